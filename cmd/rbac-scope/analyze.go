@@ -2,21 +2,52 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/alevsk/rbac-scope/internal/formatter"
 	"github.com/alevsk/rbac-scope/internal/ingestor"
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+	"github.com/alevsk/rbac-scope/internal/renderer"
+	"github.com/alevsk/rbac-scope/internal/resolver"
 	"github.com/spf13/cobra"
 )
 
+// ExitCodeFindings is the exit code used when findings cross the --fail-on or --max-findings threshold
+const ExitCodeFindings = 3
+
 var (
-	analyzeOpts = &ingestor.Options{}
-	source      string
+	analyzeOpts           = &ingestor.Options{}
+	failOn                string
+	maxFindings           int
+	baselinePath          string
+	writeBaseline         string
+	baselineOwner         string
+	baselineJustification string
 )
 
+// findingsError reports that the findings of an analysis crossed the configured threshold
+type findingsError struct {
+	count     int
+	threshold policyevaluation.RiskLevel
+	max       int
+}
+
+func (e *findingsError) Error() string {
+	return fmt.Sprintf("found %d findings with risk %s or higher, at most %d allowed", e.count, e.threshold, e.max)
+}
+
 var analyzeCmd = &cobra.Command{
-	Use:   "analyze [source]",
+	Use:   "analyze [source...]",
 	Short: "Analyze RBAC policies from various sources",
 	Long: `Analyze RBAC policies from various sources such as local YAML files, remote URLs,
-or directories containing Kubernetes manifests.
+or directories containing Kubernetes manifests. Use - as source to read YAML or JSON
+documents from stdin.
+
+Several sources are analyzed concurrently into one merged report, or into a report per
+source followed by a summary table of the highest risk per source with --report per-source.
+Sources that fail are reported and do not stop the analysis of the others, the command
+exits with an error once all reports are printed.
 
 Examples:
   # Analyze from a local YAML file
@@ -29,19 +60,128 @@ Examples:
   rbac-scope analyze ./deploy/operators/
 
   # Analyze from a helm chart
-  rbac-scope analyze ./deploy/operators/ -f values.yaml`,
-	Args: cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		source = args[0]
+  rbac-scope analyze ./deploy/operators/ -f values.yaml
+
+  # Analyze a helm chart with production values and overrides
+  rbac-scope analyze ./deploy/operators/ -f values.yaml -f values-prod.yaml --set rbac.create=true
+
+  # Analyze a helm chart as it is rendered for a release on a Kubernetes 1.29 cluster
+  rbac-scope analyze ./deploy/operators/ --release-name cert-manager --namespace cert-manager \
+    --kube-version 1.29.0 --api-versions monitoring.coreos.com/v1
+
+  # Analyze a Kustomize overlay of shared bases, inflating its helmCharts from a local chart cache
+  rbac-scope analyze ./overlays/prod --kustomize-root . --kustomize-helm --dependency-dir ./charts-cache
+
+  # Analyze every helm chart and Kustomize overlay of a repository as separate artifacts
+  rbac-scope analyze ./operator-repo --render-mode discover
+
+  # Analyze a packaged helm chart
+  rbac-scope analyze ./cert-manager-v1.14.0.tgz
+  rbac-scope analyze https://charts.example.com/operator-1.2.0.tgz
 
-		ing := ingestor.New(analyzeOpts)
-		result, err := ing.Ingest(cmd.Context(), source)
+  # Analyze a helm chart from an OCI registry or a helm repository
+  rbac-scope analyze oci://registry.example.com/charts/operator:1.2.0
+  rbac-scope analyze https://charts.example.com --chart operator@1.2.0
+
+  # Analyze rendered manifests piped to stdin
+  helm template cert-manager jetstack/cert-manager | rbac-scope analyze -
+  kustomize build ./overlays/prod | rbac-scope analyze -
+
+  # Analyze what is deployed in the prod context of the kubeconfig and save a snapshot
+  rbac-scope analyze cluster://prod --exclude-namespaces kube-system --save-snapshot prod.yaml
+
+  # Analyze a release tag of a git repository
+  rbac-scope analyze 'git::https://github.com/org/operator.git//deploy/chart?ref=v1.2.3'
+
+  # Analyze several sources listed in a file, with a report per source and a summary table
+  rbac-scope analyze --sources-file operators.txt --report per-source --concurrency 8
+
+  # Fail when a critical permission is found
+  rbac-scope analyze ./deploy/operators/ --fail-on critical
+
+  # Accept the current findings and fail only on new ones
+  rbac-scope analyze ./deploy/operators/ --write-baseline baseline.yaml --baseline-owner platform-team --baseline-justification "reviewed"
+  rbac-scope analyze ./deploy/operators/ --baseline baseline.yaml --fail-on low`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sources, err := analyzeSources(args)
+		if err != nil {
+			return err
+		}
+		mode, err := resolver.ParseRenderMode(analyzeOpts.RenderMode)
 		if err != nil {
-			return fmt.Errorf("analysis failed: %w", err)
+			return err
+		}
+		analyzeOpts.RenderMode = string(mode)
+		if mode == resolver.RenderModeDiscover {
+			// The discovered charts and overlays are reported separately unless asked otherwise
+			if !cmd.Flags().Changed("report") && analyzeOpts.OutputFormat != string(formatter.TypeSARIF) {
+				reportMode = reportPerSource
+			}
+			if reportMode == reportPerSource {
+				if sources, err = discoverSources(sources); err != nil {
+					return err
+				}
+			}
+		}
+		if reportMode != reportMerged && reportMode != reportPerSource {
+			return fmt.Errorf("invalid --report value %q: must be one of %s, %s", reportMode, reportMerged, reportPerSource)
+		}
+		if reportMode == reportPerSource && analyzeOpts.OutputFormat == string(formatter.TypeSARIF) {
+			return fmt.Errorf("per-source reports do not support sarif output, use the merged report")
 		}
 
-		if !result.Success {
-			return fmt.Errorf("analysis failed: %v", result.Error)
+		// Validate the gate before running the analysis
+		threshold := policyevaluation.RiskLevelLow
+		if failOn != "" {
+			level, err := policyevaluation.ParseRiskLevel(failOn)
+			if err != nil {
+				return fmt.Errorf("invalid --fail-on value %q: must be one of low, medium, high, critical", failOn)
+			}
+			threshold = level
+		}
+		allowed := maxFindings
+		if allowed < 0 && failOn != "" {
+			allowed = 0
+		}
+
+		analyzeOpts.Baseline = nil
+		if baselinePath != "" {
+			baseline, err := formatter.LoadBaseline(baselinePath)
+			if err != nil {
+				return err
+			}
+			analyzeOpts.Baseline = baseline
+		}
+
+		analyzeOpts.Stdin = cmd.InOrStdin()
+		var output string
+		var parsed formatter.ParsedData
+		failed := 0
+		if len(sources) == 1 && reportMode == reportMerged {
+			result, err := ingestor.New(analyzeOpts).Ingest(cmd.Context(), sources[0])
+			if err != nil {
+				return fmt.Errorf("analysis failed: %w", err)
+			}
+
+			if !result.Success {
+				return fmt.Errorf("analysis failed: %v", result.Error)
+			}
+
+			parsed, err = formatter.PrepareData(*result, &formatter.Options{Baseline: analyzeOpts.Baseline})
+			if err != nil {
+				return fmt.Errorf("failed to evaluate findings: %w", err)
+			}
+			output = result.OutputFormatted
+		} else {
+			output, parsed, failed, err = analyzeBatch(cmd.Context(), sources)
+			if err != nil {
+				return err
+			}
+		}
+
+		if writeBaseline != "" {
+			return writeBaselineFile(writeBaseline, parsed, analyzeOpts.Baseline)
 		}
 
 		// Show banner only for table output
@@ -49,11 +189,59 @@ Examples:
 			fmt.Print(GetBanner())
 		}
 
-		fmt.Print(result.OutputFormatted)
+		fmt.Print(output)
+
+		for _, s := range parsed.ExpiredSuppressions {
+			fmt.Fprintf(os.Stderr, "rbac-scope: suppression of rule %d for %s/%s owned by %s expired on %s\n",
+				s.RuleID, s.Namespace, s.SubjectName, s.Owner, s.Expires)
+		}
+
+		if allowed >= 0 {
+			summary := formatter.Summarize(parsed)
+			if count := summary.AtLeast(threshold); count > allowed {
+				fmt.Fprintf(os.Stderr, "\nrbac-scope: %d findings with risk %s or higher (%s), at most %d allowed\n",
+					count, threshold, summary, allowed)
+				return &findingsError{count: count, threshold: threshold, max: allowed}
+			}
+		}
+		if failed > 0 {
+			return &sourcesError{failed: failed, total: len(sources)}
+		}
 		return nil
 	},
 }
 
+// writeBaselineFile writes a baseline accepting the findings in parsed, the unexpired
+// suppressions of the current baseline are kept
+func writeBaselineFile(path string, parsed formatter.ParsedData, current *formatter.Baseline) error {
+	owner, justification := baselineOwner, baselineJustification
+	if owner == "" {
+		owner = formatter.PlaceholderOwner
+	}
+	if justification == "" {
+		justification = formatter.PlaceholderJustification
+	}
+	baseline := formatter.NewBaseline(parsed, justification, owner)
+	if current != nil {
+		var kept []formatter.Suppression
+		for _, s := range current.Suppressions {
+			if !s.Expired(time.Now()) {
+				kept = append(kept, s)
+			}
+		}
+		baseline.Suppressions = append(kept, baseline.Suppressions...)
+	}
+
+	if err := formatter.WriteBaseline(path, baseline); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d suppressions to %s\n", len(baseline.Suppressions), path)
+	if baselineOwner == "" || baselineJustification == "" {
+		fmt.Fprintf(os.Stderr, "Warning: replace the placeholder owner and justification in %s, the baseline is rejected until then\n", path)
+	}
+	return nil
+}
+
 func init() {
 
 	// Add flags specific to analyze command
@@ -64,8 +252,74 @@ func init() {
 		"follow symbolic links during directory traversal")
 	flags.BoolVar(&analyzeOpts.ValidateYAML, "validate-yaml", true,
 		"enable strict YAML validation during analysis")
-	flags.StringVarP(&analyzeOpts.OutputFormat, "output", "o", "table", "output format (table, json, yaml, markdown)")
+	flags.StringVar(&sourcesFile, "sources-file", "",
+		"file listing sources to analyze, one per line, in addition to the sources given as arguments")
+	flags.StringVar(&reportMode, "report", reportMerged,
+		"report of several sources: merged (one report over all sources) or per-source (a report per source and a summary table)")
+	flags.StringVarP(&analyzeOpts.OutputFormat, "output", "o", "table", "output format (table, json, yaml, markdown, sarif)")
 	flags.BoolVar(&analyzeOpts.IncludeMetadata, "include-metadata", true,
 		"include metadata in the output")
-	flags.StringVarP(&analyzeOpts.Values, "values", "f", "", "path to a values.yaml file used for rendering a helm chart")
+	addValuesFlags(analyzeCmd, &analyzeOpts.Values, "a helm chart")
+	addReleaseFlags(analyzeCmd, analyzeOpts)
+	addClusterFlags(analyzeCmd, analyzeOpts)
+	flags.StringVar(&analyzeOpts.SnapshotPath, "save-snapshot", "",
+		"write the objects read from a cluster source to this file as a YAML bundle for offline analysis")
+	flags.StringVar(&analyzeOpts.Chart, "chart", "",
+		"chart to analyze from the helm repository given as source, as name or name@version")
+	flags.BoolVar(&analyzeOpts.PlainHTTP, "plain-http", false, "use HTTP instead of HTTPS to pull charts from OCI registries")
+	flags.StringVar(&failOn, "fail-on", "",
+		"exit with code 3 when findings with this risk level or higher are found (low, medium, high, critical)")
+	flags.IntVar(&maxFindings, "max-findings", -1,
+		"maximum number of findings at or above the --fail-on level (all findings if unset) before exiting with code 3")
+	flags.StringVar(&baselinePath, "baseline", "", "path to a baseline file with accepted findings")
+	flags.BoolVar(&analyzeOpts.HideSuppressed, "hide-suppressed", false,
+		"hide findings suppressed by the baseline instead of marking them")
+	flags.StringVar(&writeBaseline, "write-baseline", "",
+		"write a baseline file accepting the current findings to this path instead of printing the report")
+	flags.StringVar(&baselineOwner, "baseline-owner", "", "owner set on the suppressions written by --write-baseline")
+	flags.StringVar(&baselineJustification, "baseline-justification", "",
+		"justification set on the suppressions written by --write-baseline")
+}
+
+// addValuesFlags registers the helm values flags of cmd, chart describes the rendered charts
+func addValuesFlags(cmd *cobra.Command, values *renderer.ValuesOptions, chart string) {
+	flags := cmd.Flags()
+	flags.StringSliceVarP(&values.ValueFiles, "values", "f", nil,
+		"values.yaml files used for rendering "+chart+", can be repeated and later files take precedence")
+	flags.StringArrayVar(&values.Values, "set", nil,
+		"set values for rendering "+chart+" (can be repeated or separated by commas: key1=val1,key2=val2)")
+	flags.StringArrayVar(&values.StringValues, "set-string", nil,
+		"set STRING values for rendering "+chart+" (can be repeated or separated by commas: key1=val1,key2=val2)")
+	flags.StringArrayVar(&values.FileValues, "set-file", nil,
+		"set values from files for rendering "+chart+" (can be repeated or separated by commas: key1=path1,key2=path2)")
+}
+
+// addClusterFlags registers the kubeconfig and namespace filter flags of cluster sources of cmd
+func addClusterFlags(cmd *cobra.Command, opts *ingestor.Options) {
+	flags := cmd.Flags()
+	flags.StringVar(&opts.Kubeconfig, "kubeconfig", "",
+		"kubeconfig file used for cluster:// sources (defaults to $KUBECONFIG or ~/.kube/config)")
+	flags.StringSliceVar(&opts.Namespaces, "cluster-namespaces", nil,
+		"namespaces read from cluster:// sources, all namespaces when unset (can be repeated)")
+	flags.StringSliceVar(&opts.ExcludeNamespaces, "exclude-namespaces", nil,
+		"namespaces not read from cluster:// sources, such as kube-system (can be repeated)")
+}
+
+// addReleaseFlags registers the rendering flags of cmd: the render mode, the helm release,
+// capabilities and dependencies, and the Kustomize root and helm charts
+func addReleaseFlags(cmd *cobra.Command, opts *ingestor.Options) {
+	flags := cmd.Flags()
+	flags.StringVar(&opts.RenderMode, "render-mode", string(resolver.RenderModeAuto),
+		"how directories are rendered: auto detects a top-level Chart.yaml or kustomization.yaml, discover renders every chart and overlay below the directory, helm, kustomize or yaml force a renderer")
+	flags.StringVar(&opts.ReleaseName, "release-name", "", "release name used for rendering helm charts (defaults to the chart name)")
+	flags.StringVar(&opts.Namespace, "namespace", "", "release namespace used for rendering helm charts (defaults to \"default\")")
+	flags.StringVar(&opts.KubeVersion, "kube-version", "", "Kubernetes version used for .Capabilities.KubeVersion when rendering helm charts")
+	flags.StringSliceVar(&opts.APIVersions, "api-versions", nil,
+		"Kubernetes API versions used for .Capabilities.APIVersions when rendering helm charts (can be repeated)")
+	flags.StringVar(&opts.DependencyDir, "dependency-dir", "",
+		"directory with packaged charts (name-version.tgz), such as a local chart repository, for chart dependencies missing from charts/ and Kustomize helmCharts")
+	flags.StringVar(&opts.KustomizeRoot, "kustomize-root", "",
+		"directory Kustomize overlays and their bases must be under (defaults to the enclosing git working tree or the overlay directory)")
+	flags.BoolVar(&opts.KustomizeHelm, "kustomize-helm", false,
+		"render the helmCharts of Kustomize overlays from their chartHome or --dependency-dir, charts are never pulled")
 }
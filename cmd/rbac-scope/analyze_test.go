@@ -3,7 +3,10 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/alevsk/rbac-scope/internal/ingestor"
@@ -40,3 +43,141 @@ func TestAnalyzeCmd_RunE_Error(t *testing.T) {
 		t.Fatal("expected error")
 	}
 }
+
+func TestAnalyzeCmd_FailOn(t *testing.T) {
+	t.Cleanup(func() {
+		failOn = ""
+		maxFindings = -1
+	})
+
+	tests := []struct {
+		name        string
+		failOn      string
+		maxFindings int
+		wantErr     bool
+		wantExit    bool
+	}{
+		{name: "disabled", maxFindings: -1},
+		{name: "findings at level", failOn: "low", maxFindings: -1, wantErr: true, wantExit: true},
+		{name: "no findings at level", failOn: "Critical", maxFindings: -1},
+		{name: "within max findings", maxFindings: 1},
+		{name: "above max findings", maxFindings: 0, wantErr: true, wantExit: true},
+		{name: "invalid level", failOn: "severe", maxFindings: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzeOpts = &ingestor.Options{OutputFormat: "json"}
+			failOn = tt.failOn
+			maxFindings = tt.maxFindings
+
+			old := os.Stdout
+			devNull, _ := os.Open(os.DevNull)
+			os.Stdout = devNull
+			analyzeCmd.SetContext(context.Background())
+			err := analyzeCmd.RunE(analyzeCmd, []string{"../../internal/renderer/testdata/cluster-role.yaml"})
+			os.Stdout = old
+			devNull.Close()
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunE() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var fErr *findingsError
+			if errors.As(err, &fErr) != tt.wantExit {
+				t.Errorf("RunE() error = %v, want findings error %v", err, tt.wantExit)
+			}
+		})
+	}
+}
+
+func TestAnalyzeCmd_Baseline(t *testing.T) {
+	t.Cleanup(func() {
+		failOn = ""
+		maxFindings = -1
+		baselinePath = ""
+		writeBaseline = ""
+		baselineOwner = ""
+		baselineJustification = ""
+	})
+	run := func() error {
+		analyzeOpts = &ingestor.Options{OutputFormat: "json"}
+		old := os.Stdout
+		devNull, _ := os.Open(os.DevNull)
+		os.Stdout = devNull
+		defer func() {
+			os.Stdout = old
+			devNull.Close()
+		}()
+		analyzeCmd.SetContext(context.Background())
+		return analyzeCmd.RunE(analyzeCmd, []string{"../../internal/renderer/testdata/cluster-role.yaml"})
+	}
+
+	// A baseline keeping the placeholder justification is rejected
+	path := filepath.Join(t.TempDir(), "baseline.yaml")
+	writeBaseline = path
+	baselineOwner = "platform-team"
+	if err := run(); err != nil {
+		t.Fatalf("write baseline: unexpected error: %v", err)
+	}
+	writeBaseline = ""
+	baselinePath = path
+	if err := run(); err == nil || !strings.Contains(err.Error(), "justification is a placeholder") {
+		t.Errorf("analysis with placeholder baseline: error = %v, want placeholder error", err)
+	}
+
+	writeBaseline = path
+	baselinePath = ""
+	baselineJustification = "reviewed by the platform team"
+	if err := run(); err != nil {
+		t.Fatalf("write baseline: unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("baseline not written: %v", err)
+	}
+	if !strings.Contains(string(content), "owner: platform-team") || !strings.Contains(string(content), "justification: reviewed by the platform team") {
+		t.Errorf("unexpected baseline:\n%s", content)
+	}
+
+	// The findings accepted by the baseline do not fail the run
+	writeBaseline = ""
+	baselinePath = path
+	failOn = "low"
+	if err := run(); err != nil {
+		t.Errorf("analysis with baseline: unexpected error: %v", err)
+	}
+
+	baselinePath = filepath.Join(t.TempDir(), "missing.yaml")
+	if err := run(); err == nil {
+		t.Error("expected error for a missing baseline file")
+	}
+}
+
+func TestAnalyzeCmd_Stdin(t *testing.T) {
+	content, err := os.ReadFile("../../internal/renderer/testdata/cluster-role.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzeOpts = &ingestor.Options{OutputFormat: "json", IncludeMetadata: true}
+	cmd := analyzeCmd
+	cmd.SetIn(bytes.NewReader(content))
+	t.Cleanup(func() { cmd.SetIn(nil) })
+
+	r, w, _ := os.Pipe()
+	old := os.Stdout
+	os.Stdout = w
+	cmd.SetContext(context.Background())
+	err = cmd.RunE(cmd, []string{"-"})
+	w.Close()
+	os.Stdout = old
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(r); err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"source": "stdin"`) || !strings.Contains(buf.String(), "pod-reader") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/formatter"
+	"github.com/alevsk/rbac-scope/internal/ingestor"
+	"github.com/alevsk/rbac-scope/internal/resolver"
+)
+
+// Report modes of an analysis of several sources
+const (
+	reportMerged    = "merged"
+	reportPerSource = "per-source"
+)
+
+var (
+	sourcesFile string
+	reportMode  string
+)
+
+// sourcesError reports that the analysis of some sources failed, the failures and the report
+// of the other sources are printed before it is returned
+type sourcesError struct {
+	failed int
+	total  int
+}
+
+func (e *sourcesError) Error() string {
+	if e.failed == e.total {
+		return fmt.Sprintf("analysis failed for all %d sources", e.total)
+	}
+	return fmt.Sprintf("analysis failed for %d of %d sources", e.failed, e.total)
+}
+
+// analyzeSources returns the sources given as arguments followed by the sources listed in --sources-file
+func analyzeSources(args []string) ([]string, error) {
+	sources := append([]string{}, args...)
+	if sourcesFile != "" {
+		listed, err := readSourcesFile(sourcesFile)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, listed...)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no source given, pass a source or --sources-file")
+	}
+
+	stdin := 0
+	for _, s := range sources {
+		if s == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return nil, fmt.Errorf("stdin can only be given once as source")
+	}
+	return sources, nil
+}
+
+// discoverSources replaces the local directories among sources by the Helm charts and Kustomize
+// overlays found below them, so each one is analyzed and reported as a separate source
+func discoverSources(sources []string) ([]string, error) {
+	var expanded []string
+	for _, source := range sources {
+		if info, err := os.Stat(source); source == "-" || err != nil || !info.IsDir() {
+			expanded = append(expanded, source)
+			continue
+		}
+		artifacts, err := resolver.DiscoverArtifacts(source)
+		if err != nil {
+			return nil, err
+		}
+		if len(artifacts) == 0 {
+			expanded = append(expanded, source)
+			continue
+		}
+		for _, artifact := range artifacts {
+			expanded = append(expanded, artifact.Path)
+		}
+	}
+	return expanded, nil
+}
+
+// readSourcesFile reads a manifest list with one source per line, blank lines and lines starting with # are ignored
+func readSourcesFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sources file: %w", err)
+	}
+	defer file.Close()
+
+	var sources []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sources = append(sources, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sources file: %w", err)
+	}
+	return sources, nil
+}
+
+// analyzeBatch analyzes the sources concurrently and returns the report, the findings of all
+// sources and the number of sources whose analysis failed. Failures are reported on stderr.
+func analyzeBatch(ctx context.Context, sources []string) (string, formatter.ParsedData, int, error) {
+	// Only the tables of a per-source report print the output of each source
+	outputType, err := formatter.ParseType(analyzeOpts.OutputFormat)
+	if err != nil {
+		return "", formatter.ParsedData{}, 0, err
+	}
+	batchOpts := *analyzeOpts
+	batchOpts.SkipFormat = reportMode == reportMerged || (outputType != formatter.TypeTable && outputType != formatter.TypeMarkdown)
+	results := ingestor.New(&batchOpts).IngestBatch(ctx, sources)
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "rbac-scope: analysis of %s failed: %v\n", r.Source, r.Err)
+		}
+	}
+	if failed == len(results) {
+		return "", formatter.ParsedData{}, failed, &sourcesError{failed: failed, total: len(results)}
+	}
+
+	if reportMode == reportMerged {
+		merged, err := ingestor.New(analyzeOpts).Merge(ctx, results)
+		if err != nil {
+			return "", formatter.ParsedData{}, failed, fmt.Errorf("failed to merge results: %w", err)
+		}
+		parsed, err := formatter.PrepareData(*merged, &formatter.Options{Baseline: analyzeOpts.Baseline})
+		if err != nil {
+			return "", formatter.ParsedData{}, failed, fmt.Errorf("failed to evaluate findings: %w", err)
+		}
+		return merged.OutputFormatted, parsed, failed, nil
+	}
+
+	fOpts := &formatter.Options{
+		IncludeMetadata: analyzeOpts.IncludeMetadata,
+		Baseline:        analyzeOpts.Baseline,
+		HideSuppressed:  analyzeOpts.HideSuppressed,
+	}
+
+	var out strings.Builder
+	var all formatter.ParsedData
+	report := formatter.BatchReport{Sources: make([]formatter.SourceReport, 0, len(results))}
+	for _, r := range results {
+		if r.Err != nil {
+			report.Sources = append(report.Sources, formatter.SourceReport{Source: r.Source, Error: r.Err.Error()})
+			continue
+		}
+		parsed, err := formatter.PrepareData(*r.Result, fOpts)
+		if err != nil {
+			return "", formatter.ParsedData{}, failed, fmt.Errorf("failed to evaluate findings of %s: %w", r.Source, err)
+		}
+		report.Sources = append(report.Sources, formatter.NewSourceReport(r.Source, parsed))
+		appendFindings(&all, parsed)
+
+		// Tables are printed one source after the other, json and yaml nest the reports
+		if outputType == formatter.TypeTable || outputType == formatter.TypeMarkdown {
+			out.WriteString(r.Result.OutputFormatted)
+			out.WriteString("\n")
+		}
+	}
+
+	summary, err := formatter.FormatBatch(report, outputType)
+	if err != nil {
+		return "", formatter.ParsedData{}, failed, err
+	}
+	out.WriteString(summary)
+	return out.String(), all, failed, nil
+}
+
+// appendFindings appends the findings of a source to the findings of all sources
+func appendFindings(all *formatter.ParsedData, parsed formatter.ParsedData) {
+	// Combinations point at the permissions of their source, which follow those of the previous sources
+	offset := len(all.RBACData)
+	all.RBACData = append(all.RBACData, parsed.RBACData...)
+	for _, combination := range parsed.CombinationData {
+		permissions := make([]int, 0, len(combination.Permissions))
+		for _, idx := range combination.Permissions {
+			permissions = append(permissions, idx+offset)
+		}
+		combination.Permissions = permissions
+		all.CombinationData = append(all.CombinationData, combination)
+	}
+	// Every source is checked against the same baseline, report each expired suppression once
+	for _, s := range parsed.ExpiredSuppressions {
+		if !slices.Contains(all.ExpiredSuppressions, s) {
+			all.ExpiredSuppressions = append(all.ExpiredSuppressions, s)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alevsk/rbac-scope/internal/formatter"
+	"github.com/alevsk/rbac-scope/internal/ingestor"
+)
+
+func TestAnalyzeSources(t *testing.T) {
+	t.Cleanup(func() { sourcesFile = "" })
+	list := filepath.Join(t.TempDir(), "sources.txt")
+	if err := os.WriteFile(list, []byte("# operators\n./deploy/a.yaml\n\n  ./deploy/b.yaml  \n-\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		file    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "arguments", args: []string{"a.yaml", "b.yaml"}, want: []string{"a.yaml", "b.yaml"}},
+		{name: "arguments and sources file", args: []string{"c.yaml"}, file: list, want: []string{"c.yaml", "./deploy/a.yaml", "./deploy/b.yaml", "-"}},
+		{name: "no source", wantErr: true},
+		{name: "stdin twice", args: []string{"-"}, file: list, wantErr: true},
+		{name: "missing sources file", file: filepath.Join(t.TempDir(), "missing.txt"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourcesFile = tt.file
+			got, err := analyzeSources(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("analyzeSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("analyzeSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeCmd_Batch(t *testing.T) {
+	t.Cleanup(func() {
+		reportMode = reportMerged
+		failOn = ""
+		maxFindings = -1
+	})
+	sources := []string{
+		"../../internal/renderer/testdata/cluster-role.yaml",
+		"../../internal/ingestor/testdata/valid.yaml",
+		"../../internal/ingestor/testdata/missing.yaml",
+	}
+
+	tests := []struct {
+		name     string
+		report   string
+		output   string
+		sources  []string
+		failOn   string
+		contains []string
+		wantErr  string
+	}{
+		{
+			name:     "merged",
+			report:   reportMerged,
+			output:   "json",
+			sources:  sources[:2],
+			contains: []string{`"name": "batch of 2 sources"`, "pod-reader", "testdata/valid.yaml"},
+		},
+		{
+			name:     "per-source with a failing source",
+			report:   reportPerSource,
+			output:   "json",
+			sources:  sources,
+			contains: []string{`"highestRisk": "Low"`, `"source": "../../internal/ingestor/testdata/missing.yaml"`, "no suitable resolver found"},
+			wantErr:  "analysis failed for 1 of 3 sources",
+		},
+		{
+			name:     "per-source tables",
+			report:   reportPerSource,
+			output:   "table",
+			sources:  sources[:2],
+			contains: []string{"SERVICE ACCOUNT BINDINGS", "SOURCES", "HIGHEST RISK"},
+		},
+		{
+			name:    "findings gate over all sources",
+			report:  reportPerSource,
+			output:  "json",
+			sources: sources[:2],
+			failOn:  "low",
+			wantErr: "findings with risk Low or higher",
+		},
+		{
+			name:    "all sources fail",
+			report:  reportMerged,
+			output:  "json",
+			sources: []string{sources[2], sources[2]},
+			wantErr: "analysis failed for all 2 sources",
+		},
+		{
+			name:    "per-source sarif",
+			report:  reportPerSource,
+			output:  "sarif",
+			sources: sources[:2],
+			wantErr: "do not support sarif",
+		},
+		{
+			name:    "invalid report",
+			report:  "combined",
+			output:  "json",
+			sources: sources[:2],
+			wantErr: "invalid --report value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzeOpts = &ingestor.Options{OutputFormat: tt.output, IncludeMetadata: true, MaxConcurrency: 2}
+			reportMode = tt.report
+			failOn = tt.failOn
+			maxFindings = -1
+
+			r, w, _ := os.Pipe()
+			old := os.Stdout
+			os.Stdout = w
+			analyzeCmd.SetContext(context.Background())
+			err := analyzeCmd.RunE(analyzeCmd, tt.sources)
+			w.Close()
+			os.Stdout = old
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			var buf bytes.Buffer
+			if _, err := buf.ReadFrom(r); err != nil {
+				t.Fatalf("read output: %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output is missing %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeCmd_Discover(t *testing.T) {
+	t.Cleanup(func() { reportMode = reportMerged })
+	root := t.TempDir()
+	role := "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: %s\nrules:\n  - apiGroups: [\"\"]\n    resources: [\"secrets\"]\n    verbs: [\"get\"]\n"
+	for name, content := range map[string]string{
+		"deploy/helm/Chart.yaml":            "apiVersion: v2\nname: operator\nversion: 1.0.0\n",
+		"deploy/helm/templates/role.yaml":   strings.Replace(role, "%s", "helm-role", 1),
+		"config/default/kustomization.yaml": "resources:\n  - role.yaml\n",
+		"config/default/role.yaml":          strings.Replace(role, "%s", "kustomize-role", 1),
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sources, err := discoverSources([]string{root, "-", filepath.Join(root, "missing")})
+	if err != nil {
+		t.Fatalf("discoverSources() error = %v", err)
+	}
+	want := []string{filepath.Join(root, "config", "default"), filepath.Join(root, "deploy", "helm"), "-", filepath.Join(root, "missing")}
+	if strings.Join(sources, ",") != strings.Join(want, ",") {
+		t.Errorf("discoverSources() = %v, want %v", sources, want)
+	}
+
+	analyzeOpts = &ingestor.Options{OutputFormat: "json", RenderMode: "discover", MaxConcurrency: 2}
+	reportMode = reportMerged
+
+	r, w, _ := os.Pipe()
+	old := os.Stdout
+	os.Stdout = w
+	analyzeCmd.SetContext(context.Background())
+	err = analyzeCmd.RunE(analyzeCmd, []string{root})
+	w.Close()
+	os.Stdout = old
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	// Without --report the discovered artifacts are reported separately
+	for _, want := range []string{`"sources"`, filepath.Join(root, "deploy", "helm"), filepath.Join(root, "config", "default")} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	analyzeOpts = &ingestor.Options{OutputFormat: "json", RenderMode: "jsonnet"}
+	if err := analyzeCmd.RunE(analyzeCmd, []string{root}); err == nil || !strings.Contains(err.Error(), "invalid render mode") {
+		t.Errorf("error = %v, want an invalid render mode", err)
+	}
+}
+
+func TestAppendFindings(t *testing.T) {
+	expired := formatter.Suppression{SubjectName: "operator", Namespace: "ops", RuleID: 1010, Owner: "platform", Expires: "2020-01-01"}
+	other := expired
+	other.RuleID = 1011
+	sources := []formatter.ParsedData{
+		{
+			RBACData:            []formatter.SARoleBindingEntry{{Resource: "secrets"}, {Resource: "pods/exec"}},
+			CombinationData:     []formatter.SACombinationEntry{{RuleID: 2000, Permissions: []int{0, 1}}},
+			ExpiredSuppressions: []formatter.Suppression{expired},
+		},
+		{
+			RBACData:            []formatter.SARoleBindingEntry{{Resource: "pods"}, {Resource: "secrets"}, {Resource: "pods/exec"}},
+			CombinationData:     []formatter.SACombinationEntry{{RuleID: 2000, Permissions: []int{1, 2}}},
+			ExpiredSuppressions: []formatter.Suppression{expired, other},
+		},
+	}
+
+	var all formatter.ParsedData
+	for _, parsed := range sources {
+		appendFindings(&all, parsed)
+	}
+	if len(all.RBACData) != 5 {
+		t.Fatalf("RBACData has %d entries, want 5", len(all.RBACData))
+	}
+	for i, want := range [][]int{{0, 1}, {3, 4}} {
+		if got := all.CombinationData[i].Permissions; !reflect.DeepEqual(got, want) {
+			t.Errorf("combination %d permissions = %v, want %v", i, got, want)
+		}
+		for _, idx := range all.CombinationData[i].Permissions {
+			if r := all.RBACData[idx].Resource; r != "secrets" && r != "pods/exec" {
+				t.Errorf("combination %d points at %s, want secrets or pods/exec", i, r)
+			}
+		}
+	}
+	if want := []formatter.Suppression{expired, other}; !reflect.DeepEqual(all.ExpiredSuppressions, want) {
+		t.Errorf("ExpiredSuppressions = %+v, want %+v", all.ExpiredSuppressions, want)
+	}
+	// The combinations of the source are left untouched
+	if got := sources[1].CombinationData[0].Permissions; !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("source permissions = %v, want [1 2]", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alevsk/rbac-scope/internal/formatter"
+	"github.com/alevsk/rbac-scope/internal/ingestor"
+	"github.com/alevsk/rbac-scope/internal/resolver"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffOpts         = &ingestor.Options{}
+	diffOutput       string
+	diffBaseValues   []string
+	diffTargetValues []string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [base] [target]",
+	Short: "Compare the RBAC policies of two sources",
+	Long: `Compare the RBAC policies of two sources, such as two versions of an operator
+or the same helm chart rendered with two values files.
+
+The report lists added and removed service accounts, added or widened permissions
+with their risk levels, removed or narrowed permissions and changed workload images.
+
+Examples:
+  # Compare two versions of an operator
+  rbac-scope diff https://example.com/operator-v1.4.yaml https://example.com/operator-v1.5.yaml
+  rbac-scope diff oci://registry.example.com/charts/operator:1.4.0 oci://registry.example.com/charts/operator:1.5.0
+
+  # Compare a helm chart rendered with two values files
+  rbac-scope diff ./chart ./chart --base-values values-prod.yaml --target-values values-next.yaml
+
+  # Compare what a chart declares with what is deployed in the cluster
+  rbac-scope diff ./chart cluster://prod --cluster-namespaces operators
+
+  # Compare the deployed manifests with a rendered chart piped to stdin
+  helm template operator ./chart | rbac-scope diff ./deploy -
+
+  # Markdown output for a pull request comment
+  rbac-scope diff ./deploy-main ./deploy -o markdown`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputType, err := formatter.ParseType(diffOutput)
+		if err != nil {
+			return err
+		}
+		if outputType == formatter.TypeSARIF {
+			return fmt.Errorf("unsupported diff output format: %s", diffOutput)
+		}
+
+		mode, err := resolver.ParseRenderMode(diffOpts.RenderMode)
+		if err != nil {
+			return err
+		}
+		diffOpts.RenderMode = string(mode)
+
+		if args[0] == "-" && args[1] == "-" {
+			return fmt.Errorf("only one of the diff sources can be read from stdin")
+		}
+		diffOpts.Stdin = cmd.InOrStdin()
+
+		base, err := analyzeForDiff(cmd.Context(), args[0], diffBaseValues)
+		if err != nil {
+			return fmt.Errorf("base analysis failed: %w", err)
+		}
+		target, err := analyzeForDiff(cmd.Context(), args[1], diffTargetValues)
+		if err != nil {
+			return fmt.Errorf("target analysis failed: %w", err)
+		}
+
+		out, err := formatter.FormatDiff(formatter.Diff(base, target), outputType)
+		if err != nil {
+			return err
+		}
+
+		// Show banner only for table output
+		if outputType == formatter.TypeTable {
+			fmt.Print(GetBanner())
+		}
+		fmt.Print(out)
+		return nil
+	},
+}
+
+// analyzeForDiff ingests source and returns its parsed data, valueFiles are merged over the shared values files
+func analyzeForDiff(ctx context.Context, source string, valueFiles []string) (formatter.ParsedData, error) {
+	opts := *diffOpts
+	opts.SkipFormat = true
+	opts.Values.ValueFiles = append(append([]string{}, diffOpts.Values.ValueFiles...), valueFiles...)
+
+	result, err := ingestor.New(&opts).Ingest(ctx, source)
+	if err != nil {
+		return formatter.ParsedData{}, err
+	}
+	if !result.Success {
+		return formatter.ParsedData{}, fmt.Errorf("%v", result.Error)
+	}
+	return formatter.PrepareData(*result, &formatter.Options{IncludeMetadata: true})
+}
+
+func init() {
+	flags := diffCmd.Flags()
+	flags.IntVar(&diffOpts.MaxConcurrency, "concurrency", 4,
+		"maximum number of concurrent analysis operations")
+	flags.BoolVar(&diffOpts.FollowSymlinks, "follow-symlinks", false,
+		"follow symbolic links during directory traversal")
+	flags.BoolVar(&diffOpts.ValidateYAML, "validate-yaml", true,
+		"enable strict YAML validation during analysis")
+	flags.StringVarP(&diffOutput, "output", "o", "table", "output format (table, json, yaml, markdown)")
+	addValuesFlags(diffCmd, &diffOpts.Values, "both helm charts")
+	addReleaseFlags(diffCmd, diffOpts)
+	addClusterFlags(diffCmd, diffOpts)
+	flags.BoolVar(&diffOpts.PlainHTTP, "plain-http", false, "use HTTP instead of HTTPS to pull charts from OCI registries")
+	flags.StringSliceVar(&diffBaseValues, "base-values", nil,
+		"values.yaml files merged over --values for rendering the base helm chart")
+	flags.StringSliceVar(&diffTargetValues, "target-values", nil,
+		"values.yaml files merged over --values for rendering the target helm chart")
+}
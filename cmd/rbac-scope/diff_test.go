@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alevsk/rbac-scope/internal/ingestor"
+)
+
+func TestDiffCmd_RunE(t *testing.T) {
+	t.Cleanup(func() { diffOutput = "table" })
+
+	diffOpts = &ingestor.Options{ValidateYAML: true}
+	diffOutput = "markdown"
+	r, w, _ := os.Pipe()
+	old := os.Stdout
+	os.Stdout = w
+	diffCmd.SetContext(context.Background())
+	err := diffCmd.RunE(diffCmd, []string{"../../internal/renderer/testdata/role.yaml", "../../internal/renderer/testdata/cluster-role.yaml"})
+	w.Close()
+	os.Stdout = old
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(r); err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	for _, want := range []string{"## RBAC changes", "| added | pod-reader |", "| removed | secret-reader |"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestDiffCmd_RunE_Error(t *testing.T) {
+	t.Cleanup(func() { diffOutput = "table" })
+
+	tests := []struct {
+		name   string
+		output string
+		args   []string
+	}{
+		{"unsupported output", "sarif", []string{"../../internal/renderer/testdata/role.yaml", "../../internal/renderer/testdata/role.yaml"}},
+		{"missing base", "json", []string{"../../internal/ingestor/testdata/nonexistent.yaml", "../../internal/renderer/testdata/role.yaml"}},
+		{"missing target", "json", []string{"../../internal/renderer/testdata/role.yaml", "../../internal/ingestor/testdata/nonexistent.yaml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffOpts = &ingestor.Options{}
+			diffOutput = tt.output
+			diffCmd.SetContext(context.Background())
+			if err := diffCmd.RunE(diffCmd, tt.args); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
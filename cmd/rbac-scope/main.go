@@ -1,17 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/alevsk/rbac-scope/internal/config"
 	"github.com/alevsk/rbac-scope/internal/logger"
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
 	"github.com/spf13/cobra"
 )
 
 var (
-	configPath string
-	debug      bool
+	configPath   string
+	debug        bool
+	rulesPaths   []string
+	rulesReplace bool
 )
 
 var cfg = &config.Config{}
@@ -36,9 +40,26 @@ helping identify permissions, potential risks, and abuse scenarios.`,
 			cfg.Debug = true
 		}
 
+		if cmd.Flags().Changed("rules") {
+			cfg.Rules.Paths = rulesPaths
+		}
+		if cmd.Flags().Changed("rules-replace") {
+			cfg.Rules.Replace = rulesReplace
+		}
+
 		// Initialize logger
 		logger.Init(cfg)
 
+		// Load custom risk rules
+		if len(cfg.Rules.Paths) > 0 {
+			if err := policyevaluation.LoadCustomRiskRules(cfg.Rules.Paths, cfg.Rules.Replace); err != nil {
+				return fmt.Errorf("error loading risk rules: %w", err)
+			}
+			logger.Debug().Msgf("Loaded custom risk rules from %v", cfg.Rules.Paths)
+		} else if cfg.Rules.Replace {
+			return fmt.Errorf("rules replace requires at least one rules path")
+		}
+
 		// Print configuration source
 		if configPath != "" || os.Getenv(config.RbacOpsConfigPathEnvVar) != "" {
 			logger.Debug().Msgf("Using config file: %s", configPath)
@@ -54,6 +75,8 @@ func init() {
 	// Add global flags
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "path to config file (default: config.yml in current directory)")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable verbose logging and additional debug information")
+	rootCmd.PersistentFlags().StringSliceVar(&rulesPaths, "rules", nil, "files or directories with custom risk rules (can be repeated)")
+	rootCmd.PersistentFlags().BoolVar(&rulesReplace, "rules-replace", false, "use only the custom risk rules instead of merging them with the built-in rules")
 
 	// Add commands
 	rootCmd.AddCommand(serveCmd)
@@ -64,22 +87,45 @@ func init() {
 	// Add analyze command to root command
 	rootCmd.AddCommand(analyzeCmd)
 
+	// Add diff command to root command
+	rootCmd.AddCommand(diffCmd)
+
 	// Add version command to root command
 	rootCmd.AddCommand(versionCmd)
 }
 
 func main() {
-	// Custom error handling to show usage before error
-	if err := rootCmd.Execute(); err != nil {
-		// Get the most recent command
-		cmd := rootCmd
-		if c, err2 := rootCmd.ExecuteC(); err2 == nil {
-			cmd = c
-		}
-		// Show usage first
-		fmt.Println(cmd.UsageString())
-		// Then show the error
+	if code := execute(); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// execute runs the root command once and returns the exit code of the process
+func execute() int {
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return 0
+	}
+
+	// Findings crossing the threshold are not a usage error, the summary was already printed
+	var fErr *findingsError
+	if errors.As(err, &fErr) {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return ExitCodeFindings
+	}
+	// Neither are failed sources, their errors and the report of the others were already printed
+	var sErr *sourcesError
+	if errors.As(err, &sErr) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if cmd == nil {
+		cmd = rootCmd
 	}
+	// Show usage first
+	fmt.Println(cmd.UsageString())
+	// Then show the error
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return 1
 }
@@ -1,6 +1,17 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/ingestor"
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+)
 
 func TestMainExecute(t *testing.T) {
 	rootCmd.SetArgs([]string{"--help"})
@@ -24,5 +35,101 @@ func TestServeCmd_PreRun(t *testing.T) {
 	if cfg.Server.Host != "1.1.1.1" || cfg.Server.Port != 9999 {
 		t.Fatalf("flags not applied")
 	}
-	serveCmd.Run(serveCmd, nil)
+	if cfg.Server.Timeout != 5*time.Second || cfg.Server.LogLevel != "debug" {
+		t.Fatalf("flags not applied")
+	}
+}
+
+func TestServeCmd_RunE(t *testing.T) {
+	cfg.Server.Host = "127.0.0.1"
+	cfg.Server.Port = 0
+	cfg.Server.Timeout = time.Second
+
+	// A cancelled context makes the server shut down right after starting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	serveCmd.SetContext(ctx)
+	if err := serveCmd.RunE(serveCmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRootCmd_CustomRules(t *testing.T) {
+	t.Cleanup(func() {
+		policyevaluation.ResetRiskRules()
+		for _, name := range []string{"rules", "rules-replace"} {
+			rootCmd.PersistentFlags().Lookup(name).Changed = false
+		}
+		rulesPaths, rulesReplace = nil, false
+	})
+
+	rulesFile := filepath.Join(t.TempDir(), "rules.yaml")
+	rule := "- id: 50000\n  name: custom\n  role_type: Role\n  risk_level: RiskLevelLow\n"
+	if err := os.WriteFile(rulesFile, []byte(rule), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rootCmd.ParseFlags([]string{"--rules", rulesFile, "--rules-replace"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rootCmd.PersistentPreRunE(rootCmd, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rules := policyevaluation.GetRiskRules(); len(rules) != 1 || rules[0].ID != 50000 {
+		t.Fatalf("expected only the custom rule, got %d rules", len(rules))
+	}
+
+	if err := rootCmd.ParseFlags([]string{"--rules", filepath.Join(t.TempDir(), "missing.yaml")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rootCmd.PersistentPreRunE(rootCmd, nil); err == nil {
+		t.Fatal("expected error for missing rules file")
+	}
+}
+
+func TestExecute_FailedSources(t *testing.T) {
+	t.Cleanup(func() {
+		reportMode = reportMerged
+		rootCmd.SetArgs(nil)
+	})
+	analyzeOpts = &ingestor.Options{OutputFormat: "json", IncludeMetadata: true, MaxConcurrency: 2}
+	reportMode = reportMerged
+	failOn, maxFindings = "", -1
+	missing := "../../internal/ingestor/testdata/missing.yaml"
+	rootCmd.SetArgs([]string{"analyze", "../../internal/ingestor/testdata/valid.yaml", missing})
+
+	dir := t.TempDir()
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, stderr
+	code := execute()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	stdout.Close()
+	stderr.Close()
+
+	if code != 1 {
+		t.Errorf("execute() = %d, want 1", code)
+	}
+	out, _ := os.ReadFile(stdout.Name())
+	var report map[string]interface{}
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Errorf("stdout is not a single JSON report: %v\n%s", err, out)
+	}
+	if strings.Contains(string(out), "Usage:") {
+		t.Errorf("stdout contains the usage:\n%s", out)
+	}
+	errOut, _ := os.ReadFile(stderr.Name())
+	if n := strings.Count(string(errOut), "analysis of "+missing+" failed"); n != 1 {
+		t.Errorf("stderr reports the failed source %d times, want once:\n%s", n, errOut)
+	}
+	if !strings.Contains(string(errOut), "Error: analysis failed for 1 of 2 sources") {
+		t.Errorf("stderr is missing the error:\n%s", errOut)
+	}
 }
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/alevsk/rbac-scope/internal/api"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -14,6 +21,7 @@ var (
 	serverPort     int
 	serverTimeout  string
 	serverLogLevel string
+	allowedHosts   []string
 )
 
 // serveCmd represents the serve command
@@ -36,13 +44,33 @@ var serveCmd = &cobra.Command{
 		if cmd.Flags().Changed("log-level") {
 			cfg.Server.LogLevel = serverLogLevel
 		}
+		if cmd.Flags().Changed("allowed-url-hosts") {
+			cfg.Server.AllowedURLHosts = allowedHosts
+		}
 	},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Always show banner for serve command as it's human-readable output
 		fmt.Print(GetBanner())
 		fmt.Printf("Starting RBAC-Scope API server on %s:%d...\n", cfg.Server.Host, cfg.Server.Port)
 		fmt.Printf("Log level: %s, Timeout: %v\n", cfg.Server.LogLevel, cfg.Server.Timeout)
-		// TODO: Implement API server
+
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		// Stop gracefully on Ctrl+C or when the container runtime asks us to
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		server := api.NewServer(&api.Options{
+			Timeout:         cfg.Server.Timeout,
+			AllowedURLHosts: cfg.Server.AllowedURLHosts,
+		})
+		addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+		if err := server.Run(ctx, addr); err != nil {
+			return fmt.Errorf("API server failed: %w", err)
+		}
+		return nil
 	},
 }
 
@@ -52,6 +80,8 @@ func init() {
 	serveCmd.Flags().IntVarP(&serverPort, "port", "p", 0, "Server port (default: 8080)")
 	serveCmd.Flags().StringVarP(&serverTimeout, "timeout", "t", "", "Server timeout (e.g., 30s, 1m)")
 	serveCmd.Flags().StringVarP(&serverLogLevel, "log-level", "l", "", "Log level (debug, info, warn, error)")
+	serveCmd.Flags().StringSliceVar(&allowedHosts, "allowed-url-hosts", nil,
+		"Hosts analyze requests may fetch a url from (default: none, url sources are rejected)")
 
 	// Bind flags to viper
 	if err := viper.BindPFlag("server.host", serveCmd.Flags().Lookup("host")); err != nil {
@@ -66,4 +96,7 @@ func init() {
 	if err := viper.BindPFlag("server.log_level", serveCmd.Flags().Lookup("log-level")); err != nil {
 		panic(fmt.Sprintf("failed to bind flag: %v", err))
 	}
+	if err := viper.BindPFlag("server.allowed_url_hosts", serveCmd.Flags().Lookup("allowed-url-hosts")); err != nil {
+		panic(fmt.Sprintf("failed to bind flag: %v", err))
+	}
 }
@@ -1,22 +1,97 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/alevsk/rbac-scope/internal/formatter"
+	"github.com/alevsk/rbac-scope/internal/ingestor"
 	"github.com/gorilla/mux"
+	"helm.sh/helm/v3/pkg/chartutil"
 )
 
+// Options holds configuration for the API server
+type Options struct {
+	// Timeout bounds reading a request and running an analysis
+	Timeout time.Duration
+	// MaxBodySize is the maximum accepted request body size in bytes
+	MaxBodySize int64
+	// AllowedURLHosts are the hosts the url field of an analyze request may fetch from, redirects
+	// must stay on them. URL sources are rejected when it is empty.
+	AllowedURLHosts []string
+}
+
+// DefaultOptions returns the default API server options
+func DefaultOptions() *Options {
+	return &Options{
+		Timeout:     30 * time.Second,
+		MaxBodySize: 10 << 20, // 10 MiB
+	}
+}
+
+// AnalyzeRequest is the JSON body accepted by the analyze endpoint
+type AnalyzeRequest struct {
+	// URL is a remote http(s) source to analyze
+	URL string `json:"url,omitempty"`
+	// Manifest is an inline YAML manifest stream to analyze
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// errorResponse is the body returned when a request fails
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
 // Server represents the API server
 type Server struct {
 	router *mux.Router
+	opts   *Options
+	client *http.Client // Fetches the url of analyze requests
 }
 
-// NewServer creates a new API server instance
-func NewServer() *Server {
+// NewServer creates a new API server instance, opts is copied and zero values fall back to
+// the defaults
+func NewServer(opts *Options) *Server {
+	defaults := DefaultOptions()
+	if opts == nil {
+		opts = defaults
+	}
+	o := *opts
+	o.AllowedURLHosts = slices.Clone(opts.AllowedURLHosts)
+	if o.Timeout <= 0 {
+		o.Timeout = defaults.Timeout
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaults.MaxBodySize
+	}
+
 	s := &Server{
 		router: mux.NewRouter(),
+		opts:   &o,
+	}
+	s.client = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("too many redirects")
+			}
+			if !s.allowedURL(req.URL) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+			}
+			return nil
+		},
 	}
 	s.routes()
 	return s
@@ -25,12 +100,49 @@ func NewServer() *Server {
 // routes sets up the API routes
 func (s *Server) routes() {
 	s.router.HandleFunc("/api/v1/health", s.healthCheck).Methods("GET")
+	s.router.HandleFunc("/api/v1/analyze", s.analyze).Methods("POST")
+}
+
+// handler wraps the router so every request is bounded by the configured timeout
+func (s *Server) handler() http.Handler {
+	return http.TimeoutHandler(s.router, s.opts.Timeout, `{"error":"request timed out"}`)
 }
 
-// Start starts the API server
+// Start starts the API server and blocks until it stops
 func (s *Server) Start(addr string) error {
-	log.Printf("Starting server on %s", addr)
-	return http.ListenAndServe(addr, s.router)
+	return s.Run(context.Background(), addr)
+}
+
+// Run starts the API server and blocks until it fails or ctx is cancelled,
+// in which case in-flight requests are given the configured timeout to finish.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: s.opts.Timeout,
+		ReadTimeout:       s.opts.Timeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down server: %w", err)
+		}
+		if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
 }
 
 // healthCheck handles the health check endpoint
@@ -43,3 +155,187 @@ func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// analyze handles the analysis endpoint. The request body is interpreted
+// based on its Content-Type:
+//   - application/json: an AnalyzeRequest with either a URL or an inline manifest
+//   - application/gzip, application/x-gzip, application/x-tar, application/octet-stream: a packaged Helm chart
+//   - anything else: a raw YAML manifest stream
+func (s *Server) analyze(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBodySize)
+
+	workDir, err := os.MkdirTemp("", "rbac-scope-api-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to create work directory: %w", err))
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.opts.Timeout)
+	defer cancel()
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var source, sourceName string
+	switch mediaType {
+	case "application/json":
+		var req AnalyzeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		switch {
+		case req.URL != "" && req.Manifest != "":
+			writeError(w, http.StatusBadRequest, fmt.Errorf("only one of url or manifest can be set"))
+			return
+		case req.URL != "":
+			u, err := url.Parse(req.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("url must be an absolute http(s) URL"))
+				return
+			}
+			if !s.allowedURL(u) {
+				writeError(w, http.StatusForbidden, fmt.Errorf("url host %s is not allowed", u.Hostname()))
+				return
+			}
+			source, err = s.fetchManifest(ctx, workDir, u)
+			if err != nil {
+				status := http.StatusUnprocessableEntity
+				if errors.Is(err, context.DeadlineExceeded) {
+					status = http.StatusGatewayTimeout
+				}
+				writeError(w, status, err)
+				return
+			}
+			sourceName = req.URL
+		case req.Manifest != "":
+			source, err = writeManifest(workDir, strings.NewReader(req.Manifest))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			sourceName = "request"
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("either url or manifest must be set"))
+			return
+		}
+	case "application/gzip", "application/x-gzip", "application/x-tar", "application/x-compressed-tar", "application/octet-stream":
+		source, err = expandChart(workDir, r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		sourceName = "request"
+	default:
+		source, err = writeManifest(workDir, r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		sourceName = "request"
+	}
+
+	// The result is encoded below, the ingestor does not need to format it
+	ingestOpts := ingestor.DefaultOptions()
+	ingestOpts.SkipFormat = true
+	result, err := ingestor.New(ingestOpts).Ingest(ctx, source)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			writeError(w, http.StatusGatewayTimeout, fmt.Errorf("analysis timed out"))
+			return
+		}
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("analysis failed: %w", err))
+		return
+	}
+	// Temporary paths are an implementation detail of the API, report where the data came from instead
+	result.Source = sourceName
+
+	parsed, err := formatter.PrepareData(*result, formatter.DefaultOptions())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to prepare result: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(parsed); err != nil {
+		log.Printf("Failed to encode analyze response: %v", err)
+	}
+}
+
+// allowedURL reports whether the host of u is one of the allowed URL hosts
+func (s *Server) allowedURL(u *url.URL) bool {
+	host := u.Hostname()
+	return slices.ContainsFunc(s.opts.AllowedURLHosts, func(allowed string) bool {
+		return strings.EqualFold(allowed, host)
+	})
+}
+
+// fetchManifest downloads the manifest at u into dir and returns the file path, the download
+// is bounded by the maximum body size
+func (s *Server) fetchManifest(ctx context.Context, dir string, u *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/yaml,text/yaml,application/json,text/plain")
+	req.Header.Set("User-Agent", "rbac-scope/1.0")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch url: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch url: HTTP status %s", resp.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, s.opts.MaxBodySize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read url: %w", err)
+	}
+	if int64(len(content)) > s.opts.MaxBodySize {
+		return "", fmt.Errorf("url content exceeds %d bytes", s.opts.MaxBodySize)
+	}
+	return writeManifest(dir, bytes.NewReader(content))
+}
+
+// writeManifest stores a manifest stream in dir and returns the file path
+func writeManifest(dir string, body io.Reader) (string, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(content) == 0 {
+		return "", fmt.Errorf("request body is empty")
+	}
+	path := filepath.Join(dir, "manifest.yaml")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return "", fmt.Errorf("failed to store manifest: %w", err)
+	}
+	return path, nil
+}
+
+// expandChart unpacks a chart archive into dir and returns the chart directory
+func expandChart(dir string, body io.Reader) (string, error) {
+	if err := chartutil.Expand(dir, body); err != nil {
+		return "", fmt.Errorf("invalid chart archive: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read expanded chart: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			return filepath.Join(dir, entry.Name()), nil
+		}
+	}
+	return "", fmt.Errorf("chart archive did not contain a chart")
+}
+
+// writeError writes a JSON error response with the given status code
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if encErr := json.NewEncoder(w).Encode(errorResponse{Error: err.Error()}); encErr != nil {
+		log.Printf("Failed to encode error response: %v", encErr)
+	}
+}
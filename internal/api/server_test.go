@@ -1,13 +1,22 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/formatter"
 )
 
 func TestNewServer(t *testing.T) {
-	s := NewServer()
+	s := NewServer(nil)
 	if s == nil {
 		t.Fatal("NewServer() returned nil")
 	}
@@ -27,7 +36,7 @@ func TestNewServer(t *testing.T) {
 }
 
 func TestHealthCheckHandler(t *testing.T) {
-	s := NewServer() // Server setup includes routes
+	s := NewServer(nil) // Server setup includes routes
 	req, err := http.NewRequest("GET", "/api/v1/health", nil)
 	if err != nil {
 		t.Fatal(err)
@@ -71,7 +80,7 @@ func (m *mockResponseWriter) Write(body []byte) (int, error) {
 }
 
 func TestHealthCheckHandler_EncodingError(t *testing.T) {
-	s := NewServer()
+	s := NewServer(nil)
 	req, err := http.NewRequest("GET", "/api/v1/health", nil)
 	if err != nil {
 		t.Fatal(err)
@@ -94,7 +103,7 @@ func TestHealthCheckHandler_EncodingError(t *testing.T) {
 }
 
 func TestStartServer_InvalidAddress(t *testing.T) {
-	s := NewServer()
+	s := NewServer(nil)
 	// Provide an invalid address format.
 	// This should cause http.ListenAndServe to return an error immediately.
 	invalidAddr := "invalid-address-is-not-valid:8080:abc"
@@ -106,3 +115,225 @@ func TestStartServer_InvalidAddress(t *testing.T) {
 	// We could also check for a specific error type if http.ListenAndServe guarantees one,
 	// but for coverage, checking for any error is sufficient.
 }
+
+func TestAnalyzeHandler(t *testing.T) {
+	manifest := `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: pod-reader
+  namespace: default
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get", "list"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: pod-reader-binding
+  namespace: default
+subjects:
+- kind: ServiceAccount
+  name: reader
+  namespace: default
+roleRef:
+  kind: Role
+  name: pod-reader
+  apiGroup: rbac.authorization.k8s.io
+`
+	chart, err := os.ReadFile(filepath.Join("..", "renderer", "testdata", "fixtures", "test-chart-0.1.0.tgz"))
+	if err != nil {
+		t.Fatalf("failed to read chart fixture: %v", err)
+	}
+	inline, err := json.Marshal(AnalyzeRequest{Manifest: manifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantStatus  int
+		wantRBAC    int
+	}{
+		{
+			name:        "yaml manifest body",
+			contentType: "application/yaml",
+			body:        []byte(manifest),
+			wantStatus:  http.StatusOK,
+			wantRBAC:    1,
+		},
+		{
+			name:        "inline manifest in json request",
+			contentType: "application/json",
+			body:        inline,
+			wantStatus:  http.StatusOK,
+			wantRBAC:    1,
+		},
+		{
+			name:        "chart archive",
+			contentType: "application/gzip",
+			body:        chart,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "empty body",
+			contentType: "application/yaml",
+			body:        nil,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "invalid json request",
+			contentType: "application/json",
+			body:        []byte("{"),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "json request without source",
+			contentType: "application/json",
+			body:        []byte("{}"),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "non http url",
+			contentType: "application/json",
+			body:        []byte(`{"url":"file:///etc/passwd"}`),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "invalid chart archive",
+			contentType: "application/gzip",
+			body:        []byte("not a chart"),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "invalid yaml manifest",
+			contentType: "text/plain",
+			body:        []byte("key: [unclosed"),
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(nil)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analyze", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("analyze returned status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				var resp errorResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+					t.Errorf("expected JSON error body, got %q", rr.Body.String())
+				}
+				return
+			}
+
+			var parsed formatter.ParsedData
+			if err := json.Unmarshal(rr.Body.Bytes(), &parsed); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if parsed.Metadata == nil || parsed.Metadata.Source != "request" {
+				t.Errorf("expected metadata source %q, got %+v", "request", parsed.Metadata)
+			}
+			if len(parsed.RBACData) != tt.wantRBAC {
+				t.Errorf("got %d RBAC rows, want %d", len(parsed.RBACData), tt.wantRBAC)
+			}
+		})
+	}
+}
+
+func TestAnalyzeHandler_URL(t *testing.T) {
+	manifest, err := os.ReadFile(filepath.Join("..", "renderer", "testdata", "cluster-role.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			// localhost is not allowed, only 127.0.0.1 is
+			http.Redirect(w, r, strings.Replace(r.Host, "127.0.0.1", "http://localhost", 1)+"/rbac.yaml", http.StatusFound)
+			return
+		}
+		w.Write(manifest)
+	}))
+	defer remote.Close()
+
+	tests := []struct {
+		name       string
+		allowed    []string
+		path       string
+		maxBody    int64
+		wantStatus int
+	}{
+		{name: "url sources are disabled by default", path: "/rbac.yaml", wantStatus: http.StatusForbidden},
+		{name: "host not allowed", allowed: []string{"example.com"}, path: "/rbac.yaml", wantStatus: http.StatusForbidden},
+		{name: "allowed host", allowed: []string{"127.0.0.1"}, path: "/rbac.yaml", wantStatus: http.StatusOK},
+		{name: "redirect to a host not allowed", allowed: []string{"127.0.0.1"}, path: "/redirect", wantStatus: http.StatusUnprocessableEntity},
+		{name: "content too large", allowed: []string{"127.0.0.1"}, path: "/rbac.yaml", maxBody: 64, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(&Options{AllowedURLHosts: tt.allowed, MaxBodySize: tt.maxBody})
+			body, _ := json.Marshal(AnalyzeRequest{URL: remote.URL + tt.path})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analyze", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("analyze returned status %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var parsed formatter.ParsedData
+			if err := json.Unmarshal(rr.Body.Bytes(), &parsed); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if parsed.Metadata == nil || parsed.Metadata.Source != remote.URL+tt.path {
+				t.Errorf("expected metadata source %q, got %+v", remote.URL+tt.path, parsed.Metadata)
+			}
+		})
+	}
+}
+
+func TestNewServer_Options(t *testing.T) {
+	opts := &Options{AllowedURLHosts: []string{"example.com"}}
+	s := NewServer(opts)
+	if opts.Timeout != 0 || opts.MaxBodySize != 0 {
+		t.Errorf("NewServer() changed the options to %+v", opts)
+	}
+	opts.AllowedURLHosts[0] = "internal"
+	if s.opts.Timeout != DefaultOptions().Timeout || s.opts.MaxBodySize != DefaultOptions().MaxBodySize ||
+		s.opts.AllowedURLHosts[0] != "example.com" {
+		t.Errorf("server options = %+v, want the defaults and the allowed hosts", s.opts)
+	}
+}
+
+func TestAnalyzeHandler_BodyTooLarge(t *testing.T) {
+	s := NewServer(&Options{MaxBodySize: 8})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/analyze", strings.NewReader("kind: Role\nmetadata:\n  name: too-large\n"))
+	req.Header.Set("Content-Type", "application/yaml")
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("analyze returned status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRun_Shutdown(t *testing.T) {
+	s := NewServer(&Options{Timeout: time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Run(ctx, "127.0.0.1:0"); err != nil {
+		t.Errorf("Run() with cancelled context returned error: %v", err)
+	}
+}
@@ -23,8 +23,18 @@ type Config struct {
 		Port     int           `mapstructure:"port"`
 		Timeout  time.Duration `mapstructure:"timeout"`
 		LogLevel string        `mapstructure:"log_level"`
+		// AllowedURLHosts are the hosts analyze requests may fetch a url from
+		AllowedURLHosts []string `mapstructure:"allowed_url_hosts"`
 	} `mapstructure:"server"`
 
+	// Rules configures custom risk rules
+	Rules struct {
+		// Paths are files or directories containing RiskRule YAML
+		Paths []string `mapstructure:"paths"`
+		// Replace uses only the custom rules instead of merging them with the built-in rules
+		Replace bool `mapstructure:"replace"`
+	} `mapstructure:"rules"`
+
 	// Database configuration
 	Database struct {
 		Host     string `mapstructure:"host"`
@@ -107,6 +117,11 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.timeout", "30s")
 	v.SetDefault("server.log_level", "info")
+	v.SetDefault("server.allowed_url_hosts", []string{})
+
+	// Rules defaults
+	v.SetDefault("rules.paths", []string{})
+	v.SetDefault("rules.replace", false)
 
 	// Database defaults
 	v.SetDefault("database.host", "localhost")
@@ -0,0 +1,216 @@
+package extractor
+
+import (
+	"slices"
+	"sort"
+)
+
+// labelSelectorRequirement is a single matchExpressions entry of a label selector
+type labelSelectorRequirement struct {
+	key      string
+	operator string
+	values   []string
+}
+
+// labelSelector is a Kubernetes label selector as used by ClusterRole aggregationRules
+type labelSelector struct {
+	matchLabels      map[string]string
+	matchExpressions []labelSelectorRequirement
+}
+
+// matches reports whether the selector matches the given labels. As in Kubernetes,
+// an empty selector matches everything.
+func (s labelSelector) matches(labels map[string]string) bool {
+	for k, v := range s.matchLabels {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	for _, req := range s.matchExpressions {
+		value, exists := labels[req.key]
+		switch req.operator {
+		case "In":
+			if !exists || !slices.Contains(req.values, value) {
+				return false
+			}
+		case "NotIn":
+			if exists && slices.Contains(req.values, value) {
+				return false
+			}
+		case "Exists":
+			if !exists {
+				return false
+			}
+		case "DoesNotExist":
+			if exists {
+				return false
+			}
+		default:
+			// Unknown operators never match, the API server would reject them anyway
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseLabelSelectors converts the clusterRoleSelectors of an aggregationRule into label selectors
+func parseLabelSelectors(v interface{}) []labelSelector {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	selectors := make([]labelSelector, 0, len(items))
+	for _, item := range items {
+		selectorMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		selector := labelSelector{
+			matchLabels: toStringMap(selectorMap["matchLabels"]),
+		}
+		if expressions, ok := selectorMap["matchExpressions"].([]interface{}); ok {
+			for _, e := range expressions {
+				expression, ok := e.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				selector.matchExpressions = append(selector.matchExpressions, labelSelectorRequirement{
+					key:      getStringValue(expression, "key"),
+					operator: getStringValue(expression, "operator"),
+					values:   toStringSlice(expression["values"]),
+				})
+			}
+		}
+		selectors = append(selectors, selector)
+	}
+
+	return selectors
+}
+
+// aggregateClusterRoles replaces the permissions and rules of every aggregated ClusterRole with
+// those of the ClusterRoles matched by its selectors, the same way the Kubernetes controller
+// manager does. Aggregated roles can select other aggregated roles, those are resolved first.
+func aggregateClusterRoles(roles []RBACRole, labels map[string]map[string]string, selectors map[string][]labelSelector) {
+	if len(selectors) == 0 {
+		return
+	}
+
+	clusterRoles := make(map[string]int)
+	for i, role := range roles {
+		if role.Type == "ClusterRole" {
+			clusterRoles[role.Name] = i
+		}
+	}
+
+	// Sort names so contributions are recorded in a deterministic order
+	names := make([]string, 0, len(clusterRoles))
+	for name := range clusterRoles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := make(map[string]bool)
+	var resolve func(name string, visiting map[string]bool)
+	resolve = func(name string, visiting map[string]bool) {
+		if resolved[name] || visiting[name] {
+			return
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		// The controller manager overwrites the rules of an aggregated role, its own rules are ignored
+		target := &roles[clusterRoles[name]]
+		target.Permissions = RuleApiGroup{}
+		target.Rules = nil
+		target.AggregatedFrom = nil
+		for _, sourceName := range names {
+			if sourceName == name || !matchesAnySelector(selectors[name], labels[sourceName]) {
+				continue
+			}
+			// A selected role may be aggregated itself, expand it before copying its permissions
+			if _, aggregated := selectors[sourceName]; aggregated {
+				resolve(sourceName, visiting)
+			}
+			mergePermissions(target, sourceName, roles[clusterRoles[sourceName]].Permissions)
+			mergeRules(target, sourceName, roles[clusterRoles[sourceName]].Rules)
+		}
+		resolved[name] = true
+	}
+
+	for _, name := range names {
+		if _, aggregated := selectors[name]; aggregated {
+			resolve(name, make(map[string]bool))
+		}
+	}
+}
+
+// matchesAnySelector reports whether any of the selectors matches the labels
+func matchesAnySelector(selectors []labelSelector, labels map[string]string) bool {
+	for _, selector := range selectors {
+		if selector.matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeRules copies the rules of the named source role into target, rules the source
+// aggregated itself keep the ClusterRole that defines them
+func mergeRules(target *RBACRole, source string, rules []PolicyRule) {
+	for _, rule := range rules {
+		if rule.AggregatedFrom == "" {
+			rule.AggregatedFrom = source
+		}
+		target.Rules = append(target.Rules, rule)
+	}
+}
+
+// mergePermissions copies permissions from the named source role into target and records the contribution
+func mergePermissions(target *RBACRole, source string, permissions RuleApiGroup) {
+	if target.Permissions == nil {
+		target.Permissions = RuleApiGroup{}
+	}
+
+	for _, apiGroup := range sortedKeys(permissions) {
+		if _, exists := target.Permissions[apiGroup]; !exists {
+			target.Permissions[apiGroup] = RuleResource{}
+		}
+		for _, resource := range sortedKeys(permissions[apiGroup]) {
+			if _, exists := target.Permissions[apiGroup][resource]; !exists {
+				target.Permissions[apiGroup][resource] = RuleResourceName{}
+			}
+			for _, resourceName := range sortedKeys(permissions[apiGroup][resource]) {
+				if _, exists := target.Permissions[apiGroup][resource][resourceName]; !exists {
+					target.Permissions[apiGroup][resource][resourceName] = RuleVerb{}
+				}
+
+				verbs := sortedKeys(permissions[apiGroup][resource][resourceName])
+				for _, verb := range verbs {
+					target.Permissions[apiGroup][resource][resourceName][verb] = struct{}{}
+				}
+
+				target.AggregatedFrom = append(target.AggregatedFrom, AggregatedPermission{
+					Source:       source,
+					APIGroup:     apiGroup,
+					Resource:     resource,
+					ResourceName: resourceName,
+					Verbs:        verbs,
+				})
+			}
+		}
+	}
+}
+
+// sortedKeys returns the keys of a map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,230 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLabelSelector_Matches(t *testing.T) {
+	labels := map[string]string{
+		"rbac.authorization.k8s.io/aggregate-to-admin": "true",
+		"tier": "backend",
+	}
+
+	tests := []struct {
+		name     string
+		selector labelSelector
+		want     bool
+	}{
+		{"empty selector", labelSelector{}, true},
+		{"matching labels", labelSelector{matchLabels: map[string]string{"rbac.authorization.k8s.io/aggregate-to-admin": "true"}}, true},
+		{"different value", labelSelector{matchLabels: map[string]string{"tier": "frontend"}}, false},
+		{"missing label", labelSelector{matchLabels: map[string]string{"missing": "true"}}, false},
+		{"In", labelSelector{matchExpressions: []labelSelectorRequirement{{key: "tier", operator: "In", values: []string{"backend", "db"}}}}, true},
+		{"In without label", labelSelector{matchExpressions: []labelSelectorRequirement{{key: "missing", operator: "In", values: []string{"x"}}}}, false},
+		{"NotIn", labelSelector{matchExpressions: []labelSelectorRequirement{{key: "tier", operator: "NotIn", values: []string{"backend"}}}}, false},
+		{"NotIn without label", labelSelector{matchExpressions: []labelSelectorRequirement{{key: "missing", operator: "NotIn", values: []string{"x"}}}}, true},
+		{"Exists", labelSelector{matchExpressions: []labelSelectorRequirement{{key: "tier", operator: "Exists"}}}, true},
+		{"DoesNotExist", labelSelector{matchExpressions: []labelSelectorRequirement{{key: "tier", operator: "DoesNotExist"}}}, false},
+		{"unknown operator", labelSelector{matchExpressions: []labelSelectorRequirement{{key: "tier", operator: "Gt"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.selector.matches(labels); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRBACExtractor_AggregationRule(t *testing.T) {
+	manifest := `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: operator-admin
+aggregationRule:
+  clusterRoleSelectors:
+  - matchLabels:
+      rbac.example.com/aggregate-to-operator-admin: "true"
+rules: []
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: operator-view
+  labels:
+    rbac.example.com/aggregate-to-operator-admin: "true"
+aggregationRule:
+  clusterRoleSelectors:
+  - matchExpressions:
+    - key: rbac.example.com/aggregate-to-operator-view
+      operator: Exists
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: operator-secrets
+  labels:
+    rbac.example.com/aggregate-to-operator-admin: "true"
+rules:
+- apiGroups: [""]
+  resources: ["secrets"]
+  verbs: ["get", "list"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: operator-pods
+  labels:
+    rbac.example.com/aggregate-to-operator-view: "true"
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["list", "watch"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: unrelated
+  labels:
+    rbac.example.com/aggregate-to-something-else: "true"
+rules:
+- apiGroups: ["apps"]
+  resources: ["deployments"]
+  verbs: ["delete"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: operator-admin
+subjects:
+- kind: ServiceAccount
+  name: operator
+  namespace: operator-system
+roleRef:
+  kind: ClusterRole
+  name: operator-admin
+  apiGroup: rbac.authorization.k8s.io`
+
+	e := NewRBACExtractor(nil)
+	docs := bytes.Split([]byte(manifest), []byte("\n---\n"))
+	var manifests []*renderer.Manifest
+	for _, doc := range docs {
+		var content map[string]interface{}
+		if err := yaml.Unmarshal(doc, &content); err == nil {
+			manifests = append(manifests, &renderer.Manifest{Raw: doc, Content: content})
+		}
+	}
+
+	result, err := e.Extract(context.Background(), manifests)
+	if err != nil {
+		t.Fatalf("RBACExtractor.Extract() error = %v", err)
+	}
+
+	rbacMap := result.Data["rbac"].(map[string]map[string]ServiceAccountRBAC)
+	roles := rbacMap["operator"]["operator-system"].Roles
+	if len(roles) != 1 {
+		t.Fatalf("operator has %d roles, want 1", len(roles))
+	}
+	admin := roles[0]
+
+	wantPermissions := RuleApiGroup{
+		"": RuleResource{
+			"pods": RuleResourceName{
+				"": RuleVerb{"list": struct{}{}, "watch": struct{}{}},
+			},
+			"secrets": RuleResourceName{
+				"": RuleVerb{"get": struct{}{}, "list": struct{}{}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(admin.Permissions, wantPermissions) {
+		t.Errorf("aggregated permissions = %v, want %v", admin.Permissions, wantPermissions)
+	}
+
+	// operator-view is aggregated itself, so it contributes the pods permissions of operator-pods too
+	if got, want := admin.AggregationSources("", "pods", ""), []string{"operator-view"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pods sources = %v, want %v", got, want)
+	}
+	if got, want := admin.AggregationSources("", "secrets", ""), []string{"operator-secrets"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("secrets sources = %v, want %v", got, want)
+	}
+	if got := admin.AggregationSources("apps", "deployments", ""); got != nil {
+		t.Errorf("unrelated role should not contribute, got %v", got)
+	}
+
+	// Aggregated rules keep their index in the ClusterRole that defines them, the own rules of
+	// operator-view are replaced by the rules it aggregates
+	wantRules := []PolicyRule{
+		{Index: 0, AggregatedFrom: "operator-pods", APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "watch"}},
+	}
+	if got := admin.RulesGranting("", "pods", ""); !reflect.DeepEqual(got, wantRules) {
+		t.Errorf("pods rules = %+v, want %+v", got, wantRules)
+	}
+
+	for _, role := range result.Data["roles"].([]RBACRole) {
+		if role.Name == "operator-view" {
+			if _, ok := role.Permissions[""]["pods"][""]["watch"]; !ok {
+				t.Errorf("operator-view was not expanded with operator-pods permissions")
+			}
+			if _, ok := role.Permissions[""]["pods"][""]["get"]; ok {
+				t.Errorf("operator-view kept its own rules after aggregation")
+			}
+		}
+		if role.Name == "unrelated" && role.AggregatedFrom != nil {
+			t.Errorf("non aggregated role recorded sources: %v", role.AggregatedFrom)
+		}
+	}
+}
+
+func TestRBACExtractor_AggregationReplacesRules(t *testing.T) {
+	manifests := []*renderer.Manifest{
+		{Content: map[string]interface{}{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRole",
+			"metadata":   map[string]interface{}{"name": "agg"},
+			"aggregationRule": map[string]interface{}{
+				"clusterRoleSelectors": []interface{}{
+					map[string]interface{}{"matchLabels": map[string]interface{}{"aggregate-to-agg": "true"}},
+				},
+			},
+			"rules": []interface{}{
+				map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"secrets"}, "verbs": []interface{}{"*"}},
+			},
+		}},
+		{Content: map[string]interface{}{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRole",
+			"metadata":   map[string]interface{}{"name": "view", "labels": map[string]interface{}{"aggregate-to-agg": "true"}},
+			"rules": []interface{}{
+				map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"configmaps"}, "verbs": []interface{}{"get"}},
+			},
+		}},
+	}
+
+	result, err := NewRBACExtractor(nil).Extract(context.Background(), manifests)
+	if err != nil {
+		t.Fatalf("RBACExtractor.Extract() error = %v", err)
+	}
+	for _, role := range result.Data["roles"].([]RBACRole) {
+		if role.Name != "agg" {
+			continue
+		}
+		want := RuleApiGroup{"": RuleResource{"configmaps": RuleResourceName{"": RuleVerb{"get": struct{}{}}}}}
+		if !reflect.DeepEqual(role.Permissions, want) {
+			t.Errorf("agg permissions = %v, want %v", role.Permissions, want)
+		}
+		if len(role.Rules) != 1 || role.Rules[0].AggregatedFrom != "view" {
+			t.Errorf("agg rules = %+v, want only the rule of view", role.Rules)
+		}
+	}
+}
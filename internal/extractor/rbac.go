@@ -3,8 +3,11 @@ package extractor
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
 
 	"github.com/alevsk/rbac-scope/internal/renderer"
+	"github.com/alevsk/rbac-scope/internal/types"
 )
 
 // BindingSubject represents a Kubernetes subject
@@ -14,13 +17,22 @@ type BindingSubject struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+// RoleRef identifies the Role or ClusterRole granted by a binding
+type RoleRef struct {
+	Kind     string `json:"kind"` // Role or ClusterRole
+	APIGroup string `json:"apiGroup,omitempty"`
+	Name     string `json:"name"`
+}
+
 // RBACBinding represents a RoleBinding or ClusterRoleBinding
 type RBACBinding struct {
 	Type      string           `json:"type"` // RoleBinding or ClusterRoleBinding
 	Name      string           `json:"name"`
 	Namespace string           `json:"namespace,omitempty"`
-	Subjects  []BindingSubject `json:"subjects"` // List of subject names (ServiceAccounts)
-	RoleRef   string           `json:"roleRef"`  // Name of the Role/ClusterRole being referenced
+	Subjects  []BindingSubject `json:"subjects"` // ServiceAccount, User and Group subjects
+	RoleRef   RoleRef          `json:"roleRef"`  // Role/ClusterRole being referenced
+	// Provenance is the file, document and line the binding was read from
+	Provenance *types.Provenance `json:"provenance,omitempty"`
 }
 
 // RuleVerb represents a permission verb (get, list, etc.)
@@ -41,9 +53,86 @@ type RBACRole struct {
 	Name        string       `json:"name"`
 	Namespace   string       `json:"namespace,omitempty"`
 	Permissions RuleApiGroup `json:"permissions,omitempty"` // Permissions by API group, resource, resource name and verb
+	// Rules are the rules of the role as written in its manifest, Permissions flattens them
+	Rules []PolicyRule `json:"rules,omitempty"`
+	// AggregatedFrom records the ClusterRoles that contributed permissions through an aggregationRule
+	AggregatedFrom []AggregatedPermission `json:"aggregatedFrom,omitempty"`
+	// Subchart is the helm subchart that rendered the role, empty for the parent chart
+	Subchart string `json:"subchart,omitempty"`
+	// Provenance is the file, document and line the role was read from
+	Provenance *types.Provenance `json:"provenance,omitempty"`
+	// BindingProvenance is where the binding that granted the role to a subject was read from,
+	// it is only set on the roles of a ServiceAccountRBAC
+	BindingProvenance *types.Provenance `json:"bindingProvenance,omitempty"`
+}
+
+// PolicyRule is a rule of a Role or ClusterRole as written in its manifest
+type PolicyRule struct {
+	Index int `json:"index"` // Position of the rule in the rules of the role, starting at 0
+	// AggregatedFrom is the ClusterRole that defines the rule when it was aggregated into the role
+	AggregatedFrom  string   `json:"aggregatedFrom,omitempty"`
+	APIGroups       []string `json:"apiGroups,omitempty"`
+	Resources       []string `json:"resources,omitempty"`
+	ResourceNames   []string `json:"resourceNames,omitempty"`
+	NonResourceURLs []string `json:"nonResourceURLs,omitempty"`
+	Verbs           []string `json:"verbs,omitempty"`
+}
+
+// Grants reports whether the rule grants permissions on the resource name of the resource
+// in apiGroup, as flattened into RBACRole.Permissions. An empty resource name stands for
+// a rule without resourceNames.
+func (p PolicyRule) Grants(apiGroup, resource, resourceName string) bool {
+	if len(p.Verbs) == 0 {
+		return false
+	}
+	resourceNames := p.ResourceNames
+	if resourceNames == nil {
+		resourceNames = []string{""}
+	}
+	return slices.Contains(p.APIGroups, apiGroup) && slices.Contains(p.Resources, resource) && slices.Contains(resourceNames, resourceName)
+}
+
+// AggregatedPermission describes the verbs a ClusterRole contributed to an aggregated ClusterRole
+type AggregatedPermission struct {
+	Source       string   `json:"source"` // Name of the contributing ClusterRole
+	APIGroup     string   `json:"apiGroup"`
+	Resource     string   `json:"resource"`
+	ResourceName string   `json:"resourceName,omitempty"`
+	Verbs        []string `json:"verbs"`
+}
+
+// AggregationSources returns the ClusterRoles that contributed permissions on the given
+// resource through an aggregationRule, or nil if the role is not aggregated
+func (r RBACRole) AggregationSources(apiGroup, resource, resourceName string) []string {
+	var sources []string
+	for _, p := range r.AggregatedFrom {
+		if p.APIGroup == apiGroup && p.Resource == resource && p.ResourceName == resourceName {
+			sources = append(sources, p.Source)
+		}
+	}
+	return sources
+}
+
+// RulesGranting returns the rules that grant permissions on the resource name of the resource
+// in apiGroup, including the rules aggregated from other ClusterRoles
+func (r RBACRole) RulesGranting(apiGroup, resource, resourceName string) []PolicyRule {
+	var rules []PolicyRule
+	for _, rule := range r.Rules {
+		if rule.Grants(apiGroup, resource, resourceName) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// BoundToNamespace reports whether a ClusterRole was granted through a RoleBinding,
+// which limits its permissions to the namespace of the binding
+func (r RBACRole) BoundToNamespace() bool {
+	return r.Type == "ClusterRole" && r.Namespace != "*"
 }
 
-// ServiceAccountRBAC represents all RBAC information for a service account
+// ServiceAccountRBAC represents all RBAC information for a service account, it is also
+// used for the roles bound to User and Group subjects
 type ServiceAccountRBAC struct {
 	Roles []RBACRole `json:"roles"` // Roles bound to this service account
 }
@@ -73,6 +162,10 @@ func (e *RBACExtractor) Extract(ctx context.Context, manifests []*renderer.Manif
 	result := NewResult()
 	var roles []RBACRole
 	var bindings []RBACBinding
+	var serviceAccounts []BindingSubject
+	// Labels and aggregation selectors of ClusterRoles, used to expand aggregationRules
+	clusterRoleLabels := make(map[string]map[string]string)
+	aggregationSelectors := make(map[string][]labelSelector)
 
 	for _, manifest := range manifests {
 		// Get kind and metadata
@@ -111,10 +204,12 @@ func (e *RBACExtractor) Extract(ctx context.Context, manifests []*renderer.Manif
 				Namespace:   namespace,
 				Permissions: RuleApiGroup{},
 			}
+			rbacRole.Subchart, _ = manifest.Metadata["subchart"].(string)
+			rbacRole.Provenance = manifest.Provenance()
 
 			// Extract rules
 			if rules, ok := manifest.Content["rules"].([]interface{}); ok {
-				for _, r := range rules {
+				for idx, r := range rules {
 					rule, ok := r.(map[string]interface{})
 					if !ok {
 						continue
@@ -125,6 +220,16 @@ func (e *RBACExtractor) Extract(ctx context.Context, manifests []*renderer.Manif
 					resourceNames := toStringSlice(rule["resourceNames"])
 					verbs := toStringSlice(rule["verbs"])
 
+					// Keep the rule as written so permissions can be traced back to it
+					rbacRole.Rules = append(rbacRole.Rules, PolicyRule{
+						Index:           idx,
+						APIGroups:       apiGroups,
+						Resources:       resources,
+						ResourceNames:   resourceNames,
+						NonResourceURLs: toStringSlice(rule["nonResourceURLs"]),
+						Verbs:           verbs,
+					})
+
 					if resourceNames == nil {
 						resourceNames = []string{""}
 					}
@@ -165,8 +270,17 @@ func (e *RBACExtractor) Extract(ctx context.Context, manifests []*renderer.Manif
 
 				}
 			}
+			if kind == "ClusterRole" {
+				clusterRoleLabels[name] = toStringMap(metadata["labels"])
+				if rule, ok := manifest.Content["aggregationRule"].(map[string]interface{}); ok {
+					aggregationSelectors[name] = parseLabelSelectors(rule["clusterRoleSelectors"])
+				}
+			}
 			roles = append(roles, rbacRole)
 
+		case "ServiceAccount":
+			serviceAccounts = append(serviceAccounts, BindingSubject{Kind: kind, Name: name, Namespace: namespace})
+
 		case "RoleBinding", "ClusterRoleBinding":
 			// Extract subjects
 			var subjects []BindingSubject
@@ -177,38 +291,49 @@ func (e *RBACExtractor) Extract(ctx context.Context, manifests []*renderer.Manif
 						continue
 					}
 
-					if subjectKind, ok := subject["kind"].(string); ok && subjectKind == "ServiceAccount" {
-						if subjectName, ok := subject["name"].(string); ok {
-							if subjectNamespace, ok := subject["namespace"].(string); ok {
-								subjects = append(subjects, BindingSubject{
-									Kind:      subjectKind,
-									Name:      subjectName,
-									Namespace: subjectNamespace,
-								})
-							}
+					subjectKind, _ := subject["kind"].(string)
+					subjectName, _ := subject["name"].(string)
+					if subjectKind == "" || subjectName == "" {
+						continue
+					}
+					subjectNamespace, _ := subject["namespace"].(string)
+					if subjectKind == "ServiceAccount" && subjectNamespace == "" {
+						// ServiceAccount subjects of a RoleBinding default to the binding namespace
+						if kind == "ClusterRoleBinding" {
+							continue
 						}
+						subjectNamespace = namespace
 					}
+					subjects = append(subjects, BindingSubject{
+						Kind:      subjectKind,
+						Name:      subjectName,
+						Namespace: subjectNamespace,
+					})
 				}
 			}
 
 			// Extract roleRef
-			roleRef := ""
+			var roleRef RoleRef
 			if ref, ok := manifest.Content["roleRef"].(map[string]interface{}); ok {
-				if refName, ok := ref["name"].(string); ok {
-					roleRef = refName
-				}
+				roleRef.Kind, _ = ref["kind"].(string)
+				roleRef.APIGroup, _ = ref["apiGroup"].(string)
+				roleRef.Name, _ = ref["name"].(string)
 			}
 
 			bindings = append(bindings, RBACBinding{
-				Type:      kind,
-				Name:      name,
-				Namespace: namespace,
-				Subjects:  subjects,
-				RoleRef:   roleRef,
+				Type:       kind,
+				Name:       name,
+				Namespace:  namespace,
+				Subjects:   subjects,
+				RoleRef:    roleRef,
+				Provenance: manifest.Provenance(),
 			})
 		}
 	}
 
+	// Expand aggregated ClusterRoles before they are bound to any subject
+	aggregateClusterRoles(roles, clusterRoleLabels, aggregationSelectors)
+
 	// Create a map to store ServiceAccountRBAC by service account name and namespace
 	rbacMap := make(map[string]map[string]ServiceAccountRBAC)
 
@@ -219,56 +344,60 @@ func (e *RBACExtractor) Extract(ctx context.Context, manifests []*renderer.Manif
 		if role.Type == "ClusterRole" {
 			clusterRolesByName[role.Name] = role
 		} else {
-			rolesByName[role.Name] = make(map[string]RBACRole)
+			if _, exists := rolesByName[role.Name]; !exists {
+				rolesByName[role.Name] = make(map[string]RBACRole)
+			}
 			rolesByName[role.Name][role.Namespace] = role
 		}
 	}
 
-	// Process bindings to organize roles by service account
-	for _, binding := range bindings {
-		for _, subject := range binding.Subjects {
-			// Initialize maps if they don't exist
-			if _, exists := rbacMap[subject.Name]; !exists {
-				rbacMap[subject.Name] = make(map[string]ServiceAccountRBAC)
-			}
+	// Service accounts that group subjects such as system:serviceaccounts expand to
+	identities := serviceAccountIdentities(serviceAccounts, bindings)
 
-			// Initialize the ServiceAccountRBAC if it doesn't exist
-			if _, exists := rbacMap[subject.Name][subject.Namespace]; !exists {
-				rbacMap[subject.Name][subject.Namespace] = ServiceAccountRBAC{}
-			}
-			saRBAC := rbacMap[subject.Name][subject.Namespace]
-
-			// Add the referenced role based on the binding type
-			var role RBACRole
-			var exists bool
-			if binding.Type == "ClusterRoleBinding" {
-				role, exists = clusterRolesByName[binding.RoleRef]
-			} else {
-				role, exists = rolesByName[binding.RoleRef][binding.Namespace]
-			}
+	// Users and groups are keyed by name and the namespace the binding applies to
+	userMap := make(map[string]map[string]ServiceAccountRBAC)
+	groupMap := make(map[string]map[string]ServiceAccountRBAC)
+
+	// Process bindings to organize roles by subject
+	for _, binding := range bindings {
+		// Resolve the referenced role based on the binding type
+		var role RBACRole
+		var exists bool
+		switch {
+		case binding.Type == "ClusterRoleBinding":
+			role, exists = clusterRolesByName[binding.RoleRef.Name]
+		case binding.RoleRef.Kind == "ClusterRole":
+			// A RoleBinding can grant a ClusterRole, but only within the binding namespace
+			role, exists = clusterRolesByName[binding.RoleRef.Name]
+			role.Namespace = binding.Namespace
+		default:
+			role, exists = rolesByName[binding.RoleRef.Name][binding.Namespace]
+		}
+		role.BindingProvenance = binding.Provenance
 
-			if exists {
-				// Check if the role is already added
-				alreadyAddedRole := false
-				for _, r := range saRBAC.Roles {
-					if r.Name == role.Name && r.Type == role.Type {
-						alreadyAddedRole = true
-						break
+		for _, subject := range binding.Subjects {
+			switch subject.Kind {
+			case "ServiceAccount":
+				grantRole(rbacMap, subject.Name, subject.Namespace, role, exists)
+			case "User":
+				grantRole(userMap, subject.Name, binding.Namespace, role, exists)
+			case "Group":
+				grantRole(groupMap, subject.Name, binding.Namespace, role, exists)
+				// Well-known service account groups also grant the role to every matching identity
+				for _, sa := range identities {
+					if subject.Name == serviceAccountsGroup || subject.Name == serviceAccountsGroup+":"+sa.Namespace {
+						grantRole(rbacMap, sa.Name, sa.Namespace, role, exists)
 					}
 				}
-				if !alreadyAddedRole {
-					saRBAC.Roles = append(saRBAC.Roles, role)
-				}
 			}
-
-			// Update the map
-			rbacMap[subject.Name][subject.Namespace] = saRBAC
 		}
 	}
 
 	result.Data["roles"] = roles
 	result.Data["bindings"] = bindings
 	result.Data["rbac"] = rbacMap
+	result.Data["users"] = userMap
+	result.Data["groups"] = groupMap
 	// Update metadata
 	result.Metadata["roleCount"] = len(roles)
 	result.Metadata["bindingCount"] = len(bindings)
@@ -276,6 +405,64 @@ func (e *RBACExtractor) Extract(ctx context.Context, manifests []*renderer.Manif
 	return result, nil
 }
 
+// serviceAccountsGroup is the group every service account belongs to, service accounts
+// of a namespace also belong to serviceAccountsGroup:<namespace>
+const serviceAccountsGroup = "system:serviceaccounts"
+
+// serviceAccountIdentities returns the unique service accounts declared as manifests or
+// referenced as binding subjects, sorted by namespace and name
+func serviceAccountIdentities(serviceAccounts []BindingSubject, bindings []RBACBinding) []BindingSubject {
+	seen := make(map[BindingSubject]struct{})
+	var identities []BindingSubject
+	add := func(sa BindingSubject) {
+		if _, ok := seen[sa]; ok {
+			return
+		}
+		seen[sa] = struct{}{}
+		identities = append(identities, sa)
+	}
+	for _, sa := range serviceAccounts {
+		add(sa)
+	}
+	for _, binding := range bindings {
+		for _, subject := range binding.Subjects {
+			if subject.Kind == "ServiceAccount" {
+				add(subject)
+			}
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		if identities[i].Namespace != identities[j].Namespace {
+			return identities[i].Namespace < identities[j].Namespace
+		}
+		return identities[i].Name < identities[j].Name
+	})
+	return identities
+}
+
+// grantRole records role for the subject name in namespace, the subject entry is created
+// even when the referenced role is not part of the manifests
+func grantRole(subjects map[string]map[string]ServiceAccountRBAC, name, namespace string, role RBACRole, exists bool) {
+	if _, ok := subjects[name]; !ok {
+		subjects[name] = make(map[string]ServiceAccountRBAC)
+	}
+	saRBAC := subjects[name][namespace]
+	if exists {
+		// Check if the role is already added
+		alreadyAddedRole := false
+		for _, r := range saRBAC.Roles {
+			if r.Name == role.Name && r.Type == role.Type && r.Namespace == role.Namespace {
+				alreadyAddedRole = true
+				break
+			}
+		}
+		if !alreadyAddedRole {
+			saRBAC.Roles = append(saRBAC.Roles, role)
+		}
+	}
+	subjects[name][namespace] = saRBAC
+}
+
 // Validate checks if the manifests can be processed
 func (e *RBACExtractor) Validate(manifests []*renderer.Manifest) error {
 	if len(manifests) == 0 {
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/alevsk/rbac-scope/internal/renderer"
+	"github.com/alevsk/rbac-scope/internal/types"
 	"gopkg.in/yaml.v3"
 )
 
@@ -71,6 +74,7 @@ rules:
 						},
 					},
 				},
+				Rules: []PolicyRule{{Index: 0, APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "watch"}}},
 			},
 		},
 		{
@@ -100,6 +104,7 @@ rules:
 						},
 					},
 				},
+				Rules: []PolicyRule{{Index: 0, APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "watch"}}},
 			},
 		},
 		{
@@ -150,13 +155,14 @@ roleRef:
 						},
 					},
 				},
+				Rules: []PolicyRule{{Index: 0, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"pod1", "pod2"}, Verbs: []string{"get", "list", "watch"}}},
 			},
 			wantBinding: &RBACBinding{
 				Type:      "RoleBinding",
 				Name:      "read-pods",
 				Namespace: "default",
 				Subjects:  []BindingSubject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
-				RoleRef:   "pod-reader",
+				RoleRef:   RoleRef{Kind: "Role", APIGroup: "rbac.authorization.k8s.io", Name: "pod-reader"},
 			},
 		},
 		{
@@ -200,6 +206,7 @@ roleRef:
 						},
 					},
 				},
+				Rules: []PolicyRule{{Index: 0, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"pod1"}, Verbs: []string{"get", "list", "watch"}}},
 			},
 		},
 		{
@@ -431,3 +438,334 @@ roleRef:
 		t.Errorf("another-sa has %d roles, want 1", len(anotherSARBAC.Roles))
 	}
 }
+
+func TestRBACExtractor_RoleBindingToClusterRole(t *testing.T) {
+	manifest := `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: secret-reader
+rules:
+- apiGroups: [""]
+  resources: ["secrets"]
+  verbs: ["get", "list"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: read-secrets
+  namespace: operator
+subjects:
+- kind: ServiceAccount
+  name: test-sa
+  namespace: operator
+roleRef:
+  kind: ClusterRole
+  name: secret-reader
+  apiGroup: rbac.authorization.k8s.io
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: read-secrets-everywhere
+subjects:
+- kind: ServiceAccount
+  name: test-sa
+  namespace: operator
+roleRef:
+  kind: ClusterRole
+  name: secret-reader
+  apiGroup: rbac.authorization.k8s.io`
+
+	e := NewRBACExtractor(nil)
+	docs := bytes.Split([]byte(manifest), []byte("\n---\n"))
+	var manifests []*renderer.Manifest
+	for _, doc := range docs {
+		var content map[string]interface{}
+		if err := yaml.Unmarshal(doc, &content); err == nil {
+			manifests = append(manifests, &renderer.Manifest{Raw: doc, Content: content})
+		}
+	}
+
+	result, err := e.Extract(context.Background(), manifests)
+	if err != nil {
+		t.Fatalf("RBACExtractor.Extract() error = %v", err)
+	}
+
+	bindings := result.Data["bindings"].([]RBACBinding)
+	wantRef := RoleRef{Kind: "ClusterRole", APIGroup: "rbac.authorization.k8s.io", Name: "secret-reader"}
+	if bindings[0].RoleRef != wantRef {
+		t.Errorf("RoleBinding roleRef = %+v, want %+v", bindings[0].RoleRef, wantRef)
+	}
+
+	rbacMap := result.Data["rbac"].(map[string]map[string]ServiceAccountRBAC)
+	roles := rbacMap["test-sa"]["operator"].Roles
+	// The same ClusterRole is granted once in the operator namespace and once cluster-wide
+	if len(roles) != 2 {
+		t.Fatalf("test-sa has %d roles, want 2", len(roles))
+	}
+
+	namespaces := map[string]bool{}
+	for _, role := range roles {
+		if role.Type != "ClusterRole" || role.Name != "secret-reader" {
+			t.Errorf("unexpected role %s/%s", role.Type, role.Name)
+		}
+		if _, ok := role.Permissions[""]["secrets"][""]["list"]; !ok {
+			t.Errorf("role bound in %q is missing the ClusterRole permissions", role.Namespace)
+		}
+		namespaces[role.Namespace] = role.BoundToNamespace()
+	}
+	if bound, ok := namespaces["operator"]; !ok || !bound {
+		t.Errorf("expected ClusterRole bound to the operator namespace, got %v", namespaces)
+	}
+	if bound, ok := namespaces["*"]; !ok || bound {
+		t.Errorf("expected cluster-wide ClusterRole, got %v", namespaces)
+	}
+}
+
+func TestRBACExtractor_UserAndGroupSubjects(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: app
+  namespace: team-a
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: worker
+  namespace: team-b
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: pod-reader
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: authenticated-readers
+subjects:
+- kind: Group
+  name: system:authenticated
+  apiGroup: rbac.authorization.k8s.io
+- kind: User
+  name: alice
+  apiGroup: rbac.authorization.k8s.io
+roleRef:
+  kind: ClusterRole
+  name: pod-reader
+  apiGroup: rbac.authorization.k8s.io
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: team-a-readers
+  namespace: team-a
+subjects:
+- kind: Group
+  name: system:serviceaccounts:team-a
+  apiGroup: rbac.authorization.k8s.io
+- kind: ServiceAccount
+  name: implicit
+roleRef:
+  kind: ClusterRole
+  name: pod-reader
+  apiGroup: rbac.authorization.k8s.io
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: all-service-accounts
+subjects:
+- kind: Group
+  name: system:serviceaccounts
+  apiGroup: rbac.authorization.k8s.io
+- kind: ServiceAccount
+  name: missing-namespace
+roleRef:
+  kind: ClusterRole
+  name: pod-reader
+  apiGroup: rbac.authorization.k8s.io`
+
+	e := NewRBACExtractor(nil)
+	docs := bytes.Split([]byte(manifest), []byte("\n---\n"))
+	var manifests []*renderer.Manifest
+	for _, doc := range docs {
+		var content map[string]interface{}
+		if err := yaml.Unmarshal(doc, &content); err == nil {
+			manifests = append(manifests, &renderer.Manifest{Raw: doc, Content: content})
+		}
+	}
+
+	result, err := e.Extract(context.Background(), manifests)
+	if err != nil {
+		t.Fatalf("RBACExtractor.Extract() error = %v", err)
+	}
+
+	bindings := result.Data["bindings"].([]RBACBinding)
+	if got := bindings[1].Subjects[1]; got != (BindingSubject{Kind: "ServiceAccount", Name: "implicit", Namespace: "team-a"}) {
+		t.Errorf("ServiceAccount subject without namespace = %+v, want the binding namespace", got)
+	}
+	if len(bindings[2].Subjects) != 1 {
+		t.Errorf("ClusterRoleBinding subjects = %+v, want the ServiceAccount without namespace dropped", bindings[2].Subjects)
+	}
+
+	users := result.Data["users"].(map[string]map[string]ServiceAccountRBAC)
+	if roles := users["alice"]["*"].Roles; len(roles) != 1 || roles[0].Name != "pod-reader" {
+		t.Errorf("user alice roles = %+v, want pod-reader cluster-wide", roles)
+	}
+
+	groups := result.Data["groups"].(map[string]map[string]ServiceAccountRBAC)
+	if roles := groups["system:authenticated"]["*"].Roles; len(roles) != 1 {
+		t.Errorf("group system:authenticated roles = %+v, want 1", roles)
+	}
+	if roles := groups["system:serviceaccounts:team-a"]["team-a"].Roles; len(roles) != 1 || !roles[0].BoundToNamespace() {
+		t.Errorf("group system:serviceaccounts:team-a roles = %+v, want pod-reader bound to team-a", roles)
+	}
+
+	rbacMap := result.Data["rbac"].(map[string]map[string]ServiceAccountRBAC)
+	tests := []struct {
+		name       string
+		namespace  string
+		namespaces []string
+	}{
+		// Bound through system:serviceaccounts:team-a and system:serviceaccounts
+		{"app", "team-a", []string{"team-a", "*"}},
+		{"implicit", "team-a", []string{"team-a", "*"}},
+		// Only bound through system:serviceaccounts
+		{"worker", "team-b", []string{"*"}},
+	}
+	for _, tt := range tests {
+		roles := rbacMap[tt.name][tt.namespace].Roles
+		var got []string
+		for _, role := range roles {
+			got = append(got, role.Namespace)
+		}
+		sort.Strings(got)
+		want := append([]string(nil), tt.namespaces...)
+		sort.Strings(want)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s/%s role namespaces = %v, want %v", tt.namespace, tt.name, got, want)
+		}
+	}
+	if _, ok := rbacMap["missing-namespace"]; ok {
+		t.Error("ServiceAccount subject without namespace in a ClusterRoleBinding should be ignored")
+	}
+}
+
+func TestRBACExtractor_Subchart(t *testing.T) {
+	role := map[string]interface{}{
+		"kind":     "Role",
+		"metadata": map[string]interface{}{"name": "metrics", "namespace": "monitoring"},
+		"rules": []interface{}{
+			map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods"}, "verbs": []interface{}{"list"}},
+		},
+	}
+	manifests := []*renderer.Manifest{
+		{Name: "parent/charts/metrics/templates/role.yaml-1", Content: role, Metadata: map[string]interface{}{"subchart": "metrics"}},
+		{Name: "role.yaml-1", Content: map[string]interface{}{"kind": "ClusterRole", "metadata": map[string]interface{}{"name": "parent"}}},
+	}
+
+	result, err := NewRBACExtractor(nil).Extract(context.Background(), manifests)
+	if err != nil {
+		t.Fatalf("RBACExtractor.Extract() error = %v", err)
+	}
+	roles := result.Data["roles"].([]RBACRole)
+	if roles[0].Subchart != "metrics" || roles[1].Subchart != "" {
+		t.Errorf("subcharts = %q, %q, want metrics and none", roles[0].Subchart, roles[1].Subchart)
+	}
+}
+
+func TestRBACExtractor_Provenance(t *testing.T) {
+	manifests := []*renderer.Manifest{
+		{
+			Name: "reader",
+			Content: map[string]interface{}{
+				"kind":     "ClusterRole",
+				"metadata": map[string]interface{}{"name": "reader"},
+				"rules": []interface{}{
+					map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods"}, "verbs": []interface{}{"list"}},
+				},
+			},
+			Metadata: map[string]interface{}{"file": "deploy/role.yaml", "docNum": 1, "line": 1},
+		},
+		{
+			Name: "operator/templates/binding.yaml-2",
+			Content: map[string]interface{}{
+				"kind":     "ClusterRoleBinding",
+				"metadata": map[string]interface{}{"name": "reader"},
+				"subjects": []interface{}{
+					map[string]interface{}{"kind": "ServiceAccount", "name": "operator", "namespace": "operators"},
+				},
+				"roleRef": map[string]interface{}{"kind": "ClusterRole", "name": "reader"},
+			},
+			Metadata: map[string]interface{}{"template": "operator/templates/binding.yaml", "docNum": 2},
+		},
+	}
+
+	result, err := NewRBACExtractor(nil).Extract(context.Background(), manifests)
+	if err != nil {
+		t.Fatalf("RBACExtractor.Extract() error = %v", err)
+	}
+	wantRole := &types.Provenance{File: "deploy/role.yaml", Document: 1, Line: 1}
+	wantBinding := &types.Provenance{File: "operator/templates/binding.yaml", Document: 2}
+	if bindings := result.Data["bindings"].([]RBACBinding); !reflect.DeepEqual(bindings[0].Provenance, wantBinding) {
+		t.Errorf("binding provenance = %+v, want %+v", bindings[0].Provenance, wantBinding)
+	}
+	roles := result.Data["rbac"].(map[string]map[string]ServiceAccountRBAC)["operator"]["operators"].Roles
+	if len(roles) != 1 {
+		t.Fatalf("roles = %+v, want the reader ClusterRole", roles)
+	}
+	if !reflect.DeepEqual(roles[0].Provenance, wantRole) || !reflect.DeepEqual(roles[0].BindingProvenance, wantBinding) {
+		t.Errorf("granted role provenance = %+v, binding %+v, want %+v and %+v", roles[0].Provenance, roles[0].BindingProvenance, wantRole, wantBinding)
+	}
+}
+
+func TestRBACRole_RulesGranting(t *testing.T) {
+	manifest := map[string]interface{}{
+		"kind":     "ClusterRole",
+		"metadata": map[string]interface{}{"name": "operator"},
+		"rules": []interface{}{
+			map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods"}, "verbs": []interface{}{"get", "list"}},
+			map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods", "secrets"}, "verbs": []interface{}{"list", "watch"}},
+			map[string]interface{}{"nonResourceURLs": []interface{}{"/metrics"}, "verbs": []interface{}{"get"}},
+			map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"secrets"}, "resourceNames": []interface{}{"token"}, "verbs": []interface{}{"get"}},
+		},
+	}
+	result, err := NewRBACExtractor(nil).Extract(context.Background(), []*renderer.Manifest{{Name: "operator", Content: manifest}})
+	if err != nil {
+		t.Fatalf("RBACExtractor.Extract() error = %v", err)
+	}
+	role := result.Data["roles"].([]RBACRole)[0]
+	if len(role.Rules) != 4 || role.Rules[2].NonResourceURLs[0] != "/metrics" {
+		t.Fatalf("rules = %+v, want the 4 rules of the role", role.Rules)
+	}
+
+	tests := []struct {
+		name         string
+		apiGroup     string
+		resource     string
+		resourceName string
+		want         []int
+	}{
+		{name: "overlapping rules", resource: "pods", want: []int{0, 1}},
+		{name: "single rule", resource: "secrets", want: []int{1}},
+		{name: "resource name", resource: "secrets", resourceName: "token", want: []int{3}},
+		{name: "other api group", apiGroup: "apps", resource: "pods"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, rule := range role.RulesGranting(tt.apiGroup, tt.resource, tt.resourceName) {
+				got = append(got, rule.Index)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RulesGranting() indexes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
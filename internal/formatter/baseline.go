@@ -0,0 +1,285 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// baselineDateLayout is the layout of suppression expiry dates
+const baselineDateLayout = "2006-01-02"
+
+// Placeholders written into generated suppressions when no owner or justification is given. A
+// baseline keeping them is rejected until they are replaced by a reviewer.
+const (
+	PlaceholderOwner         = "TODO"
+	PlaceholderJustification = "TODO: explain why these permissions are accepted"
+)
+
+// Baseline holds the findings that were reviewed and accepted
+type Baseline struct {
+	Suppressions []Suppression `json:"suppressions" yaml:"suppressions"`
+}
+
+// Suppression accepts a finding. Permission findings are matched by subject, namespace, role
+// type and name, API group, resource and one of their matched rule IDs; combination findings
+// leave the role, API group and resource empty. A suppression is valid until the end of its
+// expiry date.
+type Suppression struct {
+	SubjectName   string `json:"subjectName" yaml:"subjectName"`
+	SubjectKind   string `json:"subjectKind,omitempty" yaml:"subjectKind,omitempty"` // Empty for service accounts
+	Namespace     string `json:"namespace" yaml:"namespace"`
+	RoleType      string `json:"roleType,omitempty" yaml:"roleType,omitempty"` // Empty matches both role types
+	RoleName      string `json:"roleName,omitempty" yaml:"roleName,omitempty"`
+	APIGroup      string `json:"apiGroup,omitempty" yaml:"apiGroup,omitempty"`
+	Resource      string `json:"resource,omitempty" yaml:"resource,omitempty"`
+	RuleID        int64  `json:"ruleId" yaml:"ruleId"`
+	Justification string `json:"justification" yaml:"justification"`
+	Owner         string `json:"owner" yaml:"owner"`
+	Expires       string `json:"expires,omitempty" yaml:"expires,omitempty"` // YYYY-MM-DD
+}
+
+// UnmarshalYAML reads a suppression, accepting the serviceAccountName key of older baselines
+// in place of subjectName
+func (s *Suppression) UnmarshalYAML(value *yaml.Node) error {
+	type plain Suppression
+	var suppression struct {
+		plain              `yaml:",inline"`
+		ServiceAccountName string `yaml:"serviceAccountName"`
+	}
+	if err := value.Decode(&suppression); err != nil {
+		return err
+	}
+	*s = Suppression(suppression.plain)
+	if s.SubjectName == "" {
+		s.SubjectName = suppression.ServiceAccountName
+	}
+	return nil
+}
+
+// LoadBaseline reads and validates a baseline file
+func LoadBaseline(path string) (*Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline file: %w", err)
+	}
+
+	var baseline Baseline
+	if err := yaml.Unmarshal(content, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline file %s: %w", path, err)
+	}
+	for i, s := range baseline.Suppressions {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("invalid suppression %d in %s: %w", i, path, err)
+		}
+	}
+	return &baseline, nil
+}
+
+// WriteBaseline writes baseline to path as YAML
+func WriteBaseline(path string, baseline *Baseline) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(baseline); err != nil {
+		return fmt.Errorf("failed to marshal baseline: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write baseline file: %w", err)
+	}
+	return nil
+}
+
+// NewBaseline creates a baseline accepting every unsuppressed finding in data. Permission findings
+// are keyed on their highest risk rule. Justification and owner are set on every suppression and
+// are meant to be reviewed before the baseline is committed.
+func NewBaseline(data ParsedData, justification, owner string) *Baseline {
+	baseline := &Baseline{Suppressions: []Suppression{}}
+	seen := make(map[Suppression]bool)
+	add := func(s Suppression) {
+		if seen[s] {
+			return
+		}
+		seen[s] = true
+		baseline.Suppressions = append(baseline.Suppressions, s)
+	}
+
+	for _, entry := range data.RBACData {
+		if entry.Suppression != nil || len(entry.MatchedRiskRules) == 0 {
+			continue
+		}
+		add(Suppression{
+			SubjectName:   entry.SubjectName,
+			SubjectKind:   baselineSubjectKind(entry.SubjectKind),
+			Namespace:     entry.Namespace,
+			RoleType:      entry.RoleType,
+			RoleName:      entry.RoleName,
+			APIGroup:      entry.APIGroup,
+			Resource:      entry.Resource,
+			RuleID:        entry.MatchedRiskRules[0].ID,
+			Justification: justification,
+			Owner:         owner,
+		})
+	}
+	for _, combination := range data.CombinationData {
+		if combination.Suppression != nil {
+			continue
+		}
+		add(Suppression{
+			SubjectName:   combination.SubjectName,
+			SubjectKind:   baselineSubjectKind(combination.SubjectKind),
+			Namespace:     combination.Namespace,
+			RuleID:        combination.RuleID,
+			Justification: justification,
+			Owner:         owner,
+		})
+	}
+
+	sort.SliceStable(baseline.Suppressions, func(i, j int) bool {
+		a, b := baseline.Suppressions[i], baseline.Suppressions[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.SubjectName != b.SubjectName {
+			return a.SubjectName < b.SubjectName
+		}
+		return a.RuleID < b.RuleID
+	})
+	return baseline
+}
+
+// Apply marks the findings in data accepted by a suppression that has not expired at now,
+// expired suppressions are listed in data.ExpiredSuppressions
+func (b *Baseline) Apply(data *ParsedData, now time.Time) {
+	for i := range b.Suppressions {
+		s := &b.Suppressions[i]
+		if s.Expired(now) {
+			data.ExpiredSuppressions = append(data.ExpiredSuppressions, *s)
+			continue
+		}
+		for j := range data.RBACData {
+			if data.RBACData[j].Suppression == nil && s.matchesEntry(data.RBACData[j]) {
+				data.RBACData[j].Suppression = s
+			}
+		}
+		for j := range data.CombinationData {
+			if data.CombinationData[j].Suppression == nil && s.matchesCombination(data.CombinationData[j]) {
+				data.CombinationData[j].Suppression = s
+			}
+		}
+	}
+}
+
+// Expired reports whether the suppression expiry date is before now. Suppressions
+// without a valid expiry date never expire.
+func (s Suppression) Expired(now time.Time) bool {
+	if s.Expires == "" {
+		return false
+	}
+	expires, err := time.Parse(baselineDateLayout, s.Expires)
+	if err != nil {
+		return false
+	}
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// validate checks the required fields of a suppression
+func (s Suppression) validate() error {
+	if s.SubjectName == "" {
+		return fmt.Errorf("subjectName is required")
+	}
+	if s.RuleID == 0 {
+		return fmt.Errorf("ruleId is required")
+	}
+	if s.Justification == "" {
+		return fmt.Errorf("justification is required")
+	}
+	if s.Justification == PlaceholderJustification {
+		return fmt.Errorf("justification is a placeholder, explain why the finding is accepted")
+	}
+	if s.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if s.Owner == PlaceholderOwner {
+		return fmt.Errorf("owner is a placeholder, set the owner of the suppression")
+	}
+	if s.Expires != "" {
+		if _, err := time.Parse(baselineDateLayout, s.Expires); err != nil {
+			return fmt.Errorf("invalid expires date %q, expected YYYY-MM-DD", s.Expires)
+		}
+	}
+	return nil
+}
+
+// matchesEntry reports whether the suppression accepts a permission finding
+func (s Suppression) matchesEntry(entry SARoleBindingEntry) bool {
+	if !s.matchesSubject(entry.SubjectName, entry.SubjectKind, entry.Namespace) ||
+		(s.RoleType != "" && s.RoleType != entry.RoleType) || s.RoleName != entry.RoleName || s.APIGroup != entry.APIGroup || s.Resource != entry.Resource {
+		return false
+	}
+	return slices.ContainsFunc(entry.MatchedRiskRules, func(rule SARoleBindingRiskRule) bool {
+		return rule.ID == s.RuleID
+	})
+}
+
+// matchesCombination reports whether the suppression accepts a combination finding
+func (s Suppression) matchesCombination(combination SACombinationEntry) bool {
+	return s.matchesSubject(combination.SubjectName, combination.SubjectKind, combination.Namespace) &&
+		s.RoleType == "" && s.RoleName == "" && s.APIGroup == "" && s.Resource == "" && s.RuleID == combination.RuleID
+}
+
+func (s Suppression) matchesSubject(name, kind, namespace string) bool {
+	return s.SubjectName == name && s.Namespace == namespace &&
+		baselineSubjectKind(s.SubjectKind) == baselineSubjectKind(kind)
+}
+
+// baselineSubjectKind omits the default service account kind from suppressions
+func baselineSubjectKind(kind string) string {
+	if kind == SubjectKindServiceAccount {
+		return ""
+	}
+	return kind
+}
+
+// hideSuppressed removes suppressed findings from data. Permissions that triggered a
+// combination finding which is not suppressed are kept, combination indexes are updated.
+func hideSuppressed(data *ParsedData) {
+	referenced := make(map[int]bool)
+	for _, combination := range data.CombinationData {
+		if combination.Suppression == nil {
+			for _, idx := range combination.Permissions {
+				referenced[idx] = true
+			}
+		}
+	}
+
+	newIndex := make(map[int]int)
+	entries := make([]SARoleBindingEntry, 0, len(data.RBACData))
+	for i, entry := range data.RBACData {
+		if entry.Suppression != nil && !referenced[i] {
+			continue
+		}
+		newIndex[i] = len(entries)
+		entries = append(entries, entry)
+	}
+	data.RBACData = entries
+
+	combinations := make([]SACombinationEntry, 0, len(data.CombinationData))
+	for _, combination := range data.CombinationData {
+		if combination.Suppression != nil {
+			continue
+		}
+		permissions := make([]int, 0, len(combination.Permissions))
+		for _, idx := range combination.Permissions {
+			permissions = append(permissions, newIndex[idx])
+		}
+		combination.Permissions = permissions
+		combinations = append(combinations, combination)
+	}
+	data.CombinationData = combinations
+}
@@ -0,0 +1,268 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/extractor"
+)
+
+func TestLoadBaseline(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `suppressions:
+  - subjectName: operator
+    namespace: ns1
+    roleType: Role
+    roleName: op
+    resource: secrets
+    ruleId: 1010
+    justification: needs to read its TLS secret
+    owner: platform-team
+    expires: "2030-01-31"
+`,
+		},
+		{
+			name: "legacy serviceAccountName",
+			content: `suppressions:
+  - serviceAccountName: operator
+    namespace: ns1
+    ruleId: 2000
+    justification: needs to read its TLS secret
+    owner: platform-team
+`,
+		},
+		{name: "missing subject", content: "suppressions:\n  - namespace: ns1\n    ruleId: 1\n    justification: ok\n    owner: me\n", wantErr: "subjectName is required"},
+		{name: "missing owner", content: "suppressions:\n  - subjectName: op\n    ruleId: 1\n    justification: ok\n", wantErr: "owner is required"},
+		{name: "missing justification", content: "suppressions:\n  - subjectName: op\n    ruleId: 1\n    owner: me\n", wantErr: "justification is required"},
+		{name: "placeholder owner", content: "suppressions:\n  - subjectName: op\n    ruleId: 1\n    justification: ok\n    owner: TODO\n", wantErr: "owner is a placeholder"},
+		{name: "placeholder justification", content: "suppressions:\n  - subjectName: op\n    ruleId: 1\n    owner: me\n    justification: 'TODO: explain why these permissions are accepted'\n", wantErr: "justification is a placeholder"},
+		{name: "missing rule", content: "suppressions:\n  - subjectName: op\n    owner: me\n    justification: ok\n", wantErr: "ruleId is required"},
+		{name: "invalid expiry", content: "suppressions:\n  - subjectName: op\n    ruleId: 1\n    owner: me\n    justification: ok\n    expires: 31/01/2030\n", wantErr: "invalid expires date"},
+		{name: "invalid yaml", content: "suppressions: [", wantErr: "failed to parse baseline file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "baseline.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			baseline, err := LoadBaseline(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadBaseline() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadBaseline() error = %v", err)
+			}
+			if len(baseline.Suppressions) != 1 || baseline.Suppressions[0].Owner != "platform-team" || baseline.Suppressions[0].SubjectName != "operator" {
+				t.Errorf("unexpected baseline %+v", baseline)
+			}
+		})
+	}
+
+	if _, err := LoadBaseline(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadBaseline() of a missing file returned no error")
+	}
+}
+
+func TestSuppression_Expired(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := map[string]bool{
+		"":           false,
+		"2026-03-14": true,
+		"2026-03-15": false,
+		"2026-04-01": false,
+	}
+	for expires, want := range tests {
+		if got := (Suppression{Expires: expires}).Expired(now); got != want {
+			t.Errorf("Expired() with expires %q = %v, want %v", expires, got, want)
+		}
+	}
+}
+
+func baselineTestData() ParsedData {
+	return ParsedData{
+		RBACData: []SARoleBindingEntry{
+			{SubjectName: "op", SubjectKind: SubjectKindServiceAccount, ServiceAccountName: "op", Namespace: "ns1", RoleType: "Role", RoleName: "op", Resource: "secrets", RiskLevel: "High",
+				MatchedRiskRules: []SARoleBindingRiskRule{{ID: 1010}, {ID: 9998}}},
+			{SubjectName: "op", SubjectKind: SubjectKindServiceAccount, ServiceAccountName: "op", Namespace: "ns1", RoleType: "Role", RoleName: "op", Resource: "pods/exec", RiskLevel: "High",
+				MatchedRiskRules: []SARoleBindingRiskRule{{ID: 1020}, {ID: 9998}}},
+			{SubjectName: "op", SubjectKind: SubjectKindServiceAccount, ServiceAccountName: "op", Namespace: "ns1", RoleType: "Role", RoleName: "op", Resource: "configmaps", RiskLevel: "Low",
+				MatchedRiskRules: []SARoleBindingRiskRule{{ID: 9996}}},
+		},
+		CombinationData: []SACombinationEntry{
+			{SubjectName: "op", SubjectKind: SubjectKindServiceAccount, ServiceAccountName: "op", Namespace: "ns1", Scope: "ns1", RuleID: 2000, RiskLevel: "Critical", Permissions: []int{0, 1}},
+		},
+	}
+}
+
+func TestBaseline_Apply(t *testing.T) {
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	baseline := &Baseline{Suppressions: []Suppression{
+		// Matches a non-primary rule of the secrets entry
+		{SubjectName: "op", Namespace: "ns1", RoleName: "op", Resource: "secrets", RuleID: 9998, Justification: "j", Owner: "o"},
+		{SubjectName: "op", Namespace: "ns1", RoleName: "op", Resource: "pods/exec", RuleID: 1020, Justification: "j", Owner: "o", Expires: "2026-01-01"},
+		{SubjectName: "op", Namespace: "ns2", RoleName: "op", Resource: "configmaps", RuleID: 9996, Justification: "j", Owner: "o"},
+		// A ClusterRole with the same name as the Role does not share its suppressions
+		{SubjectName: "op", Namespace: "ns1", RoleType: "ClusterRole", RoleName: "op", Resource: "configmaps", RuleID: 9996, Justification: "j", Owner: "o"},
+		{SubjectName: "op", SubjectKind: SubjectKindGroup, Namespace: "ns1", RuleID: 2000, Justification: "j", Owner: "o"},
+	}}
+
+	data := baselineTestData()
+	baseline.Apply(&data, now)
+
+	want := []bool{true, false, false}
+	for i, entry := range data.RBACData {
+		if (entry.Suppression != nil) != want[i] {
+			t.Errorf("entry %s suppressed = %v, want %v", entry.Resource, entry.Suppression != nil, want[i])
+		}
+	}
+	if data.CombinationData[0].Suppression != nil {
+		t.Error("combination suppressed by a suppression for another subject kind")
+	}
+	if len(data.ExpiredSuppressions) != 1 || data.ExpiredSuppressions[0].Resource != "pods/exec" {
+		t.Errorf("ExpiredSuppressions = %+v, want the pods/exec suppression", data.ExpiredSuppressions)
+	}
+	if got := Summarize(data).AtLeast(0); got != 3 {
+		t.Errorf("Summarize() counted %d findings, want 3", got)
+	}
+}
+
+func TestHideSuppressed(t *testing.T) {
+	suppression := &Suppression{}
+
+	// The combination is visible, the permissions that triggered it are kept
+	data := baselineTestData()
+	data.RBACData[0].Suppression = suppression
+	data.RBACData[2].Suppression = suppression
+	hideSuppressed(&data)
+	if len(data.RBACData) != 2 || data.RBACData[0].Resource != "secrets" || data.RBACData[1].Resource != "pods/exec" {
+		t.Errorf("RBACData = %+v, want secrets and pods/exec", data.RBACData)
+	}
+
+	// Suppressed combinations are removed and the remaining indexes updated
+	data = baselineTestData()
+	data.RBACData[0].Suppression = suppression
+	data.CombinationData[0].Suppression = suppression
+	hideSuppressed(&data)
+	if len(data.RBACData) != 2 || len(data.CombinationData) != 0 {
+		t.Errorf("got %d entries and %d combinations, want 2 and 0", len(data.RBACData), len(data.CombinationData))
+	}
+
+	data = baselineTestData()
+	data.RBACData = append([]SARoleBindingEntry{{SubjectName: "other", Suppression: suppression}}, data.RBACData...)
+	data.CombinationData[0].Permissions = []int{1, 2}
+	hideSuppressed(&data)
+	if got := data.CombinationData[0].Permissions; len(got) != 2 || got[0] != 0 || got[1] != 1 {
+		t.Errorf("combination permissions = %v, want [0 1]", got)
+	}
+}
+
+func TestNewBaseline(t *testing.T) {
+	data := baselineTestData()
+	data.RBACData[1].Suppression = &Suppression{}
+	baseline := NewBaseline(data, "accepted", "team")
+
+	if len(baseline.Suppressions) != 3 {
+		t.Fatalf("got %d suppressions, want 3: %+v", len(baseline.Suppressions), baseline.Suppressions)
+	}
+	for _, s := range baseline.Suppressions {
+		if s.Justification != "accepted" || s.Owner != "team" || s.SubjectKind != "" {
+			t.Errorf("unexpected suppression %+v", s)
+		}
+		if s.Resource == "pods/exec" {
+			t.Error("already suppressed finding added to the baseline")
+		}
+		wantRoleType := ""
+		if s.RoleName != "" {
+			wantRoleType = "Role"
+		}
+		if s.RoleType != wantRoleType {
+			t.Errorf("suppression %+v has role type %q, want %q", s, s.RoleType, wantRoleType)
+		}
+	}
+
+	// A written baseline suppresses all findings it was generated from
+	path := filepath.Join(t.TempDir(), "baseline.yaml")
+	if err := WriteBaseline(path, baseline); err != nil {
+		t.Fatalf("WriteBaseline() error = %v", err)
+	}
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline() error = %v", err)
+	}
+	data = baselineTestData()
+	loaded.Apply(&data, time.Now())
+	for _, entry := range data.RBACData {
+		if entry.Resource != "pods/exec" && entry.Suppression == nil {
+			t.Errorf("entry %s is not suppressed", entry.Resource)
+		}
+	}
+	if data.CombinationData[0].Suppression == nil {
+		t.Error("combination is not suppressed")
+	}
+}
+
+func TestFormat_Suppressed(t *testing.T) {
+	res := newTestResult("test", "v1", "src", time.Now().Unix())
+	addRawRBACData(&res, "sa1", "ns1", extractor.ServiceAccountRBAC{
+		Roles: []extractor.RBACRole{{
+			Type: "Role", Name: "reader", Namespace: "ns1",
+			Permissions: extractor.RuleApiGroup{"": {"configmaps": {"": {"get": {}}}}},
+		}},
+	})
+	opts := &Options{Baseline: &Baseline{Suppressions: []Suppression{
+		{SubjectName: "sa1", Namespace: "ns1", RoleName: "reader", Resource: "configmaps", RuleID: 9996, Justification: "read only", Owner: "team"},
+		{SubjectName: "gone", Namespace: "ns1", RuleID: 1, Justification: "old", Owner: "team", Expires: "2020-01-01"},
+	}}}
+
+	tests := []struct {
+		typ      Type
+		contains []string
+	}{
+		{TypeJSON, []string{`"justification": "read only"`, `"expiredSuppressions"`}},
+		{TypeYAML, []string{"justification: read only", "expiredSuppressions:"}},
+		{TypeTable, []string{"Low (suppressed)", "EXPIRED SUPPRESSIONS"}},
+		{TypeMarkdown, []string{"Low (suppressed)", "EXPIRED SUPPRESSIONS"}},
+		{TypeSARIF, []string{`"status": "accepted"`, `"expiredSuppressions"`}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.typ), func(t *testing.T) {
+			f, err := NewFormatter(tt.typ, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := f.Format(res)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(out, want) {
+					t.Errorf("output does not contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+
+	hidden := *opts
+	hidden.HideSuppressed = true
+	f, _ := NewFormatter(TypeTable, &hidden)
+	out, err := f.Format(res)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(out, "configmaps") {
+		t.Errorf("suppressed finding is not hidden:\n%s", out)
+	}
+}
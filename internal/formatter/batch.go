@@ -0,0 +1,109 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"gopkg.in/yaml.v3"
+)
+
+// RiskLevelNone is the highest risk of a source without findings
+const RiskLevelNone = "None"
+
+// SourceReport is the analysis of one source of a batch. Report is nil when the analysis failed.
+type SourceReport struct {
+	Source      string      `json:"source" yaml:"source"`
+	Error       string      `json:"error,omitempty" yaml:"error,omitempty"`
+	HighestRisk string      `json:"highestRisk,omitempty" yaml:"highestRisk,omitempty"`
+	Critical    int         `json:"critical" yaml:"critical"`
+	High        int         `json:"high" yaml:"high"`
+	Medium      int         `json:"medium" yaml:"medium"`
+	Low         int         `json:"low" yaml:"low"`
+	Report      *ParsedData `json:"report,omitempty" yaml:"report,omitempty"`
+}
+
+// BatchReport holds the per-source analyses of a batch in the order of the sources
+type BatchReport struct {
+	Sources []SourceReport `json:"sources" yaml:"sources"`
+}
+
+// NewSourceReport summarizes the findings of the analysis of source by risk level
+func NewSourceReport(source string, data ParsedData) SourceReport {
+	summary := Summarize(data)
+	report := SourceReport{
+		Source:      source,
+		HighestRisk: RiskLevelNone,
+		Critical:    summary.Counts[policyevaluation.RiskLevelCritical],
+		High:        summary.Counts[policyevaluation.RiskLevelHigh],
+		Medium:      summary.Counts[policyevaluation.RiskLevelMedium],
+		Low:         summary.Counts[policyevaluation.RiskLevelLow],
+		Report:      &data,
+	}
+	for _, level := range []policyevaluation.RiskLevel{
+		policyevaluation.RiskLevelCritical,
+		policyevaluation.RiskLevelHigh,
+		policyevaluation.RiskLevelMedium,
+		policyevaluation.RiskLevelLow,
+	} {
+		if summary.Counts[level] > 0 {
+			report.HighestRisk = level.String()
+			break
+		}
+	}
+	return report
+}
+
+// Failed returns the number of sources whose analysis failed
+func (b BatchReport) Failed() int {
+	failed := 0
+	for _, s := range b.Sources {
+		if s.Report == nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// FormatBatch formats a batch report as json, yaml, table or markdown. The json and yaml
+// output includes the report of every source, the table and markdown output only the
+// summary table of the sources.
+func FormatBatch(report BatchReport, t Type) (string, error) {
+	switch t {
+	case TypeJSON:
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal batch report to JSON: %w", err)
+		}
+		return string(out) + "\n", nil
+	case TypeYAML:
+		out, err := yaml.Marshal(report)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal batch report to YAML: %w", err)
+		}
+		return string(out), nil
+	case TypeTable:
+		summary := batchSummaryTable(report)
+		summary.SetTitle("SOURCES")
+		return summary.Render() + "\n", nil
+	case TypeMarkdown:
+		return "## Sources\n\n" + batchSummaryTable(report).RenderMarkdown() + "\n", nil
+	default:
+		return "", fmt.Errorf("unsupported batch output format: %s", t)
+	}
+}
+
+// batchSummaryTable lists the highest risk and the findings by risk level of each source
+func batchSummaryTable(report BatchReport) table.Writer {
+	t := newStyledTable(table.Row{"SOURCE", "HIGHEST RISK", "CRITICAL", "HIGH", "MEDIUM", "LOW", "ERROR"})
+	for _, s := range report.Sources {
+		if s.Report == nil {
+			t.AppendRow(table.Row{s.Source, "", "", "", "", "", strings.TrimSpace(s.Error)})
+			continue
+		}
+		t.AppendRow(table.Row{s.Source, s.HighestRisk, s.Critical, s.High, s.Medium, s.Low, ""})
+	}
+	return t
+}
@@ -0,0 +1,81 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewSourceReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        ParsedData
+		wantHighest string
+		wantHigh    int
+		wantLow     int
+	}{
+		{
+			name:        "no findings",
+			wantHighest: RiskLevelNone,
+		},
+		{
+			name: "suppressed findings are not counted",
+			data: ParsedData{
+				RBACData: []SARoleBindingEntry{
+					{RiskLevel: "Critical", Suppression: &Suppression{RuleID: 1}},
+					{RiskLevel: "High"},
+					{RiskLevel: "Low"},
+				},
+				CombinationData: []SACombinationEntry{{RiskLevel: "High"}},
+			},
+			wantHighest: "High",
+			wantHigh:    2,
+			wantLow:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewSourceReport("operator.yaml", tt.data)
+			if report.Source != "operator.yaml" || report.Report == nil {
+				t.Errorf("unexpected report %+v", report)
+			}
+			if report.HighestRisk != tt.wantHighest || report.Critical != 0 || report.High != tt.wantHigh || report.Low != tt.wantLow {
+				t.Errorf("report = %s critical %d high %d low %d, want %s high %d low %d",
+					report.HighestRisk, report.Critical, report.High, report.Low, tt.wantHighest, tt.wantHigh, tt.wantLow)
+			}
+		})
+	}
+}
+
+func TestFormatBatch(t *testing.T) {
+	report := BatchReport{Sources: []SourceReport{
+		NewSourceReport("operator.yaml", ParsedData{RBACData: []SARoleBindingEntry{{SubjectName: "operator", ServiceAccountName: "operator", RiskLevel: "Critical"}}}),
+		{Source: "missing.yaml", Error: "no suitable resolver found"},
+	}}
+	if report.Failed() != 1 {
+		t.Errorf("Failed() = %d, want 1", report.Failed())
+	}
+
+	tests := []struct {
+		t        Type
+		contains []string
+		wantErr  bool
+	}{
+		{t: TypeJSON, contains: []string{`"highestRisk": "Critical"`, `"error": "no suitable resolver found"`, `"serviceAccountName": "operator"`}},
+		{t: TypeYAML, contains: []string{"highestRisk: Critical", "error: no suitable resolver found", "serviceAccountName: operator"}},
+		{t: TypeTable, contains: []string{"SOURCES", "HIGHEST RISK", "operator.yaml", "no suitable resolver found"}},
+		{t: TypeMarkdown, contains: []string{"## Sources", "| operator.yaml | Critical | 1 |"}},
+		{t: TypeSARIF, wantErr: true},
+	}
+	for _, tt := range tests {
+		out, err := FormatBatch(report, tt.t)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("FormatBatch(%s) error = %v, wantErr %v", tt.t, err, tt.wantErr)
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(out, want) {
+				t.Errorf("FormatBatch(%s) output is missing %q:\n%s", tt.t, want, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"gopkg.in/yaml.v3"
+)
+
+// Change kinds of a PermissionChange
+const (
+	ChangeAdded    = "added"
+	ChangeWidened  = "widened"
+	ChangeRemoved  = "removed"
+	ChangeNarrowed = "narrowed"
+)
+
+// PermissionChange is a permission row that differs between two analyses. Added and
+// widened rows hold the target row, removed rows the base row and narrowed rows the
+// target row.
+type PermissionChange struct {
+	SARoleBindingEntry `yaml:",inline"`
+	Change             string `json:"change" yaml:"change"`
+	// ChangedVerbs are the verbs granted by a widened row, or no longer granted by a narrowed row
+	ChangedVerbs      []string `json:"changedVerbs,omitempty" yaml:"changedVerbs,omitempty"`
+	PreviousRiskLevel string   `json:"previousRiskLevel,omitempty" yaml:"previousRiskLevel,omitempty"`
+}
+
+// ImageChange is a workload container whose image differs between two analyses
+type ImageChange struct {
+	ServiceAccountName string `json:"serviceAccountName" yaml:"serviceAccountName"`
+	Namespace          string `json:"namespace" yaml:"namespace"`
+	WorkloadType       string `json:"workloadType" yaml:"workloadType"`
+	WorkloadName       string `json:"workloadName" yaml:"workloadName"`
+	ContainerName      string `json:"containerName" yaml:"containerName"`
+	PreviousImage      string `json:"previousImage" yaml:"previousImage"`
+	Image              string `json:"image" yaml:"image"`
+}
+
+// DiffReport holds the RBAC changes between a base and a target analysis
+type DiffReport struct {
+	Base               *Metadata          `json:"base,omitempty" yaml:"base,omitempty"`
+	Target             *Metadata          `json:"target,omitempty" yaml:"target,omitempty"`
+	AddedIdentities    []SAIdentityEntry  `json:"addedServiceAccounts" yaml:"addedServiceAccounts"`
+	RemovedIdentities  []SAIdentityEntry  `json:"removedServiceAccounts" yaml:"removedServiceAccounts"`
+	AddedPermissions   []PermissionChange `json:"addedPermissions" yaml:"addedPermissions"`
+	RemovedPermissions []PermissionChange `json:"removedPermissions" yaml:"removedPermissions"`
+	ChangedImages      []ImageChange      `json:"changedImages" yaml:"changedImages"`
+}
+
+// Empty reports whether the diff found no changes
+func (d DiffReport) Empty() bool {
+	return len(d.AddedIdentities) == 0 && len(d.RemovedIdentities) == 0 &&
+		len(d.AddedPermissions) == 0 && len(d.RemovedPermissions) == 0 && len(d.ChangedImages) == 0
+}
+
+// Diff compares two analyses. Permission rows are matched by subject, namespace, role,
+// API group, resource and resource name; a matched row granting new verbs is widened,
+// one that only lost verbs is narrowed.
+func Diff(base, target ParsedData) DiffReport {
+	report := DiffReport{
+		Base:               base.Metadata,
+		Target:             target.Metadata,
+		AddedIdentities:    []SAIdentityEntry{},
+		RemovedIdentities:  []SAIdentityEntry{},
+		AddedPermissions:   []PermissionChange{},
+		RemovedPermissions: []PermissionChange{},
+		ChangedImages:      []ImageChange{},
+	}
+
+	// Service accounts
+	baseIdentities := make(map[string]bool)
+	for _, identity := range base.IdentityData {
+		baseIdentities[identity.Namespace+"/"+identity.ServiceAccountName] = true
+	}
+	targetIdentities := make(map[string]bool)
+	for _, identity := range target.IdentityData {
+		key := identity.Namespace + "/" + identity.ServiceAccountName
+		targetIdentities[key] = true
+		if !baseIdentities[key] {
+			report.AddedIdentities = append(report.AddedIdentities, identity)
+		}
+	}
+	for _, identity := range base.IdentityData {
+		if !targetIdentities[identity.Namespace+"/"+identity.ServiceAccountName] {
+			report.RemovedIdentities = append(report.RemovedIdentities, identity)
+		}
+	}
+	sortIdentities(report.AddedIdentities)
+	sortIdentities(report.RemovedIdentities)
+
+	// Permissions
+	baseRows := indexPermissions(base.RBACData)
+	targetRows := indexPermissions(target.RBACData)
+	for key, row := range targetRows {
+		previous, ok := baseRows[key]
+		if !ok {
+			report.AddedPermissions = append(report.AddedPermissions, PermissionChange{SARoleBindingEntry: row, Change: ChangeAdded})
+			continue
+		}
+		if added := missingVerbs(row.Verbs, previous.Verbs); len(added) > 0 {
+			report.AddedPermissions = append(report.AddedPermissions, PermissionChange{
+				SARoleBindingEntry: row,
+				Change:             ChangeWidened,
+				ChangedVerbs:       added,
+				PreviousRiskLevel:  previous.RiskLevel,
+			})
+		} else if removed := missingVerbs(previous.Verbs, row.Verbs); len(removed) > 0 {
+			report.RemovedPermissions = append(report.RemovedPermissions, PermissionChange{
+				SARoleBindingEntry: row,
+				Change:             ChangeNarrowed,
+				ChangedVerbs:       removed,
+				PreviousRiskLevel:  previous.RiskLevel,
+			})
+		}
+	}
+	for key, row := range baseRows {
+		if _, ok := targetRows[key]; !ok {
+			report.RemovedPermissions = append(report.RemovedPermissions, PermissionChange{SARoleBindingEntry: row, Change: ChangeRemoved})
+		}
+	}
+	sortPermissionChanges(report.AddedPermissions)
+	sortPermissionChanges(report.RemovedPermissions)
+
+	// Workload images
+	baseImages := make(map[string]string)
+	for _, workload := range base.WorkloadData {
+		baseImages[workloadKey(workload)] = workload.Image
+	}
+	for _, workload := range target.WorkloadData {
+		previous, ok := baseImages[workloadKey(workload)]
+		if !ok || previous == workload.Image {
+			continue
+		}
+		report.ChangedImages = append(report.ChangedImages, ImageChange{
+			ServiceAccountName: workload.ServiceAccountName,
+			Namespace:          workload.Namespace,
+			WorkloadType:       workload.WorkloadType,
+			WorkloadName:       workload.WorkloadName,
+			ContainerName:      workload.ContainerName,
+			PreviousImage:      previous,
+			Image:              workload.Image,
+		})
+	}
+	sort.Slice(report.ChangedImages, func(i, j int) bool {
+		a, b := report.ChangedImages[i], report.ChangedImages[j]
+		return strings.Join([]string{a.Namespace, a.WorkloadType, a.WorkloadName, a.ContainerName}, "/") <
+			strings.Join([]string{b.Namespace, b.WorkloadType, b.WorkloadName, b.ContainerName}, "/")
+	})
+
+	return report
+}
+
+// FormatDiff formats a diff report as json, yaml, table or markdown. The markdown
+// output is meant to be posted as a pull request comment.
+func FormatDiff(report DiffReport, t Type) (string, error) {
+	switch t {
+	case TypeJSON:
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal diff to JSON: %w", err)
+		}
+		return string(out) + "\n", nil
+	case TypeYAML:
+		out, err := yaml.Marshal(report)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal diff to YAML: %w", err)
+		}
+		return string(out), nil
+	case TypeTable:
+		return formatDiffTables(report, false), nil
+	case TypeMarkdown:
+		return formatDiffTables(report, true), nil
+	default:
+		return "", fmt.Errorf("unsupported diff output format: %s", t)
+	}
+}
+
+// formatDiffTables renders the non-empty sections of the report as tables
+func formatDiffTables(report DiffReport, markdown bool) string {
+	var b strings.Builder
+	if markdown {
+		b.WriteString("## RBAC changes\n\n")
+		if report.Base != nil && report.Target != nil {
+			fmt.Fprintf(&b, "Comparing `%s` with `%s`\n\n", diffSourceLabel(report.Base), diffSourceLabel(report.Target))
+		}
+		fmt.Fprintf(&b, "%s\n\n", diffSummary(report))
+	}
+	if report.Empty() {
+		b.WriteString("No RBAC changes found.\n")
+		return b.String()
+	}
+
+	type section struct {
+		title string
+		table table.Writer
+	}
+	var sections []section
+	if len(report.AddedIdentities) > 0 || len(report.RemovedIdentities) > 0 {
+		identities := newStyledTable(table.Row{"CHANGE", "IDENTITY", "NAMESPACE", "AUTOMOUNT TOKEN"})
+		for _, identity := range report.AddedIdentities {
+			identities.AppendRow(table.Row{ChangeAdded, identity.ServiceAccountName, identity.Namespace, identity.AutomountToken})
+		}
+		for _, identity := range report.RemovedIdentities {
+			identities.AppendRow(table.Row{ChangeRemoved, identity.ServiceAccountName, identity.Namespace, identity.AutomountToken})
+		}
+		sections = append(sections, section{"SERVICE ACCOUNTS", identities})
+	}
+	if len(report.AddedPermissions) > 0 {
+		sections = append(sections, section{"ADDED OR WIDENED PERMISSIONS", permissionChangesTable(report.AddedPermissions)})
+	}
+	if len(report.RemovedPermissions) > 0 {
+		sections = append(sections, section{"REMOVED OR NARROWED PERMISSIONS", permissionChangesTable(report.RemovedPermissions)})
+	}
+	if len(report.ChangedImages) > 0 {
+		images := newStyledTable(table.Row{"IDENTITY", "NAMESPACE", "WORKLOAD", "CONTAINER", "PREVIOUS IMAGE", "IMAGE"})
+		for _, image := range report.ChangedImages {
+			images.AppendRow(table.Row{
+				image.ServiceAccountName,
+				image.Namespace,
+				fmt.Sprintf("%s/%s", image.WorkloadType, image.WorkloadName),
+				image.ContainerName,
+				image.PreviousImage,
+				image.Image,
+			})
+		}
+		sections = append(sections, section{"CHANGED WORKLOAD IMAGES", images})
+	}
+
+	rendered := make([]string, 0, len(sections))
+	for _, s := range sections {
+		// Markdown headings nest below the report heading instead of the table title
+		if markdown {
+			rendered = append(rendered, fmt.Sprintf("### %s\n\n%s", s.title, s.table.RenderMarkdown()))
+		} else {
+			s.table.SetTitle(s.title)
+			rendered = append(rendered, s.table.Render())
+		}
+	}
+	b.WriteString(strings.Join(rendered, "\n\n"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// permissionChangesTable renders permission changes, verbs that changed are listed separately
+func permissionChangesTable(changes []PermissionChange) table.Writer {
+	t := newStyledTable(table.Row{"CHANGE", "IDENTITY", "NAMESPACE", "ROLE TYPE", "ROLE NAME", "API GROUP", "RESOURCE", "VERBS", "CHANGED VERBS", "RISK"})
+	for _, change := range changes {
+		resource := change.Resource
+		if change.ResourceName != "" && change.ResourceName != "*" {
+			resource = fmt.Sprintf("%s (restricted to: %s)", change.Resource, change.ResourceName)
+		}
+		risk := change.RiskLevel
+		if change.PreviousRiskLevel != "" && change.PreviousRiskLevel != change.RiskLevel {
+			risk = fmt.Sprintf("%s (was %s)", change.RiskLevel, change.PreviousRiskLevel)
+		}
+		t.AppendRow(table.Row{
+			change.Change,
+			subjectLabel(change.SARoleBindingEntry),
+			change.Namespace,
+			change.RoleType,
+			change.RoleName,
+			change.APIGroup,
+			resource,
+			strings.Join(change.Verbs, ","),
+			strings.Join(change.ChangedVerbs, ","),
+			risk,
+		})
+	}
+	return t
+}
+
+// diffSummary returns a one line summary of the report
+func diffSummary(report DiffReport) string {
+	return fmt.Sprintf("**%d** added and **%d** removed service accounts, **%d** added or widened and **%d** removed or narrowed permissions, **%d** changed images.",
+		len(report.AddedIdentities), len(report.RemovedIdentities),
+		len(report.AddedPermissions), len(report.RemovedPermissions), len(report.ChangedImages))
+}
+
+// diffSourceLabel names an analysis by its source and version, content digests are shortened
+func diffSourceLabel(m *Metadata) string {
+	version := m.Version
+	if algorithm, digest, ok := strings.Cut(version, ":"); ok && strings.HasPrefix(algorithm, "sha") && len(digest) > 12 {
+		version = algorithm + ":" + digest[:12]
+	}
+	if version == "" {
+		return m.Source
+	}
+	return fmt.Sprintf("%s (%s)", m.Source, version)
+}
+
+// indexPermissions maps permission rows by their identity, verbs of duplicate rows are merged
+func indexPermissions(entries []SARoleBindingEntry) map[string]SARoleBindingEntry {
+	rows := make(map[string]SARoleBindingEntry, len(entries))
+	for _, entry := range entries {
+		key := strings.Join([]string{
+			entry.SubjectKind, entry.SubjectName, entry.Namespace,
+			entry.RoleType, entry.RoleName, entry.APIGroup, entry.Resource, entry.ResourceName,
+		}, "\x00")
+		if existing, ok := rows[key]; ok {
+			verbs := slices.Clone(existing.Verbs)
+			verbs = append(verbs, missingVerbs(entry.Verbs, existing.Verbs)...)
+			sort.Strings(verbs)
+			existing.Verbs = verbs
+			if riskLevelRank(entry.RiskLevel) > riskLevelRank(existing.RiskLevel) {
+				existing.RiskLevel = entry.RiskLevel
+			}
+			rows[key] = existing
+			continue
+		}
+		rows[key] = entry
+	}
+	return rows
+}
+
+// missingVerbs returns the verbs of verbs not granted by granted, a "*" verb grants every verb
+func missingVerbs(verbs, granted []string) []string {
+	if slices.Contains(granted, "*") {
+		return nil
+	}
+	var missing []string
+	for _, verb := range verbs {
+		if !slices.Contains(granted, verb) {
+			missing = append(missing, verb)
+		}
+	}
+	return missing
+}
+
+// workloadKey identifies a workload container
+func workloadKey(w SAWorkloadEntry) string {
+	return strings.Join([]string{w.ServiceAccountName, w.Namespace, w.WorkloadType, w.WorkloadName, w.ContainerName}, "\x00")
+}
+
+// sortIdentities sorts identities by namespace and name
+func sortIdentities(identities []SAIdentityEntry) {
+	sort.Slice(identities, func(i, j int) bool {
+		if identities[i].Namespace != identities[j].Namespace {
+			return identities[i].Namespace < identities[j].Namespace
+		}
+		return identities[i].ServiceAccountName < identities[j].ServiceAccountName
+	})
+}
+
+// sortPermissionChanges sorts changes by risk level, highest first, then by subject and role
+func sortPermissionChanges(changes []PermissionChange) {
+	slices.SortFunc(changes, func(a, b PermissionChange) int {
+		return compareEntries(a.SARoleBindingEntry, b.SARoleBindingEntry)
+	})
+}
@@ -0,0 +1,172 @@
+package formatter
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	row := func(sa, resource string, verbs []string, risk string) SARoleBindingEntry {
+		return SARoleBindingEntry{
+			SubjectName: sa, SubjectKind: SubjectKindServiceAccount, ServiceAccountName: sa, Namespace: "ns1",
+			RoleType: "Role", RoleName: sa + "-role", Resource: resource, Verbs: verbs, RiskLevel: risk,
+		}
+	}
+	base := ParsedData{
+		Metadata: &Metadata{Source: "chart", Version: "1.4.0"},
+		IdentityData: []SAIdentityEntry{
+			{ServiceAccountName: "operator", Namespace: "ns1"},
+			{ServiceAccountName: "legacy", Namespace: "ns1"},
+		},
+		RBACData: []SARoleBindingEntry{
+			row("operator", "configmaps", []string{"get"}, "Low"),
+			row("operator", "secrets", []string{"get", "list"}, "High"),
+			row("operator", "services", []string{"get"}, "Low"),
+			row("legacy", "pods", []string{"*"}, "Medium"),
+		},
+		WorkloadData: []SAWorkloadEntry{
+			{ServiceAccountName: "operator", Namespace: "ns1", WorkloadType: "Deployment", WorkloadName: "op", ContainerName: "manager", Image: "op:1.4.0"},
+			{ServiceAccountName: "operator", Namespace: "ns1", WorkloadType: "Deployment", WorkloadName: "op", ContainerName: "proxy", Image: "proxy:1"},
+		},
+	}
+	target := ParsedData{
+		Metadata: &Metadata{Source: "chart", Version: "1.5.0"},
+		IdentityData: []SAIdentityEntry{
+			{ServiceAccountName: "operator", Namespace: "ns1"},
+			{ServiceAccountName: "webhook", Namespace: "ns1"},
+		},
+		RBACData: []SARoleBindingEntry{
+			row("operator", "configmaps", []string{"get", "update"}, "Medium"),
+			row("operator", "secrets", []string{"get"}, "High"),
+			row("operator", "services", []string{"get"}, "Low"),
+			row("webhook", "pods", []string{"create"}, "High"),
+		},
+		WorkloadData: []SAWorkloadEntry{
+			{ServiceAccountName: "operator", Namespace: "ns1", WorkloadType: "Deployment", WorkloadName: "op", ContainerName: "manager", Image: "op:1.5.0"},
+			{ServiceAccountName: "operator", Namespace: "ns1", WorkloadType: "Deployment", WorkloadName: "op", ContainerName: "proxy", Image: "proxy:1"},
+		},
+	}
+
+	report := Diff(base, target)
+
+	if len(report.AddedIdentities) != 1 || report.AddedIdentities[0].ServiceAccountName != "webhook" {
+		t.Errorf("AddedIdentities = %+v, want webhook", report.AddedIdentities)
+	}
+	if len(report.RemovedIdentities) != 1 || report.RemovedIdentities[0].ServiceAccountName != "legacy" {
+		t.Errorf("RemovedIdentities = %+v, want legacy", report.RemovedIdentities)
+	}
+
+	type change struct {
+		sa, resource, change, previousRisk string
+		verbs                              []string
+	}
+	summarize := func(changes []PermissionChange) []change {
+		var out []change
+		for _, c := range changes {
+			out = append(out, change{c.SubjectName, c.Resource, c.Change, c.PreviousRiskLevel, c.ChangedVerbs})
+		}
+		return out
+	}
+	wantAdded := []change{
+		{"webhook", "pods", ChangeAdded, "", nil},
+		{"operator", "configmaps", ChangeWidened, "Low", []string{"update"}},
+	}
+	if got := summarize(report.AddedPermissions); !reflect.DeepEqual(got, wantAdded) {
+		t.Errorf("AddedPermissions = %+v, want %+v", got, wantAdded)
+	}
+	wantRemoved := []change{
+		{"operator", "secrets", ChangeNarrowed, "High", []string{"list"}},
+		{"legacy", "pods", ChangeRemoved, "", nil},
+	}
+	if got := summarize(report.RemovedPermissions); !reflect.DeepEqual(got, wantRemoved) {
+		t.Errorf("RemovedPermissions = %+v, want %+v", got, wantRemoved)
+	}
+
+	wantImages := []ImageChange{{
+		ServiceAccountName: "operator", Namespace: "ns1", WorkloadType: "Deployment", WorkloadName: "op",
+		ContainerName: "manager", PreviousImage: "op:1.4.0", Image: "op:1.5.0",
+	}}
+	if !reflect.DeepEqual(report.ChangedImages, wantImages) {
+		t.Errorf("ChangedImages = %+v, want %+v", report.ChangedImages, wantImages)
+	}
+
+	if !Diff(base, base).Empty() {
+		t.Error("Diff() of identical data is not empty")
+	}
+}
+
+func TestMissingVerbs(t *testing.T) {
+	tests := []struct {
+		name    string
+		verbs   []string
+		granted []string
+		want    []string
+	}{
+		{"subset", []string{"get"}, []string{"get", "list"}, nil},
+		{"new verbs", []string{"get", "watch", "list"}, []string{"get"}, []string{"watch", "list"}},
+		{"wildcard granted", []string{"delete"}, []string{"*"}, nil},
+		{"wildcard added", []string{"*"}, []string{"get"}, []string{"*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingVerbs(tt.verbs, tt.granted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingVerbs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	report := DiffReport{
+		Base:   &Metadata{Source: "chart", Version: "1.4.0"},
+		Target: &Metadata{Source: "chart", Version: "1.5.0"},
+		AddedPermissions: []PermissionChange{{
+			SARoleBindingEntry: SARoleBindingEntry{SubjectName: "operator", ServiceAccountName: "operator", Namespace: "ns1", RoleType: "Role", RoleName: "op", Resource: "secrets", Verbs: []string{"get", "list"}, RiskLevel: "High"},
+			Change:             ChangeWidened,
+			ChangedVerbs:       []string{"list"},
+			PreviousRiskLevel:  "Medium",
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		report   DiffReport
+		typ      Type
+		contains []string
+		wantErr  bool
+	}{
+		{name: "table", report: report, typ: TypeTable, contains: []string{"ADDED OR WIDENED PERMISSIONS", "High (was Medium)"}},
+		{name: "markdown", report: report, typ: TypeMarkdown, contains: []string{"## RBAC changes", "`chart (1.4.0)` with `chart (1.5.0)`", "| widened |"}},
+		{name: "yaml", report: report, typ: TypeYAML, contains: []string{"change: widened", "serviceAccountName: operator"}},
+		{name: "empty markdown", report: DiffReport{}, typ: TypeMarkdown, contains: []string{"No RBAC changes found."}},
+		{name: "unsupported", report: report, typ: TypeSARIF, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := FormatDiff(tt.report, tt.typ)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FormatDiff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(out, want) {
+					t.Errorf("FormatDiff() output does not contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+
+	out, err := FormatDiff(report, TypeJSON)
+	if err != nil {
+		t.Fatalf("FormatDiff() error = %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	added := decoded["addedPermissions"].([]interface{})[0].(map[string]interface{})
+	if added["serviceAccountName"] != "operator" || added["change"] != ChangeWidened {
+		t.Errorf("unexpected JSON permission change %v", added)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/alevsk/rbac-scope/internal/extractor"
 	"github.com/alevsk/rbac-scope/internal/policyevaluation"
@@ -15,6 +16,10 @@ import (
 type Options struct {
 	// IncludeMetadata determines if metadata should be included in the output
 	IncludeMetadata bool
+	// Baseline marks the findings it accepts as suppressed, nil disables suppressions
+	Baseline *Baseline
+	// HideSuppressed removes suppressed findings from the output instead of marking them
+	HideSuppressed bool
 }
 
 // DefaultOptions returns the default formatter options
@@ -67,28 +72,36 @@ func (y *YAML) Format(rawData types.Result) (string, error) {
 
 // Format formats data as a table using go-pretty/v6/table
 func (t *Table) Format(data types.Result) (string, error) {
-	metadataTable, identityTable, rbacTable, workloadTable, potentialAbuseTable, err := buildTables(data)
+	metadataTable, identityTable, rbacTable, workloadTable, potentialAbuseTable, expiredTable, err := buildTables(data, t.opts)
 	if err != nil {
 		return "", err
 	}
 	// Combine all tables with newline separators
-	return metadataTable.Render() + "\n\n" + identityTable.Render() + "\n\n" + rbacTable.Render() + "\n\n" + potentialAbuseTable.Render() + "\n\n" + workloadTable.Render() + "\n", nil
+	out := metadataTable.Render() + "\n\n" + identityTable.Render() + "\n\n" + rbacTable.Render() + "\n\n" + potentialAbuseTable.Render() + "\n\n" + workloadTable.Render() + "\n"
+	if expiredTable != nil {
+		out += "\n" + expiredTable.Render() + "\n"
+	}
+	return out, nil
 }
 
 // Format formats data as a markdown using go-pretty/v6/table
 func (t *Markdown) Format(data types.Result) (string, error) {
-	metadataTable, identityTable, rbacTable, workloadTable, potentialAbuseTable, err := buildTables(data)
+	metadataTable, identityTable, rbacTable, workloadTable, potentialAbuseTable, expiredTable, err := buildTables(data, t.opts)
 	if err != nil {
 		return "", err
 	}
 	// Combine all tables with newline separators
-	return metadataTable.RenderMarkdown() + "\n\n" + identityTable.RenderMarkdown() + "\n\n" + rbacTable.RenderMarkdown() + "\n\n" + potentialAbuseTable.RenderMarkdown() + "\n\n" + workloadTable.RenderMarkdown() + "\n", nil
+	out := metadataTable.RenderMarkdown() + "\n\n" + identityTable.RenderMarkdown() + "\n\n" + rbacTable.RenderMarkdown() + "\n\n" + potentialAbuseTable.RenderMarkdown() + "\n\n" + workloadTable.RenderMarkdown() + "\n"
+	if expiredTable != nil {
+		out += "\n" + expiredTable.RenderMarkdown() + "\n"
+	}
+	return out, nil
 }
 
 // ParseType converts a string to a Type
 func ParseType(s string) (Type, error) {
 	switch Type(s) {
-	case TypeJSON, TypeYAML, TypeTable, TypeMarkdown:
+	case TypeJSON, TypeYAML, TypeTable, TypeMarkdown, TypeSARIF:
 		return Type(s), nil
 	default:
 		return "", fmt.Errorf("unknown formatter type: %s", s)
@@ -119,6 +132,10 @@ func NewFormatter(t Type, opts *Options) (Formatter, error) {
 		return &Markdown{
 			opts,
 		}, nil
+	case TypeSARIF:
+		return &SARIF{
+			opts,
+		}, nil
 	default:
 		return nil, fmt.Errorf("unknown formatter type: %s", t)
 	}
@@ -135,6 +152,7 @@ func PrepareData(data types.Result, opts *Options) (ParsedData, error) {
 	parsedData.IdentityData = make([]SAIdentityEntry, 0)
 	parsedData.RBACData = make([]SARoleBindingEntry, 0)
 	parsedData.WorkloadData = make([]SAWorkloadEntry, 0)
+	parsedData.CombinationData = make([]SACombinationEntry, 0)
 
 	if opts.IncludeMetadata {
 		parsedData.Metadata = &Metadata{
@@ -177,108 +195,228 @@ func PrepareData(data types.Result, opts *Options) (ParsedData, error) {
 		if !ok {
 			return parsedData, fmt.Errorf("invalid RBAC data format")
 		}
+		appendRBACEntries(&parsedData, SubjectKindServiceAccount, rbacMap, data.Manifests)
+
+		// Users and groups are optional, older results only carry service accounts
+		for _, subject := range []struct {
+			kind string
+			key  string
+		}{
+			{SubjectKindUser, "users"},
+			{SubjectKindGroup, "groups"},
+		} {
+			raw, found := data.RBACData.Data[subject.key]
+			if !found {
+				continue
+			}
+			subjectMap, ok := raw.(map[string]map[string]extractor.ServiceAccountRBAC)
+			if !ok {
+				return parsedData, fmt.Errorf("invalid RBAC %s data format", subject.key)
+			}
+			appendRBACEntries(&parsedData, subject.kind, subjectMap, data.Manifests)
+		}
+	}
+
+	// Extract Workload data and create table entries
+	if data.WorkloadData != nil {
+		// Get the Workload map that contains service account workloads
+		workloadMap, ok := data.WorkloadData.Data["workloads"].(map[string]map[string][]extractor.Workload)
+		if !ok {
+			return parsedData, fmt.Errorf("invalid Workload data format")
+		}
 
 		// Iterate through each service account
-		for saName, namespaceMap := range rbacMap {
+		for saName, namespaceMap := range workloadMap {
 			// Iterate through each namespace
-			for namespace, saRBAC := range namespaceMap {
-				// Iterate through each role
-				for _, role := range saRBAC.Roles {
-					// Iterate through permissions
-					for apiGroup, resourceMap := range role.Permissions {
-						for resource, resourceNameMap := range resourceMap {
-							for resourceName, verbSet := range resourceNameMap {
-								// Convert verbs set to slice
-								verbs := make([]string, 0, len(verbSet))
-								for verb := range verbSet {
-									verbs = append(verbs, verb)
-								}
+			for namespace, workloads := range namespaceMap {
+				// Iterate through each workload
+				for _, workload := range workloads {
+					// Iterate through containers
+					for _, container := range workload.Containers {
+						// Add row to table
+						parsedData.WorkloadData = append(parsedData.WorkloadData, SAWorkloadEntry{
+							saName,
+							namespace,
+							string(workload.Type),
+							workload.Name,
+							container.Name,
+							container.Image,
+						})
+					}
+				}
+			}
+		}
+	}
 
-								// Sort verbs for consistent output
-								sort.Strings(verbs)
-
-								entry := SARoleBindingEntry{
-									ServiceAccountName: saName,
-									Namespace:          namespace,
-									RoleType:           role.Type,
-									RoleName:           role.Name,
-									APIGroup:           apiGroup,
-									Resource:           resource,
-									ResourceName:       resourceName,
-									Verbs:              verbs,
-									RiskLevel:          "",
-									Tags:               policyevaluation.RiskTags{},
-									MatchedRiskRules:   []SARoleBindingRiskRule{},
-								}
+	if opts.Baseline != nil {
+		opts.Baseline.Apply(&parsedData, time.Now())
+		if opts.HideSuppressed {
+			hideSuppressed(&parsedData)
+		}
+	}
 
-								riskRules, err := policyevaluation.MatchRiskRules(policyevaluation.Policy{
-									Namespace:    namespace,
-									RoleType:     role.Type,
-									RoleName:     role.Name,
-									APIGroup:     apiGroup,
-									Resource:     resource,
-									ResourceName: resourceName,
-									Verbs:        verbs,
-								})
-								if err != nil {
-									continue
-								}
+	return parsedData, nil
+}
+
+// subjectPermissions holds the permissions of a subject for combination rule evaluation
+type subjectPermissions struct {
+	name      string
+	namespace string
+	policies  []policyevaluation.Policy
+	entries   []int // Index in ParsedData.RBACData of each policy
+}
+
+// roleBindingRules converts the rules of a role into the rules of an entry
+func roleBindingRules(rules []extractor.PolicyRule) []SARoleBindingRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	entries := make([]SARoleBindingRule, 0, len(rules))
+	for _, rule := range rules {
+		entries = append(entries, SARoleBindingRule{
+			Index:           rule.Index,
+			AggregatedFrom:  rule.AggregatedFrom,
+			APIGroups:       rule.APIGroups,
+			Resources:       rule.Resources,
+			ResourceNames:   rule.ResourceNames,
+			NonResourceURLs: rule.NonResourceURLs,
+			Verbs:           rule.Verbs,
+		})
+	}
+	return entries
+}
+
+// appendRBACEntries appends one entry per permission granted to the subjects of kind in subjectMap,
+// followed by the combination rules matched across all permissions of each subject. The role
+// manifests are rewritten in the remediation of high and critical permissions.
+func appendRBACEntries(parsedData *ParsedData, kind string, subjectMap map[string]map[string]extractor.ServiceAccountRBAC, manifests []*types.Manifest) {
+	// Service accounts are identified by name and namespace, users and groups by name only
+	subjects := make(map[string]*subjectPermissions)
+	var subjectKeys []string
 
+	// Iterate through each subject
+	for subjectName, namespaceMap := range subjectMap {
+		// Iterate through each namespace
+		for namespace, saRBAC := range namespaceMap {
+			// Iterate through each role
+			for _, role := range saRBAC.Roles {
+				// Iterate through permissions
+				for apiGroup, resourceMap := range role.Permissions {
+					for resource, resourceNameMap := range resourceMap {
+						for resourceName, verbSet := range resourceNameMap {
+							// Convert verbs set to slice
+							verbs := make([]string, 0, len(verbSet))
+							for verb := range verbSet {
+								verbs = append(verbs, verb)
+							}
+
+							// Sort verbs for consistent output
+							sort.Strings(verbs)
+
+							entry := SARoleBindingEntry{
+								SubjectName:       subjectName,
+								SubjectKind:       kind,
+								Namespace:         namespace,
+								RoleType:          role.Type,
+								RoleName:          role.Name,
+								APIGroup:          apiGroup,
+								Resource:          resource,
+								ResourceName:      resourceName,
+								Verbs:             verbs,
+								RiskLevel:         "",
+								Tags:              policyevaluation.RiskTags{},
+								MatchedRiskRules:  []SARoleBindingRiskRule{},
+								AggregatedFrom:    role.AggregationSources(apiGroup, resource, resourceName),
+								Rules:             roleBindingRules(role.RulesGranting(apiGroup, resource, resourceName)),
+								Subchart:          role.Subchart,
+								RoleProvenance:    role.Provenance,
+								BindingProvenance: role.BindingProvenance,
+							}
+							if kind == SubjectKindServiceAccount {
+								entry.ServiceAccountName = subjectName
+							}
+
+							policy := policyevaluation.Policy{
+								Namespace:        namespace,
+								RoleType:         role.Type,
+								RoleName:         role.Name,
+								APIGroup:         apiGroup,
+								Resource:         resource,
+								ResourceName:     resourceName,
+								Verbs:            verbs,
+								BoundToNamespace: role.BoundToNamespace(),
+							}
+							riskRules, err := policyevaluation.MatchRiskRules(policy)
+							if err != nil {
+								continue
+							}
+
+							for _, rule := range riskRules {
+								entry.MatchedRiskRules = append(entry.MatchedRiskRules, SARoleBindingRiskRule{
+									ID:   rule.ID,
+									Name: rule.Name,
+									Link: fmt.Sprintf("https://rbac-atlas.github.io/rules/%d/", rule.ID),
+								})
+							}
+							if len(riskRules) > 0 {
+								entry.RiskLevel = riskRules[0].RiskLevel.String()
+								// Get unique tags
 								for _, rule := range riskRules {
-									entry.MatchedRiskRules = append(entry.MatchedRiskRules, SARoleBindingRiskRule{
-										ID:   rule.ID,
-										Name: rule.Name,
-										Link: fmt.Sprintf("https://rbac-atlas.github.io/rules/%d/", rule.ID),
-									})
-								}
-								if len(riskRules) > 0 {
-									entry.RiskLevel = riskRules[0].RiskLevel.String()
-									// Get unique tags
-									for _, rule := range riskRules {
-										entry.Tags = append(entry.Tags, rule.Tags...)
-									}
-									entry.Tags = policyevaluation.UniqueRiskTags(entry.Tags)
+									entry.Tags = append(entry.Tags, rule.Tags...)
 								}
+								entry.Tags = policyevaluation.UniqueRiskTags(entry.Tags)
+							}
+							entry.Remediation = suggestRemediation(entry, policy, riskRules, findRoleManifest(manifests, role.Type, role.Name, role.Namespace))
 
-								parsedData.RBACData = append(parsedData.RBACData, entry)
+							key, subjectNamespace := subjectName, ""
+							if kind == SubjectKindServiceAccount {
+								key, subjectNamespace = subjectName+"/"+namespace, namespace
 							}
+							subject, ok := subjects[key]
+							if !ok {
+								subject = &subjectPermissions{name: subjectName, namespace: subjectNamespace}
+								subjects[key] = subject
+								subjectKeys = append(subjectKeys, key)
+							}
+							// Combinations are evaluated in the scope the role applies to
+							policy.Namespace = role.Namespace
+							subject.policies = append(subject.policies, policy)
+							subject.entries = append(subject.entries, len(parsedData.RBACData))
+
+							parsedData.RBACData = append(parsedData.RBACData, entry)
 						}
 					}
 				}
 			}
 		}
+	}
 
-		// Extract Workload data and create table entries
-		if data.WorkloadData != nil {
-			// Get the Workload map that contains service account workloads
-			workloadMap, ok := data.WorkloadData.Data["workloads"].(map[string]map[string][]extractor.Workload)
-			if !ok {
-				return parsedData, fmt.Errorf("invalid Workload data format")
+	sort.Strings(subjectKeys)
+	for _, key := range subjectKeys {
+		subject := subjects[key]
+		for _, match := range policyevaluation.MatchCombinationRules(subject.policies) {
+			namespace := subject.namespace
+			if kind != SubjectKindServiceAccount {
+				namespace = match.Namespace
 			}
-
-			// Iterate through each service account
-			for saName, namespaceMap := range workloadMap {
-				// Iterate through each namespace
-				for namespace, workloads := range namespaceMap {
-					// Iterate through each workload
-					for _, workload := range workloads {
-						// Iterate through containers
-						for _, container := range workload.Containers {
-							// Add row to table
-							parsedData.WorkloadData = append(parsedData.WorkloadData, SAWorkloadEntry{
-								saName,
-								namespace,
-								string(workload.Type),
-								workload.Name,
-								container.Name,
-								container.Image,
-							})
-						}
-					}
-				}
+			combination := SACombinationEntry{
+				SubjectName: subject.name,
+				SubjectKind: kind,
+				Namespace:   namespace,
+				Scope:       match.Namespace,
+				RuleID:      match.Rule.ID,
+				RuleName:    match.Rule.Name,
+				Link:        fmt.Sprintf("https://rbac-atlas.github.io/rules/%d/", match.Rule.ID),
+				RiskLevel:   match.Rule.RiskLevel.String(),
+				Tags:        match.Rule.Tags,
+			}
+			if kind == SubjectKindServiceAccount {
+				combination.ServiceAccountName = subject.name
 			}
+			for _, idx := range match.Policies {
+				combination.Permissions = append(combination.Permissions, subject.entries[idx])
+			}
+			parsedData.CombinationData = append(parsedData.CombinationData, combination)
 		}
 	}
-
-	return parsedData, nil
 }
@@ -37,6 +37,7 @@ func TestParseType(t *testing.T) {
 		{"yaml", "yaml", TypeYAML, false},
 		{"table", "table", TypeTable, false},
 		{"markdown", "markdown", TypeMarkdown, false},
+		{"sarif", "sarif", TypeSARIF, false},
 		{"unknown", "unknown", "", true},
 		{"empty", "", "", true},
 	}
@@ -65,6 +66,7 @@ func TestNewFormatter(t *testing.T) {
 		{"yaml", TypeYAML, reflect.TypeOf(&YAML{}).Kind()},
 		{"table", TypeTable, reflect.TypeOf(&Table{}).Kind()},
 		{"markdown", TypeMarkdown, reflect.TypeOf(&Markdown{}).Kind()},
+		{"sarif", TypeSARIF, reflect.TypeOf(&SARIF{}).Kind()},
 	}
 
 	for _, tt := range validTypes {
@@ -93,6 +95,10 @@ func TestNewFormatter(t *testing.T) {
 				if !f.opts.IncludeMetadata {
 					t.Errorf("formatter.opts.IncludeMetadata = false, want true for default options")
 				}
+			case *SARIF:
+				if !f.opts.IncludeMetadata {
+					t.Errorf("formatter.opts.IncludeMetadata = false, want true for default options")
+				}
 			default:
 				t.Errorf("NewFormatter returned an unexpected type: %T", formatter)
 			}
@@ -138,6 +144,13 @@ func TestNewFormatter(t *testing.T) {
 				if f.opts.IncludeMetadata {
 					t.Errorf("formatter.opts.IncludeMetadata = true, want false for custom options")
 				}
+			case *SARIF:
+				if f.opts != customOpts {
+					t.Errorf("formatter.opts not set to customOpts")
+				}
+				if f.opts.IncludeMetadata {
+					t.Errorf("formatter.opts.IncludeMetadata = true, want false for custom options")
+				}
 			default:
 				t.Errorf("NewFormatter returned an unexpected type: %T", formatter)
 			}
@@ -208,8 +221,8 @@ func sortSARoleBindingEntries(entries []SARoleBindingEntry) {
 		sort.Slice(entries[i].Tags, func(k, l int) bool { return entries[i].Tags[k] < entries[i].Tags[l] })
 	}
 	sort.SliceStable(entries, func(i, j int) bool {
-		if entries[i].ServiceAccountName != entries[j].ServiceAccountName {
-			return entries[i].ServiceAccountName < entries[j].ServiceAccountName
+		if entries[i].SubjectName != entries[j].SubjectName {
+			return entries[i].SubjectName < entries[j].SubjectName
 		}
 		if entries[i].Namespace != entries[j].Namespace {
 			return entries[i].Namespace < entries[j].Namespace
@@ -398,9 +411,12 @@ func TestPrepareData(t *testing.T) {
 						Image:              "img1",
 					},
 				},
+				CombinationData: []SACombinationEntry{},
 				RBACData: []SARoleBindingEntry{ // This part will be checked by the custom checkFunc
 					{
+						SubjectName:        "sa1",
 						ServiceAccountName: "sa1",
+						SubjectKind:        "ServiceAccount",
 						Namespace:          "ns1",
 						RoleType:           "Role",
 						RoleName:           "role1",
@@ -418,7 +434,9 @@ func TestPrepareData(t *testing.T) {
 						},
 					},
 					{
+						SubjectName:        "sa1",
 						ServiceAccountName: "sa1",
+						SubjectKind:        "ServiceAccount",
 						Namespace:          "ns1",
 						RoleType:           "ClusterRole",
 						RoleName:           "clusterrole1",
@@ -454,6 +472,106 @@ func TestPrepareData(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "user and group subjects",
+			inputRes: func(ts int64) types.Result {
+				res := newTestResult("subjects-app", "v1", "src", ts)
+				readPods := extractor.RuleApiGroup{
+					"": extractor.RuleResource{
+						"pods": extractor.RuleResourceName{
+							"": extractor.RuleVerb{"get": {}},
+						},
+					},
+				}
+				res.RBACData.Data["users"] = map[string]map[string]extractor.ServiceAccountRBAC{
+					"alice": {"ns1": {Roles: []extractor.RBACRole{{Type: "Role", Name: "reader", Namespace: "ns1", Permissions: readPods}}}},
+				}
+				res.RBACData.Data["groups"] = map[string]map[string]extractor.ServiceAccountRBAC{
+					"system:authenticated": {"*": {Roles: []extractor.RBACRole{{Type: "ClusterRole", Name: "everyone", Namespace: "*", Permissions: readPods}}}},
+				}
+				return res
+			},
+			inputOpts: DefaultOptions(),
+			checkFunc: func(t *testing.T, got ParsedData, want ParsedData) {
+				sortSARoleBindingEntries(got.RBACData)
+				if len(got.RBACData) != 2 {
+					t.Fatalf("RBACData len got %d, want 2. Got: %+v", len(got.RBACData), got.RBACData)
+				}
+				if e := got.RBACData[0]; e.SubjectName != "alice" || e.ServiceAccountName != "" || e.SubjectKind != SubjectKindUser || e.Namespace != "ns1" {
+					t.Errorf("RBACData[0] = %+v, want user alice in ns1", e)
+				}
+				if e := got.RBACData[1]; e.SubjectName != "system:authenticated" || e.SubjectKind != SubjectKindGroup || e.Namespace != "*" {
+					t.Errorf("RBACData[1] = %+v, want group system:authenticated in *", e)
+				}
+			},
+		},
+		{
+			name: "combination across roles",
+			inputRes: func(ts int64) types.Result {
+				res := newTestResult("combo-app", "v1", "src", ts)
+				addRawRBACData(&res, "sa1", "ns1", extractor.ServiceAccountRBAC{
+					Roles: []extractor.RBACRole{
+						{
+							Type: "Role", Name: "secret-reader", Namespace: "ns1",
+							Permissions: extractor.RuleApiGroup{"": {"secrets": {"": {"list": {}}}}},
+						},
+						{
+							Type: "ClusterRole", Name: "exec", Namespace: "*",
+							Permissions: extractor.RuleApiGroup{"": {"pods/exec": {"": {"create": {}}}}},
+						},
+					},
+				})
+				// The same permissions split across namespaces do not combine
+				addRawRBACData(&res, "sa2", "ns2", extractor.ServiceAccountRBAC{
+					Roles: []extractor.RBACRole{
+						{
+							Type: "Role", Name: "secret-reader", Namespace: "ns2",
+							Permissions: extractor.RuleApiGroup{"": {"secrets": {"": {"list": {}}}}},
+						},
+						{
+							Type: "Role", Name: "exec", Namespace: "ns3",
+							Permissions: extractor.RuleApiGroup{"": {"pods/exec": {"": {"create": {}}}}},
+						},
+					},
+				})
+				return res
+			},
+			inputOpts: DefaultOptions(),
+			checkFunc: func(t *testing.T, got ParsedData, want ParsedData) {
+				if len(got.CombinationData) != 1 {
+					t.Fatalf("CombinationData len got %d, want 1. Got: %+v", len(got.CombinationData), got.CombinationData)
+				}
+				combination := got.CombinationData[0]
+				if combination.SubjectName != "sa1" || combination.ServiceAccountName != "sa1" || combination.Namespace != "ns1" || combination.Scope != "ns1" {
+					t.Errorf("CombinationData[0] = %+v, want sa1 in ns1", combination)
+				}
+				if combination.RuleID != 2000 || combination.RiskLevel != "Critical" {
+					t.Errorf("CombinationData[0] rule = %d (%s), want 2000 (Critical)", combination.RuleID, combination.RiskLevel)
+				}
+				var roles []string
+				for _, idx := range combination.Permissions {
+					entry := got.RBACData[idx]
+					if entry.SubjectName != "sa1" {
+						t.Errorf("permission %d belongs to %s, want sa1", idx, entry.SubjectName)
+					}
+					roles = append(roles, entry.RoleName)
+				}
+				sort.Strings(roles)
+				if strings.Join(roles, ",") != "exec,secret-reader" {
+					t.Errorf("combination linked to roles %v, want exec and secret-reader", roles)
+				}
+			},
+		},
+		{
+			name: "invalid group data format",
+			inputRes: func(ts int64) types.Result {
+				res := newTestResult("test", "v1", "src", ts)
+				res.RBACData.Data["groups"] = "this is not a map"
+				return res
+			},
+			inputOpts:  DefaultOptions(),
+			wantErrStr: "invalid RBAC groups data format",
+		},
 		{
 			name: "rbac data with risk evaluation",
 			inputRes: func(ts int64) types.Result {
@@ -860,3 +978,64 @@ func TestFormatters(t *testing.T) {
 		}
 	})
 }
+
+func TestPrepareData_Rules(t *testing.T) {
+	res := newTableTestResult("operator", "v1", "deploy", time.Now().Unix())
+	res.IdentityData.Data["identities"] = make(map[string]map[string]extractor.Identity)
+	res.RBACData.Data["rbac"] = make(map[string]map[string]extractor.ServiceAccountRBAC)
+	res.WorkloadData.Data["workloads"] = make(map[string]map[string][]extractor.Workload)
+	addTableTestRBAC(&res, "operator", "operators", []extractor.RBACRole{
+		{
+			Type: "ClusterRole", Name: "operator", Namespace: "*",
+			Permissions: extractor.RuleApiGroup{"": {
+				"pods":    {"": {"get": {}, "list": {}, "watch": {}}},
+				"secrets": {"": {"list": {}, "watch": {}}},
+			}},
+			Rules: []extractor.PolicyRule{
+				{Index: 0, APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+				{Index: 1, APIGroups: []string{""}, Resources: []string{"pods", "secrets"}, Verbs: []string{"list", "watch"}},
+			},
+		},
+	})
+
+	parsed, err := PrepareData(res, DefaultOptions())
+	if err != nil {
+		t.Fatalf("PrepareData() returned error: %v", err)
+	}
+	want := map[string][]int{"pods": {0, 1}, "secrets": {1}}
+	for _, entry := range parsed.RBACData {
+		var got []int
+		for _, rule := range entry.Rules {
+			got = append(got, rule.Index)
+		}
+		if !reflect.DeepEqual(got, want[entry.Resource]) {
+			t.Errorf("%s rules = %v, want %v", entry.Resource, got, want[entry.Resource])
+		}
+	}
+
+	// The rules are part of the JSON and YAML output
+	for _, typ := range []Type{TypeJSON, TypeYAML} {
+		f, err := NewFormatter(typ, DefaultOptions())
+		if err != nil {
+			t.Fatalf("NewFormatter(%s) error = %v", typ, err)
+		}
+		out, err := f.Format(res)
+		if err != nil {
+			t.Fatalf("%s Format() error = %v", typ, err)
+		}
+		var decoded ParsedData
+		if typ == TypeJSON {
+			err = json.Unmarshal([]byte(out), &decoded)
+		} else {
+			err = yaml.Unmarshal([]byte(out), &decoded)
+		}
+		if err != nil {
+			t.Fatalf("%s output does not decode: %v", typ, err)
+		}
+		for _, entry := range decoded.RBACData {
+			if entry.Resource == "pods" && (len(entry.Rules) != 2 || !reflect.DeepEqual(entry.Rules[1].Resources, []string{"pods", "secrets"})) {
+				t.Errorf("%s pods rules = %+v, want rules 0 and 1", typ, entry.Rules)
+			}
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+	"github.com/alevsk/rbac-scope/internal/types"
+	jsonpatch "github.com/evanphx/json-patch"
+	"gopkg.in/yaml.v3"
+)
+
+// Remediation is a least-privilege suggestion for a permission with a high or critical risk.
+// The permission is narrowed step by step until its risk drops below High: wildcard verbs are
+// replaced with read-only verbs, a cluster-wide ClusterRole is downgraded to a Role in the
+// namespace of the service account, the permission is restricted with resourceNames and, as a
+// last resort, removed.
+type Remediation struct {
+	Steps     []string `json:"steps" yaml:"steps"`                           // Changes suggested for the permission, in order
+	Guidance  string   `json:"guidance,omitempty" yaml:"guidance,omitempty"` // Remediation of the highest matched risk rule
+	RiskLevel string   `json:"riskLevel,omitempty" yaml:"riskLevel,omitempty"`
+	// Patch is a JSON patch (RFC 6902) of the role manifest written as YAML, it narrows the
+	// permission in place, or removes it when the permission is moved to a Role or removed
+	Patch string `json:"patch,omitempty" yaml:"patch,omitempty"`
+	// Manifest is the role manifest with the patch applied, or the Role and RoleBinding the
+	// permission is moved to when the ClusterRole is downgraded
+	Manifest string `json:"manifest,omitempty" yaml:"manifest,omitempty"`
+}
+
+// readOnlyVerbs are the verbs suggested in place of the * verb
+var readOnlyVerbs = []string{"get", "list", "watch"}
+
+// unnamedVerbs are not restricted by resourceNames, requests with these verbs carry no name
+var unnamedVerbs = []string{"create", "list", "watch", "deletecollection"}
+
+// clusterScopedResources are the built-in resources that do not belong to a namespace
+var clusterScopedResources = map[string]bool{
+	"apiservices":                       true,
+	"certificatesigningrequests":        true,
+	"clusterrolebindings":               true,
+	"clusterroles":                      true,
+	"componentstatuses":                 true,
+	"csidrivers":                        true,
+	"csinodes":                          true,
+	"customresourcedefinitions":         true,
+	"flowschemas":                       true,
+	"ingressclasses":                    true,
+	"mutatingwebhookconfigurations":     true,
+	"namespaces":                        true,
+	"nodes":                             true,
+	"persistentvolumes":                 true,
+	"podsecuritypolicies":               true,
+	"priorityclasses":                   true,
+	"prioritylevelconfigurations":       true,
+	"runtimeclasses":                    true,
+	"selfsubjectaccessreviews":          true,
+	"selfsubjectreviews":                true,
+	"selfsubjectrulesreviews":           true,
+	"storageclasses":                    true,
+	"subjectaccessreviews":              true,
+	"tokenreviews":                      true,
+	"validatingadmissionpolicies":       true,
+	"validatingadmissionpolicybindings": true,
+	"validatingwebhookconfigurations":   true,
+	"volumeattachments":                 true,
+}
+
+// manifestRule is a rule as written in a Role or ClusterRole manifest
+type manifestRule struct {
+	APIGroups     []string `json:"apiGroups" yaml:"apiGroups"`
+	Resources     []string `json:"resources" yaml:"resources"`
+	ResourceNames []string `json:"resourceNames,omitempty" yaml:"resourceNames,omitempty"`
+	Verbs         []string `json:"verbs" yaml:"verbs"`
+}
+
+// patchOperation is an operation of a JSON patch
+type patchOperation struct {
+	Op    string      `json:"op" yaml:"op"`
+	Path  string      `json:"path" yaml:"path"`
+	Value interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// riskLevelOf returns the risk level of a permission, Low when it cannot be evaluated
+func riskLevelOf(policy policyevaluation.Policy) policyevaluation.RiskLevel {
+	rules, err := policyevaluation.MatchRiskRules(policy)
+	if err != nil || len(rules) == 0 {
+		return policyevaluation.RiskLevelLow
+	}
+	return rules[0].RiskLevel
+}
+
+// isNamespacedResource reports whether a resource, or the resource of a subresource, belongs to a namespace
+func isNamespacedResource(apiGroup, resource string) bool {
+	if strings.Contains(apiGroup, "*") || strings.Contains(resource, "*") {
+		return false
+	}
+	base, _, _ := strings.Cut(resource, "/")
+	return !clusterScopedResources[base]
+}
+
+// suggestRemediation returns a least-privilege suggestion for the permission of entry, nil when
+// its risk is below High. policy is the evaluated permission, rules are the risk rules it
+// matched and manifest is the manifest of the role, if known.
+func suggestRemediation(entry SARoleBindingEntry, policy policyevaluation.Policy, rules []policyevaluation.RiskRule, manifest *types.Manifest) *Remediation {
+	high := policyevaluation.RiskLevelHigh
+	if riskLevelOf(policy) < high {
+		return nil
+	}
+
+	remediation := &Remediation{}
+	for _, rule := range rules {
+		if rule.Remediation != "" {
+			remediation.Guidance = rule.Remediation
+			break
+		}
+	}
+
+	narrowed := policy
+	narrowed.Verbs = append([]string{}, policy.Verbs...)
+	wildcard := strings.Contains(policy.APIGroup, "*") || strings.Contains(policy.Resource, "*")
+	downgraded, removed := false, false
+
+	// Replace wildcard verbs, or drop the write verbs when the read-only verbs are less risky
+	if slices.Contains(narrowed.Verbs, "*") {
+		narrowed.Verbs = append([]string{}, readOnlyVerbs...)
+		remediation.Steps = append(remediation.Steps, "Replace the * verb with the read-only verbs get, list and watch")
+	} else {
+		var readOnly, write []string
+		for _, verb := range narrowed.Verbs {
+			if slices.Contains(readOnlyVerbs, verb) {
+				readOnly = append(readOnly, verb)
+			} else {
+				write = append(write, verb)
+			}
+		}
+		candidate := narrowed
+		candidate.Verbs = readOnly
+		if len(readOnly) > 0 && len(write) > 0 && riskLevelOf(candidate) < riskLevelOf(narrowed) {
+			narrowed = candidate
+			remediation.Steps = append(remediation.Steps, fmt.Sprintf("Drop the %s and keep the read-only %s", verbWords(write), verbWords(readOnly)))
+		}
+	}
+
+	// Move a namespaced resource granted cluster-wide to a Role in the namespace of the service account
+	if riskLevelOf(narrowed) >= high && entry.SubjectKind == SubjectKindServiceAccount &&
+		policy.RoleType == "ClusterRole" && !policy.BoundToNamespace && entry.Namespace != "" &&
+		isNamespacedResource(policy.APIGroup, policy.Resource) {
+		narrowed.RoleType = "Role"
+		narrowed.Namespace = entry.Namespace
+		downgraded = true
+		remediation.Steps = append(remediation.Steps, fmt.Sprintf("Move the permission from the ClusterRole %s to a Role in the %s namespace of the service account and its workloads, bound with a RoleBinding instead of a cluster-wide binding", policy.RoleName, entry.Namespace))
+	}
+
+	// Restrict the permission to named resources, the verbs resourceNames do not restrict are dropped
+	if riskLevelOf(narrowed) >= high && policy.ResourceName == "" && !wildcard {
+		var named, unnamed []string
+		for _, verb := range narrowed.Verbs {
+			// Subresource requests such as pods/exec are made on a named resource
+			if slices.Contains(unnamedVerbs, verb) && (verb != "create" || !strings.Contains(policy.Resource, "/")) {
+				unnamed = append(unnamed, verb)
+			} else {
+				named = append(named, verb)
+			}
+		}
+		if len(named) > 0 {
+			narrowed.Verbs = named
+			narrowed.ResourceName = "<resource-name>"
+			step := fmt.Sprintf("Restrict the permission to the %s the subject needs with resourceNames", policy.Resource)
+			if len(unnamed) > 0 {
+				step += fmt.Sprintf(" and drop the %s, which resourceNames do not restrict", verbWords(unnamed))
+			}
+			remediation.Steps = append(remediation.Steps, step)
+		}
+	}
+
+	if riskLevelOf(narrowed) >= high {
+		if wildcard {
+			remediation.Steps = append(remediation.Steps, "Replace the wildcard API group or resource with the API groups and resources the subject uses")
+		} else {
+			removed = true
+			remediation.Steps = append(remediation.Steps, "Remove the permission, no narrower rule brings its risk below High")
+		}
+	}
+	if !removed {
+		remediation.RiskLevel = riskLevelOf(narrowed).String()
+	}
+
+	var aggregated []string
+	for _, rule := range entry.Rules {
+		if rule.AggregatedFrom != "" && !slices.Contains(aggregated, rule.AggregatedFrom) {
+			aggregated = append(aggregated, rule.AggregatedFrom)
+		}
+	}
+	if len(aggregated) > 0 {
+		remediation.Steps = append(remediation.Steps, fmt.Sprintf("The permission is also aggregated from the ClusterRole %s, apply the same change there", joinWords(aggregated)))
+	}
+
+	rule := manifestRule{
+		APIGroups: []string{narrowed.APIGroup},
+		Resources: []string{narrowed.Resource},
+		Verbs:     narrowed.Verbs,
+	}
+	if narrowed.ResourceName != "" {
+		rule.ResourceNames = []string{narrowed.ResourceName}
+	}
+
+	var target *manifestRule
+	if !downgraded && !removed {
+		target = &rule
+	}
+	operations := remediationPatch(entry, target)
+	if len(operations) > 0 {
+		patch, err := marshalYAML(operations)
+		if err == nil {
+			remediation.Patch = patch
+		}
+		if manifest != nil && !downgraded {
+			if rewritten, err := applyPatch(manifest.Content, operations); err == nil {
+				remediation.Manifest = rewritten
+			}
+		}
+	}
+	if downgraded && !removed {
+		remediation.Manifest = downgradeManifest(entry, rule)
+	}
+	return remediation
+}
+
+// remediationPatch returns the operations that replace the permission of entry with rule in the
+// rules of the role, or remove it when rule is nil. The rule that grants only the permission is
+// replaced, other rules granting it lose the resource, API group or resource name of the
+// permission. Rules aggregated from other ClusterRoles are not patched.
+func remediationPatch(entry SARoleBindingEntry, rule *manifestRule) []patchOperation {
+	var grants []SARoleBindingRule
+	for _, r := range entry.Rules {
+		if r.AggregatedFrom == "" {
+			grants = append(grants, r)
+		}
+	}
+	if len(grants) == 0 {
+		return nil
+	}
+
+	exclusive := func(r SARoleBindingRule) bool {
+		names := len(r.ResourceNames) == 0 && entry.ResourceName == "" ||
+			len(r.ResourceNames) == 1 && r.ResourceNames[0] == entry.ResourceName
+		return len(r.APIGroups) == 1 && len(r.Resources) == 1 && names
+	}
+	replaced := -1
+	if rule != nil {
+		for _, r := range grants {
+			if exclusive(r) {
+				replaced = r.Index
+				break
+			}
+		}
+	}
+
+	// Removals are applied from the last rule so they do not shift the indexes of the others
+	sort.Slice(grants, func(i, j int) bool { return grants[i].Index > grants[j].Index })
+	var operations []patchOperation
+	for _, r := range grants {
+		path := fmt.Sprintf("/rules/%d", r.Index)
+		switch {
+		case r.Index == replaced:
+			operations = append(operations, patchOperation{Op: "replace", Path: path, Value: *rule})
+		case exclusive(r):
+			operations = append(operations, patchOperation{Op: "remove", Path: path})
+		case len(r.Resources) > 1:
+			operations = append(operations, patchOperation{Op: "replace", Path: path + "/resources", Value: without(r.Resources, entry.Resource)})
+		case len(r.APIGroups) > 1:
+			operations = append(operations, patchOperation{Op: "replace", Path: path + "/apiGroups", Value: without(r.APIGroups, entry.APIGroup)})
+		default:
+			operations = append(operations, patchOperation{Op: "replace", Path: path + "/resourceNames", Value: without(r.ResourceNames, entry.ResourceName)})
+		}
+	}
+	if rule != nil && replaced < 0 {
+		operations = append(operations, patchOperation{Op: "add", Path: "/rules/-", Value: *rule})
+	}
+	return operations
+}
+
+// applyPatch applies the operations to the content of a manifest and returns it as YAML
+func applyPatch(content map[string]interface{}, operations []patchOperation) (string, error) {
+	doc, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	ops, err := json.Marshal(operations)
+	if err != nil {
+		return "", err
+	}
+	patch, err := jsonpatch.DecodePatch(ops)
+	if err != nil {
+		return "", err
+	}
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to patch manifest: %w", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(patched, &out); err != nil {
+		return "", err
+	}
+	return marshalYAML(out)
+}
+
+// downgradeManifest returns the Role and RoleBinding that grant rule to the service account of
+// entry in its namespace
+func downgradeManifest(entry SARoleBindingEntry, rule manifestRule) string {
+	role := map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "Role",
+		"metadata":   map[string]interface{}{"name": entry.RoleName, "namespace": entry.Namespace},
+		"rules":      []manifestRule{rule},
+	}
+	binding := map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "RoleBinding",
+		"metadata":   map[string]interface{}{"name": entry.RoleName, "namespace": entry.Namespace},
+		"roleRef": map[string]interface{}{
+			"apiGroup": "rbac.authorization.k8s.io",
+			"kind":     "Role",
+			"name":     entry.RoleName,
+		},
+		"subjects": []map[string]interface{}{
+			{"kind": SubjectKindServiceAccount, "name": entry.ServiceAccountName, "namespace": entry.Namespace},
+		},
+	}
+	var docs []string
+	for _, doc := range []interface{}{role, binding} {
+		out, err := marshalYAML(doc)
+		if err != nil {
+			return ""
+		}
+		docs = append(docs, out)
+	}
+	return strings.Join(docs, "---\n")
+}
+
+// findRoleManifest returns the manifest of a role, preferring the one defined in namespace
+func findRoleManifest(manifests []*types.Manifest, roleType, roleName, namespace string) *types.Manifest {
+	var match *types.Manifest
+	for _, manifest := range manifests {
+		if manifest == nil {
+			continue
+		}
+		kind, _ := manifest.Content["kind"].(string)
+		metadata, _ := manifest.Content["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		if kind != roleType || name != roleName {
+			continue
+		}
+		if match == nil {
+			match = manifest
+		}
+		if ns, _ := metadata["namespace"].(string); ns == namespace {
+			return manifest
+		}
+	}
+	return match
+}
+
+// marshalYAML encodes v as YAML indented with two spaces
+func marshalYAML(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// without returns values without value
+func without(values []string, value string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// verbWords names verbs as "get verb" or "get and list verbs"
+func verbWords(verbs []string) string {
+	if len(verbs) == 1 {
+		return verbs[0] + " verb"
+	}
+	return joinWords(verbs) + " verbs"
+}
+
+// joinWords joins words as "a, b and c"
+func joinWords(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
@@ -0,0 +1,263 @@
+package formatter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/extractor"
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+	"github.com/alevsk/rbac-scope/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSuggestRemediation(t *testing.T) {
+	tests := []struct {
+		name         string
+		entry        SARoleBindingEntry
+		namespaced   bool // The role is a ClusterRole granted through a RoleBinding
+		manifest     *types.Manifest
+		want         bool
+		wantSteps    int
+		wantRisk     string
+		wantPatch    []string
+		wantManifest []string
+	}{
+		{
+			name: "low risk permission",
+			entry: SARoleBindingEntry{
+				SubjectKind: SubjectKindServiceAccount, Namespace: "ops", RoleType: "Role", RoleName: "reader",
+				Resource: "pods", Verbs: []string{"get"},
+			},
+		},
+		{
+			name: "wildcard verbs of a cluster role are narrowed and moved to a role",
+			entry: SARoleBindingEntry{
+				SubjectKind: SubjectKindServiceAccount, SubjectName: "operator", ServiceAccountName: "operator", Namespace: "ops",
+				RoleType: "ClusterRole", RoleName: "operator", Resource: "configmaps", Verbs: []string{"*"},
+				Rules: []SARoleBindingRule{{Index: 0, APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"*"}}},
+			},
+			want:         true,
+			wantSteps:    2,
+			wantRisk:     "Medium",
+			wantPatch:    []string{"op: remove", "path: /rules/0"},
+			wantManifest: []string{"kind: Role\n", "namespace: ops", "- watch", "kind: RoleBinding", "name: operator"},
+		},
+		{
+			name: "secrets of a role are restricted by name",
+			entry: SARoleBindingEntry{
+				SubjectKind: SubjectKindServiceAccount, SubjectName: "app", ServiceAccountName: "app", Namespace: "ops",
+				RoleType: "Role", RoleName: "app", Resource: "secrets", Verbs: []string{"*"},
+				Rules: []SARoleBindingRule{{Index: 0, APIGroups: []string{""}, Resources: []string{"secrets", "configmaps"}, Verbs: []string{"*"}}},
+			},
+			manifest: &types.Manifest{Content: map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "Role",
+				"metadata":   map[string]interface{}{"name": "app", "namespace": "ops"},
+				"rules": []interface{}{
+					map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"secrets", "configmaps"}, "verbs": []interface{}{"*"}},
+				},
+			}},
+			want:         true,
+			wantSteps:    2,
+			wantRisk:     "Low",
+			wantPatch:    []string{"path: /rules/0/resources", "path: /rules/-", "<resource-name>"},
+			wantManifest: []string{"- configmaps", "- secrets", "resourceNames:", "- get\n"},
+		},
+		{
+			name: "pod creation of a user is removed",
+			entry: SARoleBindingEntry{
+				SubjectKind: SubjectKindUser, SubjectName: "jane", RoleType: "ClusterRole", RoleName: "deployer",
+				Resource: "pods", Verbs: []string{"create"},
+				Rules: []SARoleBindingRule{{Index: 1, APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"create"}}},
+			},
+			want:      true,
+			wantSteps: 1,
+			wantPatch: []string{"op: remove", "path: /rules/1"},
+		},
+		{
+			name: "wildcard resources are left to the user",
+			entry: SARoleBindingEntry{
+				SubjectKind: SubjectKindServiceAccount, Namespace: "ops", RoleType: "ClusterRole", RoleName: "admin",
+				APIGroup: "*", Resource: "*", Verbs: []string{"*"},
+			},
+			want:      true,
+			wantSteps: 2,
+			wantRisk:  "Critical", // Read-only access to every resource includes secrets
+		},
+		{
+			name: "cluster role granted in a namespace is not moved",
+			entry: SARoleBindingEntry{
+				SubjectKind: SubjectKindServiceAccount, Namespace: "ops", RoleType: "ClusterRole", RoleName: "edit",
+				Resource: "pods/exec", Verbs: []string{"create"},
+			},
+			namespaced: true,
+			want:       true,
+			wantSteps:  1,
+			wantRisk:   "Low",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := policyevaluation.Policy{
+				Namespace:        tt.entry.Namespace,
+				RoleType:         tt.entry.RoleType,
+				RoleName:         tt.entry.RoleName,
+				APIGroup:         tt.entry.APIGroup,
+				Resource:         tt.entry.Resource,
+				ResourceName:     tt.entry.ResourceName,
+				Verbs:            tt.entry.Verbs,
+				BoundToNamespace: tt.namespaced,
+			}
+			rules, err := policyevaluation.MatchRiskRules(policy)
+			if err != nil {
+				t.Fatalf("MatchRiskRules() error = %v", err)
+			}
+			got := suggestRemediation(tt.entry, policy, rules, tt.manifest)
+			if (got != nil) != tt.want {
+				t.Fatalf("suggestRemediation() = %+v, want remediation %v", got, tt.want)
+			}
+			if got == nil {
+				return
+			}
+			if len(got.Steps) != tt.wantSteps {
+				t.Errorf("Steps = %q, want %d steps", got.Steps, tt.wantSteps)
+			}
+			if got.RiskLevel != tt.wantRisk {
+				t.Errorf("RiskLevel = %q, want %q", got.RiskLevel, tt.wantRisk)
+			}
+			if got.Guidance == "" {
+				t.Error("Guidance is empty, want the remediation of the matched rule")
+			}
+			for _, want := range tt.wantPatch {
+				if !strings.Contains(got.Patch, want) {
+					t.Errorf("Patch = %q, want it to contain %q", got.Patch, want)
+				}
+			}
+			for _, want := range tt.wantManifest {
+				if !strings.Contains(got.Manifest, want) {
+					t.Errorf("Manifest = %q, want it to contain %q", got.Manifest, want)
+				}
+			}
+			if len(tt.wantManifest) == 0 && got.Manifest != "" {
+				t.Errorf("Manifest = %q, want none", got.Manifest)
+			}
+		})
+	}
+}
+
+func TestRemediationPatch(t *testing.T) {
+	narrowed := &manifestRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}
+	tests := []struct {
+		name  string
+		rules []SARoleBindingRule
+		rule  *manifestRule
+		want  []patchOperation
+	}{
+		{
+			name:  "rule granting only the permission is replaced",
+			rules: []SARoleBindingRule{{Index: 2, APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"*"}}},
+			rule:  narrowed,
+			want:  []patchOperation{{Op: "replace", Path: "/rules/2", Value: *narrowed}},
+		},
+		{
+			name: "shared rules lose the permission",
+			rules: []SARoleBindingRule{
+				{Index: 0, APIGroups: []string{""}, Resources: []string{"pods", "secrets"}, Verbs: []string{"*"}},
+				{Index: 1, APIGroups: []string{"", "apps"}, Resources: []string{"secrets"}, Verbs: []string{"list"}},
+			},
+			rule: narrowed,
+			want: []patchOperation{
+				{Op: "replace", Path: "/rules/1/apiGroups", Value: []string{"apps"}},
+				{Op: "replace", Path: "/rules/0/resources", Value: []string{"pods"}},
+				{Op: "add", Path: "/rules/-", Value: *narrowed},
+			},
+		},
+		{
+			name: "permission is removed from the last rule first",
+			rules: []SARoleBindingRule{
+				{Index: 0, APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+				{Index: 3, APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"list"}},
+			},
+			want: []patchOperation{
+				{Op: "remove", Path: "/rules/3"},
+				{Op: "remove", Path: "/rules/0"},
+			},
+		},
+		{
+			name:  "aggregated rules are not patched",
+			rules: []SARoleBindingRule{{Index: 0, AggregatedFrom: "view", APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+			rule:  narrowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := SARoleBindingEntry{Resource: "secrets", Rules: tt.rules}
+			if got := remediationPatch(entry, tt.rule); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("remediationPatch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrepareData_Remediation(t *testing.T) {
+	res := newTableTestResult("operator", "v1", "deploy", time.Now().Unix())
+	res.IdentityData.Data["identities"] = make(map[string]map[string]extractor.Identity)
+	res.RBACData.Data["rbac"] = make(map[string]map[string]extractor.ServiceAccountRBAC)
+	res.WorkloadData.Data["workloads"] = make(map[string]map[string][]extractor.Workload)
+	res.Manifests = []*types.Manifest{{Content: map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "Role",
+		"metadata":   map[string]interface{}{"name": "operator", "namespace": "operators"},
+		"rules": []interface{}{
+			map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods"}, "verbs": []interface{}{"get"}},
+			map[string]interface{}{"apiGroups": []interface{}{"apps"}, "resources": []interface{}{"deployments"}, "verbs": []interface{}{"*"}},
+		},
+	}}}
+	addTableTestRBAC(&res, "operator", "operators", []extractor.RBACRole{
+		{
+			Type: "Role", Name: "operator", Namespace: "operators",
+			Permissions: extractor.RuleApiGroup{
+				"":     {"pods": {"": {"get": {}}}},
+				"apps": {"deployments": {"": {"*": {}}}},
+			},
+			Rules: []extractor.PolicyRule{
+				{Index: 0, APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+				{Index: 1, APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"*"}},
+			},
+		},
+	})
+
+	parsed, err := PrepareData(res, DefaultOptions())
+	if err != nil {
+		t.Fatalf("PrepareData() returned error: %v", err)
+	}
+	for _, entry := range parsed.RBACData {
+		if entry.Resource == "pods" {
+			if entry.Remediation != nil {
+				t.Errorf("pods remediation = %+v, want none for a low risk permission", entry.Remediation)
+			}
+			continue
+		}
+		if entry.Remediation == nil {
+			t.Fatalf("deployments remediation is nil, want a suggestion for a %s permission", entry.RiskLevel)
+		}
+		var role struct {
+			Kind  string         `yaml:"kind"`
+			Rules []manifestRule `yaml:"rules"`
+		}
+		if err := yaml.Unmarshal([]byte(entry.Remediation.Manifest), &role); err != nil {
+			t.Fatalf("remediation manifest does not decode: %v", err)
+		}
+		want := []manifestRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list", "watch"}},
+		}
+		if role.Kind != "Role" || !reflect.DeepEqual(role.Rules, want) {
+			t.Errorf("remediation manifest = %s, want the deployments rule narrowed to read-only verbs", entry.Remediation.Manifest)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package formatter
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+	"github.com/alevsk/rbac-scope/internal/types"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// sarifFingerprint identifies the partial fingerprint computed by rbac-scope
+	sarifFingerprint = "rbacScope/v1"
+)
+
+// sarifLog is the top level SARIF 2.1.0 document
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool              `json:"tool"`
+	Results    []sarifResult          `json:"results"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string                     `json:"name"`
+	InformationURI string                     `json:"informationUri"`
+	Rules          []sarifReportingDescriptor `json:"rules"`
+}
+
+type sarifReportingDescriptor struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	ShortDescription     sarifMessage           `json:"shortDescription"`
+	FullDescription      sarifMessage           `json:"fullDescription"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifConfiguration     `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             sarifMessage           `json:"message"`
+	Locations           []sarifLocation        `json:"locations,omitempty"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Suppressions        []sarifSuppression     `json:"suppressions,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string                 `json:"kind"`
+	Status        string                 `json:"status"`
+	Justification string                 `json:"justification,omitempty"`
+	Properties    map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation  `json:"artifactLocation"`
+	Region           *sarifRegion           `json:"region,omitempty"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// Format formats data as a SARIF 2.1.0 log
+func (s *SARIF) Format(rawData types.Result) (string, error) {
+	data, err := PrepareData(rawData, s.opts)
+	if err != nil {
+		return "", fmt.Errorf("error preparing data: %w", err)
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "rbac-scope",
+			InformationURI: "https://github.com/alevsk/rbac-scope",
+			Rules:          []sarifReportingDescriptor{},
+		}},
+		Results: []sarifResult{},
+	}
+	if data.Metadata != nil {
+		run.Properties = map[string]interface{}{
+			"name":      data.Metadata.Name,
+			"version":   data.Metadata.Version,
+			"source":    data.Metadata.Source,
+			"timestamp": data.Metadata.Timestamp,
+		}
+	}
+	if len(data.ExpiredSuppressions) > 0 {
+		if run.Properties == nil {
+			run.Properties = map[string]interface{}{}
+		}
+		run.Properties["expiredSuppressions"] = data.ExpiredSuppressions
+	}
+
+	// Rules are collected by ID, their index in the driver is set once they are sorted
+	rules := make(map[int64]sarifReportingDescriptor)
+	addRule := func(id int64, name, link string) {
+		if _, ok := rules[id]; !ok {
+			rules[id] = sarifRuleDescriptor(id, name, link)
+		}
+	}
+
+	for _, entry := range data.RBACData {
+		if len(entry.MatchedRiskRules) == 0 {
+			continue
+		}
+		// Rules are sorted by risk level, the first one is the most relevant finding
+		rule := entry.MatchedRiskRules[0]
+		addRule(rule.ID, rule.Name, rule.Link)
+		result := sarifResult{
+			RuleID:    strconv.FormatInt(rule.ID, 10),
+			Level:     sarifLevel(entry.RiskLevel),
+			Message:   sarifMessage{Text: sarifEntryMessage(entry)},
+			Locations: sarifRoleLocations(rawData, entry.RoleType, entry.RoleName, entry.Namespace),
+			PartialFingerprints: map[string]string{
+				sarifFingerprint: sarifHash(entry.SubjectKind, entry.SubjectName, entry.Namespace,
+					entry.RoleType, entry.RoleName, entry.APIGroup, entry.Resource, entry.ResourceName, rule.ID),
+			},
+			Properties: map[string]interface{}{
+				"subjectKind":    entry.SubjectKind,
+				"subject":        entry.SubjectName,
+				"namespace":      entry.Namespace,
+				"roleType":       entry.RoleType,
+				"roleName":       entry.RoleName,
+				"apiGroup":       entry.APIGroup,
+				"resource":       entry.Resource,
+				"resourceName":   entry.ResourceName,
+				"verbs":          entry.Verbs,
+				"riskLevel":      entry.RiskLevel,
+				"tags":           entry.Tags.Strings(),
+				"matchedRuleIds": sarifRuleIDs(entry.MatchedRiskRules),
+			},
+			Suppressions: sarifSuppressions(entry.Suppression),
+		}
+		if entry.Subchart != "" {
+			result.Properties["subchart"] = entry.Subchart
+		}
+		if entry.RoleProvenance != nil {
+			result.Properties["roleProvenance"] = entry.RoleProvenance.String()
+		}
+		if entry.BindingProvenance != nil {
+			result.Properties["bindingProvenance"] = entry.BindingProvenance.String()
+		}
+		if entry.Remediation != nil {
+			result.Properties["remediation"] = entry.Remediation
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	for _, combination := range data.CombinationData {
+		var locations []sarifLocation
+		seen := make(map[string]bool)
+		for _, idx := range combination.Permissions {
+			entry := data.RBACData[idx]
+			key := entry.RoleType + "/" + entry.RoleName
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			locations = append(locations, sarifRoleLocations(rawData, entry.RoleType, entry.RoleName, entry.Namespace)...)
+		}
+		addRule(combination.RuleID, combination.RuleName, combination.Link)
+		run.Results = append(run.Results, sarifResult{
+			RuleID: strconv.FormatInt(combination.RuleID, 10),
+			Level:  sarifLevel(combination.RiskLevel),
+			Message: sarifMessage{Text: fmt.Sprintf("%s %s is granted a risky combination of permissions (%s) in scope %s",
+				combination.SubjectKind, sarifSubject(combination.SubjectName, combination.Namespace, combination.SubjectKind),
+				combination.RuleName, combination.Scope)},
+			Locations: locations,
+			PartialFingerprints: map[string]string{
+				sarifFingerprint: sarifHash(combination.SubjectKind, combination.SubjectName, combination.Namespace,
+					combination.Scope, combination.RuleID),
+			},
+			Properties: map[string]interface{}{
+				"subjectKind": combination.SubjectKind,
+				"subject":     combination.SubjectName,
+				"namespace":   combination.Namespace,
+				"scope":       combination.Scope,
+				"riskLevel":   combination.RiskLevel,
+				"tags":        combination.Tags.Strings(),
+			},
+			Suppressions: sarifSuppressions(combination.Suppression),
+		})
+	}
+
+	sortSARIFRun(&run, rules)
+
+	bytes, err := json.MarshalIndent(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error formatting as SARIF: %w", err)
+	}
+	return string(bytes), nil
+}
+
+// sortSARIFRun sets the rules of the run sorted by ID and sorts its results by fingerprint, so
+// the same data always produces the same log
+func sortSARIFRun(run *sarifRun, rules map[int64]sarifReportingDescriptor) {
+	ids := make([]int64, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	ruleIndex := make(map[string]int, len(ids))
+	for _, id := range ids {
+		ruleIndex[rules[id].ID] = len(run.Tool.Driver.Rules)
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rules[id])
+	}
+	for i := range run.Results {
+		run.Results[i].RuleIndex = ruleIndex[run.Results[i].RuleID]
+	}
+
+	sort.SliceStable(run.Results, func(i, j int) bool {
+		a, b := run.Results[i], run.Results[j]
+		if fa, fb := a.PartialFingerprints[sarifFingerprint], b.PartialFingerprints[sarifFingerprint]; fa != fb {
+			return fa < fb
+		}
+		return a.Message.Text < b.Message.Text
+	})
+}
+
+// sarifRuleDescriptor builds the reportingDescriptor of a risk rule
+func sarifRuleDescriptor(id int64, name, link string) sarifReportingDescriptor {
+	descriptor := sarifReportingDescriptor{
+		ID:               strconv.FormatInt(id, 10),
+		Name:             name,
+		ShortDescription: sarifMessage{Text: name},
+		FullDescription:  sarifMessage{Text: name},
+		HelpURI:          link,
+	}
+	rule, ok := policyevaluation.GetRiskRuleByID(id)
+	if !ok {
+		descriptor.DefaultConfiguration.Level = "warning"
+		return descriptor
+	}
+	if rule.Description != "" {
+		descriptor.FullDescription.Text = rule.Description
+	}
+	descriptor.DefaultConfiguration.Level = sarifLevel(rule.RiskLevel.String())
+	descriptor.Properties = map[string]interface{}{
+		"category":          rule.Category,
+		"riskLevel":         rule.RiskLevel.String(),
+		"security-severity": sarifSecuritySeverity(rule.RiskLevel),
+		"tags":              append([]string{"security"}, rule.Tags.Strings()...),
+	}
+	return descriptor
+}
+
+// sarifLevel maps a risk level name to a SARIF result level
+func sarifLevel(riskLevel string) string {
+	level, err := policyevaluation.ParseRiskLevel(riskLevel)
+	if err != nil {
+		return "warning"
+	}
+	switch level {
+	case policyevaluation.RiskLevelCritical, policyevaluation.RiskLevelHigh:
+		return "error"
+	case policyevaluation.RiskLevelMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifSuppressions reports a baseline suppression as an accepted external suppression
+func sarifSuppressions(suppression *Suppression) []sarifSuppression {
+	if suppression == nil {
+		return nil
+	}
+	properties := map[string]interface{}{"owner": suppression.Owner}
+	if suppression.Expires != "" {
+		properties["expires"] = suppression.Expires
+	}
+	return []sarifSuppression{{
+		Kind:          "external",
+		Status:        "accepted",
+		Justification: suppression.Justification,
+		Properties:    properties,
+	}}
+}
+
+// sarifSecuritySeverity maps a risk level to the score used by GitHub code scanning
+func sarifSecuritySeverity(level policyevaluation.RiskLevel) string {
+	switch level {
+	case policyevaluation.RiskLevelCritical:
+		return "9.5"
+	case policyevaluation.RiskLevelHigh:
+		return "8.0"
+	case policyevaluation.RiskLevelMedium:
+		return "5.5"
+	default:
+		return "2.0"
+	}
+}
+
+// sarifEntryMessage describes the permission of an entry
+func sarifEntryMessage(entry SARoleBindingEntry) string {
+	resource := entry.Resource
+	if entry.APIGroup != "" {
+		resource = entry.APIGroup + "/" + entry.Resource
+	}
+	if entry.ResourceName != "" {
+		resource += " (restricted to: " + entry.ResourceName + ")"
+	}
+	return fmt.Sprintf("%s %s can %s %s through %s %s (risk: %s)",
+		entry.SubjectKind, sarifSubject(entry.SubjectName, entry.Namespace, entry.SubjectKind),
+		strings.Join(entry.Verbs, ","), resource, entry.RoleType, entry.RoleName, entry.RiskLevel)
+}
+
+// sarifSubject returns namespace/name for service accounts and the name for users and groups
+func sarifSubject(name, namespace, kind string) string {
+	if kind == SubjectKindServiceAccount {
+		return namespace + "/" + name
+	}
+	return name
+}
+
+// sarifRoleLocations points to the manifest that defines a role. Manifests read from a file
+// record the file, document index and line, manifests rendered from a Helm chart record their
+// template and document index, other manifests point to the source.
+func sarifRoleLocations(data types.Result, roleType, roleName, namespace string) []sarifLocation {
+	match := findRoleManifest(data.Manifests, roleType, roleName, namespace)
+
+	source := data.Source
+	location := sarifPhysicalLocation{Region: &sarifRegion{StartLine: 1}}
+	if match != nil {
+		// Manifests of a merged batch record their own source
+		if s, ok := match.Metadata["source"].(string); ok && s != "" {
+			source = s
+		}
+	}
+	uri := source
+	if match != nil {
+		if file, ok := match.Metadata["file"].(string); ok && file != "" {
+			uri = file
+		} else if template, ok := match.Metadata["template"].(string); ok && template != "" {
+			uri = sarifTemplateURI(source, template)
+		}
+		if docNum, ok := match.Metadata["docNum"].(int); ok {
+			location.Properties = map[string]interface{}{"documentIndex": docNum}
+		}
+		if line, ok := match.Metadata["line"].(int); ok && line > 0 {
+			location.Region.StartLine = line
+		}
+	}
+	if uri == "" {
+		return nil
+	}
+	location.ArtifactLocation.URI = filepath.ToSlash(uri)
+	return []sarifLocation{{PhysicalLocation: location}}
+}
+
+// sarifTemplateURI resolves a Helm template name such as mychart/templates/role.yaml
+// against the chart directory, remote charts keep the template name
+func sarifTemplateURI(source, template string) string {
+	if source == "" || strings.Contains(source, "://") {
+		return template
+	}
+	parts := strings.SplitN(filepath.ToSlash(template), "/", 2)
+	if len(parts) != 2 {
+		return template
+	}
+	return filepath.Join(source, parts[1])
+}
+
+// sarifRuleIDs returns the IDs of the matched rules
+func sarifRuleIDs(rules []SARoleBindingRiskRule) []string {
+	ids := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		ids = append(ids, strconv.FormatInt(rule.ID, 10))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sarifHash returns a stable fingerprint for the given values
+func sarifHash(values ...interface{}) string {
+	h := sha256.New()
+	for _, v := range values {
+		fmt.Fprintf(h, "%v\x00", v)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,216 @@
+package formatter
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/extractor"
+	"github.com/alevsk/rbac-scope/internal/types"
+)
+
+func TestSARIF_Format(t *testing.T) {
+	res := newTestResult("chart", "v1", "deploy/chart", time.Now().Unix())
+	addRawRBACData(&res, "sa1", "ns1", extractor.ServiceAccountRBAC{
+		Roles: []extractor.RBACRole{{
+			Type: "ClusterRole", Name: "admin", Namespace: "*",
+			Permissions: extractor.RuleApiGroup{"*": {"*": {"": {"*": {}}}}},
+		}},
+	})
+	addRawRBACData(&res, "sa2", "ns1", extractor.ServiceAccountRBAC{
+		Roles: []extractor.RBACRole{{
+			Type: "Role", Name: "reader", Namespace: "ns1",
+			Permissions: extractor.RuleApiGroup{"": {"configmaps": {"": {"get": {}}}}},
+		}},
+	})
+	res.Manifests = []*types.Manifest{
+		{
+			Content:  map[string]interface{}{"kind": "ClusterRole", "metadata": map[string]interface{}{"name": "admin"}},
+			Metadata: map[string]interface{}{"template": "chart/templates/rbac.yaml", "docNum": 2},
+		},
+		{
+			Content: map[string]interface{}{"kind": "Role", "metadata": map[string]interface{}{"name": "reader", "namespace": "ns1"}},
+		},
+	}
+
+	f, err := NewFormatter(TypeSARIF, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Format(res)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(out), &log); err != nil {
+		t.Fatalf("invalid SARIF output: %v\n%s", err, out)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF envelope: version %q, %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "rbac-scope" {
+		t.Errorf("driver name = %q", run.Tool.Driver.Name)
+	}
+	// One result per permission plus the combinations the wildcard permission of sa1 matches
+	if len(run.Results) != 5 {
+		t.Fatalf("got %d results, want 5", len(run.Results))
+	}
+
+	results := map[string]sarifResult{}
+	combinations := 0
+	for _, r := range run.Results {
+		if _, ok := r.Properties["scope"]; ok {
+			combinations++
+			if len(r.Locations) != 1 {
+				t.Errorf("combination result %s has %d locations, want 1", r.RuleID, len(r.Locations))
+			}
+		} else {
+			results[r.Properties["subject"].(string)] = r
+		}
+		rule := run.Tool.Driver.Rules[r.RuleIndex]
+		if rule.ID != r.RuleID {
+			t.Errorf("result rule %s points to descriptor %s", r.RuleID, rule.ID)
+		}
+		if r.PartialFingerprints[sarifFingerprint] == "" {
+			t.Errorf("result for %v has no fingerprint", r.Properties["subject"])
+		}
+	}
+
+	if combinations != 3 {
+		t.Errorf("got %d combination results, want 3", combinations)
+	}
+
+	admin := results["sa1"]
+	if admin.Level != "error" {
+		t.Errorf("critical finding level = %q, want error", admin.Level)
+	}
+	wantURI := filepath.ToSlash(filepath.Join("deploy/chart", "templates/rbac.yaml"))
+	if len(admin.Locations) != 1 || admin.Locations[0].PhysicalLocation.ArtifactLocation.URI != wantURI {
+		t.Errorf("critical finding locations = %+v, want %s", admin.Locations, wantURI)
+	}
+	if doc := admin.Locations[0].PhysicalLocation.Properties["documentIndex"]; doc != float64(2) {
+		t.Errorf("documentIndex = %v, want 2", doc)
+	}
+	adminRule := run.Tool.Driver.Rules[admin.RuleIndex]
+	if adminRule.DefaultConfiguration.Level != "error" || adminRule.FullDescription.Text == "" {
+		t.Errorf("unexpected descriptor %+v", adminRule)
+	}
+
+	reader := results["sa2"]
+	if reader.Level != "note" {
+		t.Errorf("low finding level = %q, want note", reader.Level)
+	}
+	if len(reader.Locations) != 1 || reader.Locations[0].PhysicalLocation.ArtifactLocation.URI != "deploy/chart" {
+		t.Errorf("low finding locations = %+v, want the source", reader.Locations)
+	}
+}
+
+func TestSARIF_Format_Deterministic(t *testing.T) {
+	res := newTestResult("chart", "v1", "deploy/chart", time.Now().Unix())
+	for _, sa := range []string{"sa1", "sa2", "sa3", "sa4"} {
+		addRawRBACData(&res, sa, "ns1", extractor.ServiceAccountRBAC{
+			Roles: []extractor.RBACRole{{
+				Type: "ClusterRole", Name: sa + "-role", Namespace: "*",
+				Permissions: extractor.RuleApiGroup{
+					"":     {"secrets": {"": {"get": {}, "list": {}}}, "pods": {"": {"create": {}}}, "pods/exec": {"": {"create": {}}}},
+					"apps": {"deployments": {"": {"patch": {}}}},
+				},
+			}},
+		})
+	}
+
+	f, err := NewFormatter(TypeSARIF, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := f.Format(res)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		got, err := f.Format(res)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		if got != want {
+			t.Fatalf("Format() is not deterministic, got\n%s\nwant\n%s", got, want)
+		}
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(want), &log); err != nil {
+		t.Fatal(err)
+	}
+	rules := log.Runs[0].Tool.Driver.Rules
+	for i := 1; i < len(rules); i++ {
+		prev, _ := strconv.ParseInt(rules[i-1].ID, 10, 64)
+		id, _ := strconv.ParseInt(rules[i].ID, 10, 64)
+		if prev >= id {
+			t.Errorf("rules are not sorted by ID: %s before %s", rules[i-1].ID, rules[i].ID)
+		}
+	}
+}
+
+func TestSarifLevel(t *testing.T) {
+	tests := map[string]string{
+		"Critical": "error",
+		"High":     "error",
+		"Medium":   "warning",
+		"Low":      "note",
+		"":         "warning",
+	}
+	for level, want := range tests {
+		if got := sarifLevel(level); got != want {
+			t.Errorf("sarifLevel(%q) = %q, want %q", level, got, want)
+		}
+	}
+}
+
+func TestSarifRoleLocations_ManifestSource(t *testing.T) {
+	data := types.Result{
+		Name: "batch of 2 sources",
+		Manifests: []*types.Manifest{
+			{
+				Content:  map[string]interface{}{"kind": "Role", "metadata": map[string]interface{}{"name": "reader"}},
+				Metadata: map[string]interface{}{"source": "deploy/operator.yaml"},
+			},
+			{
+				Content:  map[string]interface{}{"kind": "ClusterRole", "metadata": map[string]interface{}{"name": "admin"}},
+				Metadata: map[string]interface{}{"source": "charts/operator", "template": "operator/templates/rbac.yaml"},
+			},
+			{
+				Content:  map[string]interface{}{"kind": "ClusterRole", "metadata": map[string]interface{}{"name": "viewer"}},
+				Metadata: map[string]interface{}{"source": "deploy", "file": "deploy/rbac/viewer.yaml", "docNum": 2, "line": 12},
+			},
+		},
+	}
+
+	tests := []struct {
+		roleType string
+		roleName string
+		wantURI  string
+		wantLine int
+	}{
+		{roleType: "Role", roleName: "reader", wantURI: "deploy/operator.yaml", wantLine: 1},
+		{roleType: "ClusterRole", roleName: "admin", wantURI: "charts/operator/templates/rbac.yaml", wantLine: 1},
+		{roleType: "ClusterRole", roleName: "viewer", wantURI: "deploy/rbac/viewer.yaml", wantLine: 12},
+		{roleType: "Role", roleName: "unknown"},
+	}
+	for _, tt := range tests {
+		locations := sarifRoleLocations(data, tt.roleType, tt.roleName, "")
+		if tt.wantURI == "" {
+			if len(locations) != 0 {
+				t.Errorf("%s %s: expected no location, got %v", tt.roleType, tt.roleName, locations)
+			}
+			continue
+		}
+		if len(locations) != 1 || locations[0].PhysicalLocation.ArtifactLocation.URI != tt.wantURI ||
+			locations[0].PhysicalLocation.Region.StartLine != tt.wantLine {
+			t.Errorf("%s %s: locations = %+v, want %s:%d", tt.roleType, tt.roleName, locations, tt.wantURI, tt.wantLine)
+		}
+	}
+}
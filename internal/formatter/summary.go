@@ -0,0 +1,63 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+)
+
+// Summary counts the findings of an analysis by risk level. Findings are the
+// permission entries and the combination findings of the parsed data that are
+// not suppressed by a baseline.
+type Summary struct {
+	Counts map[policyevaluation.RiskLevel]int
+}
+
+// Summarize counts the findings in data by risk level, entries with an unknown risk level are ignored
+func Summarize(data ParsedData) Summary {
+	summary := Summary{Counts: make(map[policyevaluation.RiskLevel]int)}
+	for _, entry := range data.RBACData {
+		if entry.Suppression != nil {
+			continue
+		}
+		if level, err := policyevaluation.ParseRiskLevel(entry.RiskLevel); err == nil {
+			summary.Counts[level]++
+		}
+	}
+	for _, combination := range data.CombinationData {
+		if combination.Suppression != nil {
+			continue
+		}
+		if level, err := policyevaluation.ParseRiskLevel(combination.RiskLevel); err == nil {
+			summary.Counts[level]++
+		}
+	}
+	return summary
+}
+
+// AtLeast returns the number of findings with a risk level of at least level
+func (s Summary) AtLeast(level policyevaluation.RiskLevel) int {
+	total := 0
+	for l, count := range s.Counts {
+		if l >= level {
+			total += count
+		}
+	}
+	return total
+}
+
+// String returns the counts from the highest to the lowest risk level, e.g. "Critical: 1, High: 0, Medium: 2, Low: 5"
+func (s Summary) String() string {
+	levels := []policyevaluation.RiskLevel{
+		policyevaluation.RiskLevelCritical,
+		policyevaluation.RiskLevelHigh,
+		policyevaluation.RiskLevelMedium,
+		policyevaluation.RiskLevelLow,
+	}
+	parts := make([]string, 0, len(levels))
+	for _, level := range levels {
+		parts = append(parts, fmt.Sprintf("%s: %d", level, s.Counts[level]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,42 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+)
+
+func TestSummarize(t *testing.T) {
+	data := ParsedData{
+		RBACData: []SARoleBindingEntry{
+			{RiskLevel: "Critical"},
+			{RiskLevel: "High"},
+			{RiskLevel: "Low"},
+			{RiskLevel: "Low"},
+			{RiskLevel: ""},
+		},
+		CombinationData: []SACombinationEntry{
+			{RiskLevel: "Critical"},
+		},
+	}
+	summary := Summarize(data)
+
+	tests := []struct {
+		level policyevaluation.RiskLevel
+		want  int
+	}{
+		{policyevaluation.RiskLevelCritical, 2},
+		{policyevaluation.RiskLevelHigh, 3},
+		{policyevaluation.RiskLevelMedium, 3},
+		{policyevaluation.RiskLevelLow, 5},
+	}
+	for _, tt := range tests {
+		if got := summary.AtLeast(tt.level); got != tt.want {
+			t.Errorf("AtLeast(%s) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+
+	if got, want := summary.String(), "Critical: 2, High: 1, Medium: 0, Low: 2"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -2,17 +2,23 @@ package formatter
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 
-	"github.com/alevsk/rbac-scope/internal/extractor"
 	"github.com/alevsk/rbac-scope/internal/policyevaluation"
 	"github.com/alevsk/rbac-scope/internal/types"
 	"github.com/jedib0t/go-pretty/v6/table"
 )
 
-// buildTables builds the tables for the given data
-func buildTables(data types.Result) (table.Writer, table.Writer, table.Writer, table.Writer, table.Writer, error) {
+// buildTables builds the tables for the given data, the expired suppressions table is nil
+// when no baseline suppression has expired
+func buildTables(data types.Result, opts *Options) (table.Writer, table.Writer, table.Writer, table.Writer, table.Writer, table.Writer, error) {
+	parsed, err := PrepareData(data, opts)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
+
 	// Create Metadata table
 	metadataTable := table.NewWriter()
 	metadataTable.SetOutputMirror(nil)
@@ -66,28 +72,14 @@ func buildTables(data types.Result) (table.Writer, table.Writer, table.Writer, t
 		"IMAGE PULL SECRETS",
 	})
 
-	// Extract Identity data and create table entries
-	if data.IdentityData != nil {
-		// Get the Identity map that contains service account identities
-		identityMap, ok := data.IdentityData.Data["identities"].(map[string]map[string]extractor.Identity)
-		if !ok {
-			return nil, nil, nil, nil, nil, fmt.Errorf("invalid Identity data format")
-		}
-
-		// Iterate through each service account
-		for saName, namespaceMap := range identityMap {
-			// Iterate through each namespace
-			for namespace, identity := range namespaceMap {
-				// Add row to table
-				identityTable.AppendRow(table.Row{
-					saName,
-					namespace,
-					identity.AutomountToken,
-					strings.Join(identity.Secrets, ","),
-					strings.Join(identity.ImagePullSecrets, ","),
-				})
-			}
-		}
+	for _, identity := range parsed.IdentityData {
+		identityTable.AppendRow(table.Row{
+			identity.ServiceAccountName,
+			identity.Namespace,
+			identity.AutomountToken,
+			strings.Join(identity.Secrets, ","),
+			strings.Join(identity.ImagePullSecrets, ","),
+		})
 	}
 
 	// Sort Identity table by service account name and namespace
@@ -116,6 +108,7 @@ func buildTables(data types.Result) (table.Writer, table.Writer, table.Writer, t
 		"VERBS",
 		"RISK",
 		"TAGS",
+		"SOURCE",
 	})
 
 	// Create potential abuse table
@@ -133,118 +126,82 @@ func buildTables(data types.Result) (table.Writer, table.Writer, table.Writer, t
 		"ACTION",
 		"INFO",
 	})
-	addedRules := make(map[string]map[int64]bool)
-
-	// Extract RBAC data and create table entries
-	if data.RBACData != nil {
-		// Get the RBAC map that contains service account permissions
-		rbacMap, ok := data.RBACData.Data["rbac"].(map[string]map[string]extractor.ServiceAccountRBAC)
-		if !ok {
-			return nil, nil, nil, nil, nil, fmt.Errorf("invalid RBAC data format")
+	addedRules := make(map[string]map[string]bool)
+
+	// Sort entries by risk level, highest first, then by subject and role
+	entries := slices.Clone(parsed.RBACData)
+	slices.SortStableFunc(entries, compareEntries)
+
+	for _, entry := range entries {
+		identity := subjectLabel(entry)
+
+		formattedResource := entry.Resource
+		if entry.ResourceName != "" && entry.ResourceName != "*" {
+			formattedResource = fmt.Sprintf("%s (restricted to: %s)", entry.Resource, entry.ResourceName)
 		}
 
-		// Create an array to store rows
-		var rows []table.Row
-
-		// Iterate through each service account
-		for saName, namespaceMap := range rbacMap {
-			// Iterate through each namespace
-			for namespace, saRBAC := range namespaceMap {
-				// Iterate through each role
-				for _, role := range saRBAC.Roles {
-					// Iterate through permissions
-					for apiGroup, resourceMap := range role.Permissions {
-						for resource, resourceNameMap := range resourceMap {
-							for resourceName, verbSet := range resourceNameMap {
-								// Convert verbs set to slice
-								verbs := make([]string, 0, len(verbSet))
-								for verb := range verbSet {
-									verbs = append(verbs, verb)
-								}
-
-								// Sort verbs for consistent output
-								sort.Strings(verbs)
-
-								formattedResource := resource
-								if resourceName != "" && resourceName != "*" {
-									formattedResource = fmt.Sprintf("%s (restricted to: %s)", resource, resourceName)
-								}
-
-								row := table.Row{
-									saName,
-									namespace,
-									role.Type,
-									role.Name,
-									apiGroup,
-									formattedResource,
-									strings.Join(verbs, ","),
-								}
-
-								riskRules, err := policyevaluation.MatchRiskRules(policyevaluation.Policy{
-									Namespace:    namespace,
-									RoleType:     role.Type,
-									RoleName:     role.Name,
-									APIGroup:     apiGroup,
-									Resource:     resource,
-									ResourceName: resourceName,
-									Verbs:        verbs,
-								})
-								if err != nil {
-									continue
-								}
-
-								if len(riskRules) > 0 {
-									tags := policyevaluation.RiskTags{}
-									// Get unique tags
-									for _, rule := range riskRules {
-										tags = append(tags, rule.Tags...)
-
-										// Add rule to potential abuse table if not already added
-										if _, ok := addedRules[saName]; !ok {
-											addedRules[saName] = make(map[int64]bool)
-										}
-										// Skip default rules (Low, Medium, High, Critical)
-										if rule.ID < 9996 && !addedRules[saName][rule.ID] {
-											potentialAbuseTable.AppendRow(table.Row{
-												saName,
-												rule.Name,
-												fmt.Sprintf("https://rbac-atlas.github.io/rules/%d/", rule.ID),
-											})
-											addedRules[saName][rule.ID] = true
-										}
-
-									}
-									tags = policyevaluation.UniqueRiskTags(tags)
-									row = append(row, riskRules[0].RiskLevel, strings.Join(tags.StringSlice(3), ","))
-								} else {
-									row = append(row, "", "")
-								}
-
-								// Add row to array
-								rows = append(rows, row)
-							}
-						}
-					}
-				}
-			}
+		roleName := entry.RoleName
+		if entry.Subchart != "" {
+			roleName = fmt.Sprintf("%s (subchart: %s)", entry.RoleName, entry.Subchart)
 		}
 
-		// Sort potential abuse table by ID
-		potentialAbuseTable.SortBy([]table.SortBy{
-			{Name: "ID", Mode: table.Asc},
+		rbacTable.AppendRow(table.Row{
+			identity,
+			entry.Namespace,
+			entry.RoleType,
+			roleName,
+			entry.APIGroup,
+			formattedResource,
+			strings.Join(entry.Verbs, ","),
+			suppressedLabel(entry.RiskLevel, entry.Suppression),
+			strings.Join(entry.Tags.StringSlice(3), ","),
+			provenanceLabel(entry),
 		})
 
-		// Sort rows by risk level
-		sort.Slice(rows, func(i, j int) bool {
-			rowLeft := rows[i]
-			rowRight := rows[j]
-			return rowLeft[7].(policyevaluation.RiskLevel) > rowRight[7].(policyevaluation.RiskLevel)
-		})
+		// Add rules to potential abuse table if not already added
+		if _, ok := addedRules[identity]; !ok {
+			addedRules[identity] = make(map[string]bool)
+		}
+		for _, rule := range entry.MatchedRiskRules {
+			// Skip default rules (Low, Medium, High, Critical)
+			action := suppressedLabel(rule.Name, entry.Suppression)
+			if rule.ID < 9996 && !addedRules[identity][action] {
+				potentialAbuseTable.AppendRow(table.Row{
+					identity,
+					action,
+					rule.Link,
+				})
+				addedRules[identity][action] = true
+			}
+		}
+	}
 
-		// Append all rows to the table
-		rbacTable.AppendRows(rows)
+	// Add combination findings with the roles that triggered them
+	for _, combination := range parsed.CombinationData {
+		identity := subjectLabel(SARoleBindingEntry{SubjectName: combination.SubjectName, SubjectKind: combination.SubjectKind})
+		var roles []string
+		seen := make(map[string]bool)
+		for _, idx := range combination.Permissions {
+			role := parsed.RBACData[idx].RoleName
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+		sort.Strings(roles)
+		potentialAbuseTable.AppendRow(table.Row{
+			identity,
+			suppressedLabel(fmt.Sprintf("%s (%s, scope: %s, via: %s)", combination.RuleName, combination.RiskLevel, combination.Scope, strings.Join(roles, ",")), combination.Suppression),
+			combination.Link,
+		})
 	}
 
+	// Sort potential abuse table by identity and action
+	potentialAbuseTable.SortBy([]table.SortBy{
+		{Name: "IDENTITY", Mode: table.Asc},
+		{Name: "ACTION", Mode: table.Asc},
+	})
+
 	// Create Workload table
 	workloadTable := table.NewWriter()
 	workloadTable.SetOutputMirror(nil)
@@ -264,35 +221,15 @@ func buildTables(data types.Result) (table.Writer, table.Writer, table.Writer, t
 		"IMAGE",
 	})
 
-	// Extract Workload data and create table entries
-	if data.WorkloadData != nil {
-		// Get the Workload map that contains service account workloads
-		workloadMap, ok := data.WorkloadData.Data["workloads"].(map[string]map[string][]extractor.Workload)
-		if !ok {
-			return nil, nil, nil, nil, nil, fmt.Errorf("invalid Workload data format")
-		}
-
-		// Iterate through each service account
-		for saName, namespaceMap := range workloadMap {
-			// Iterate through each namespace
-			for namespace, workloads := range namespaceMap {
-				// Iterate through each workload
-				for _, workload := range workloads {
-					// Iterate through containers
-					for _, container := range workload.Containers {
-						// Add row to table
-						workloadTable.AppendRow(table.Row{
-							saName,
-							namespace,
-							workload.Type,
-							workload.Name,
-							container.Name,
-							container.Image,
-						})
-					}
-				}
-			}
-		}
+	for _, workload := range parsed.WorkloadData {
+		workloadTable.AppendRow(table.Row{
+			workload.ServiceAccountName,
+			workload.Namespace,
+			workload.WorkloadType,
+			workload.WorkloadName,
+			workload.ContainerName,
+			workload.Image,
+		})
 	}
 
 	// Sort Workload table by service account name and namespace
@@ -301,5 +238,95 @@ func buildTables(data types.Result) (table.Writer, table.Writer, table.Writer, t
 		{Name: "NAMESPACE", Mode: table.Asc},
 	})
 
-	return metadataTable, identityTable, rbacTable, potentialAbuseTable, workloadTable, nil
+	// Create expired suppressions table
+	var expiredTable table.Writer
+	if len(parsed.ExpiredSuppressions) > 0 {
+		expiredTable = table.NewWriter()
+		expiredTable.SetOutputMirror(nil)
+		expiredTable.SetStyle(table.StyleLight)
+		expiredTable.Style().Options.SeparateColumns = true
+		expiredTable.SetTitle("EXPIRED SUPPRESSIONS")
+		expiredTable.AppendHeader(table.Row{
+			"IDENTITY",
+			"NAMESPACE",
+			"ROLE NAME",
+			"API GROUP",
+			"RESOURCE",
+			"RULE ID",
+			"OWNER",
+			"EXPIRES",
+		})
+		for _, s := range parsed.ExpiredSuppressions {
+			expiredTable.AppendRow(table.Row{
+				subjectLabel(SARoleBindingEntry{SubjectName: s.SubjectName, SubjectKind: s.SubjectKind}),
+				s.Namespace,
+				s.RoleName,
+				s.APIGroup,
+				s.Resource,
+				s.RuleID,
+				s.Owner,
+				s.Expires,
+			})
+		}
+	}
+
+	return metadataTable, identityTable, rbacTable, potentialAbuseTable, workloadTable, expiredTable, nil
+}
+
+// suppressedLabel marks a value of a finding accepted by a baseline suppression
+func suppressedLabel(value string, suppression *Suppression) string {
+	if suppression == nil {
+		return value
+	}
+	return value + " (suppressed)"
+}
+
+// subjectLabel returns the identity shown in tables, users and groups are suffixed with their kind
+func subjectLabel(entry SARoleBindingEntry) string {
+	if entry.SubjectKind == "" || entry.SubjectKind == SubjectKindServiceAccount {
+		return entry.SubjectName
+	}
+	return fmt.Sprintf("%s (%s)", entry.SubjectName, entry.SubjectKind)
+}
+
+// compareEntries orders permission rows by risk level, highest first, then by subject, namespace,
+// role, API group, resource and resource name
+func compareEntries(a, b SARoleBindingEntry) int {
+	if ra, rb := riskLevelRank(a.RiskLevel), riskLevelRank(b.RiskLevel); ra != rb {
+		return rb - ra
+	}
+	ka := strings.Join([]string{a.SubjectName, a.Namespace, a.RoleName, a.APIGroup, a.Resource, a.ResourceName}, "\x00")
+	kb := strings.Join([]string{b.SubjectName, b.Namespace, b.RoleName, b.APIGroup, b.Resource, b.ResourceName}, "\x00")
+	return strings.Compare(ka, kb)
+}
+
+// provenanceLabel returns where the role and the binding of an entry were read from, one per line
+func provenanceLabel(entry SARoleBindingEntry) string {
+	var lines []string
+	if entry.RoleProvenance != nil {
+		lines = append(lines, "role: "+entry.RoleProvenance.String())
+	}
+	if entry.BindingProvenance != nil {
+		lines = append(lines, "binding: "+entry.BindingProvenance.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// riskLevelRank orders risk level names, unknown levels rank below Low
+func riskLevelRank(level string) int {
+	rl, err := policyevaluation.ParseRiskLevel(level)
+	if err != nil {
+		return -1
+	}
+	return int(rl)
+}
+
+// newStyledTable creates a table with the style of the analysis tables, for the reports built outside of them
+func newStyledTable(header table.Row) table.Writer {
+	t := table.NewWriter()
+	t.SetOutputMirror(nil)
+	t.SetStyle(table.StyleLight)
+	t.Style().Options.SeparateColumns = true
+	t.AppendHeader(header)
+	return t
 }
@@ -1,6 +1,7 @@
 package formatter
 
 import (
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -87,7 +88,7 @@ func TestBuildTables_FullData(t *testing.T) {
 	})
 	addTableTestWorkload(&fullDataRes, "sa-data", "prod", "Deployment", "data-processor", "main-proc", "processor:latest")
 
-	mt, it, rt, pat, wt, err := buildTables(fullDataRes)
+	mt, it, rt, pat, wt, _, err := buildTables(fullDataRes, DefaultOptions())
 	if err != nil {
 		t.Fatalf("buildTables() with full data returned error: %v", err)
 	}
@@ -203,7 +204,7 @@ func TestBuildTables_EmptyResult(t *testing.T) {
 	emptyData.RBACData.Data["rbac"] = make(map[string]map[string]extractor.ServiceAccountRBAC)
 	emptyData.WorkloadData.Data["workloads"] = make(map[string]map[string][]extractor.Workload)
 
-	mt, it, rt, pat, wt, err := buildTables(emptyData)
+	mt, it, rt, pat, wt, _, err := buildTables(emptyData, DefaultOptions())
 
 	if err != nil {
 		t.Fatalf("buildTables() with empty data returned error: %v", err)
@@ -320,7 +321,7 @@ func TestBuildTables_InvalidDataFormat(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			inputData := tt.setupResult()
-			_, _, _, _, _, err := buildTables(inputData)
+			_, _, _, _, _, _, err := buildTables(inputData, DefaultOptions())
 
 			if err == nil {
 				t.Fatalf("buildTables() expected error, got nil")
@@ -331,3 +332,118 @@ func TestBuildTables_InvalidDataFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildTables_Combinations(t *testing.T) {
+	res := newTableTestResult("combo-app", "v1", "src", time.Now().Unix())
+	res.IdentityData.Data["identities"] = make(map[string]map[string]extractor.Identity)
+	res.RBACData.Data["rbac"] = make(map[string]map[string]extractor.ServiceAccountRBAC)
+	res.WorkloadData.Data["workloads"] = make(map[string]map[string][]extractor.Workload)
+	addTableTestRBAC(&res, "sa-combo", "ns1", []extractor.RBACRole{
+		{Type: "Role", Name: "pod-creator", Namespace: "ns1", Permissions: extractor.RuleApiGroup{
+			"": {"pods": {"": {"create": {}}}},
+		}},
+		{Type: "Role", Name: "token-minter", Namespace: "ns1", Permissions: extractor.RuleApiGroup{
+			"": {"serviceaccounts/token": {"": {"create": {}}}},
+		}},
+	})
+
+	_, _, _, pat, _, _, err := buildTables(res, DefaultOptions())
+	if err != nil {
+		t.Fatalf("buildTables() returned error: %v", err)
+	}
+	rendered := renderTableForTest(pat)
+	if !strings.Contains(rendered, "Pod creation with service account token minting") {
+		t.Errorf("Potential abuse table missing combination finding:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "via: pod-creator,token-minter") {
+		t.Errorf("Potential abuse table missing triggering roles:\n%s", rendered)
+	}
+}
+
+func TestBuildTables_Subchart(t *testing.T) {
+	res := newTableTestResult("parent", "v1", "src", time.Now().Unix())
+	res.IdentityData.Data["identities"] = make(map[string]map[string]extractor.Identity)
+	res.RBACData.Data["rbac"] = make(map[string]map[string]extractor.ServiceAccountRBAC)
+	res.WorkloadData.Data["workloads"] = make(map[string]map[string][]extractor.Workload)
+	addTableTestRBAC(&res, "metrics", "monitoring", []extractor.RBACRole{
+		{Type: "Role", Name: "metrics-reader", Namespace: "monitoring", Subchart: "metrics", Permissions: extractor.RuleApiGroup{
+			"": {"pods": {"": {"list": {}}}},
+		}},
+	})
+
+	parsed, err := PrepareData(res, DefaultOptions())
+	if err != nil {
+		t.Fatalf("PrepareData() returned error: %v", err)
+	}
+	if len(parsed.RBACData) != 1 || parsed.RBACData[0].Subchart != "metrics" {
+		t.Fatalf("RBACData = %+v, want one entry of the metrics subchart", parsed.RBACData)
+	}
+
+	_, _, rbacTable, _, _, _, err := buildTables(res, DefaultOptions())
+	if err != nil {
+		t.Fatalf("buildTables() returned error: %v", err)
+	}
+	if rendered := renderTableForTest(rbacTable); !strings.Contains(rendered, "metrics-reader (subchart: metrics)") {
+		t.Errorf("RBAC table missing the subchart:\n%s", rendered)
+	}
+}
+
+func TestBuildTables_Provenance(t *testing.T) {
+	res := newTableTestResult("operator", "v1", "deploy", time.Now().Unix())
+	res.IdentityData.Data["identities"] = make(map[string]map[string]extractor.Identity)
+	res.RBACData.Data["rbac"] = make(map[string]map[string]extractor.ServiceAccountRBAC)
+	res.WorkloadData.Data["workloads"] = make(map[string]map[string][]extractor.Workload)
+	addTableTestRBAC(&res, "operator", "operators", []extractor.RBACRole{
+		{
+			Type: "ClusterRole", Name: "secret-reader", Namespace: "*",
+			Permissions:       extractor.RuleApiGroup{"": {"secrets": {"": {"get": {}}}}},
+			Provenance:        &types.Provenance{File: "deploy/role.yaml", Document: 2, Line: 8},
+			BindingProvenance: &types.Provenance{File: "operator/templates/binding.yaml", Document: 1},
+		},
+	})
+
+	parsed, err := PrepareData(res, DefaultOptions())
+	if err != nil {
+		t.Fatalf("PrepareData() returned error: %v", err)
+	}
+	if len(parsed.RBACData) != 1 || parsed.RBACData[0].RoleProvenance == nil || parsed.RBACData[0].BindingProvenance == nil {
+		t.Fatalf("RBACData = %+v, want one entry with the role and binding provenance", parsed.RBACData)
+	}
+
+	_, _, rbacTable, _, _, _, err := buildTables(res, DefaultOptions())
+	if err != nil {
+		t.Fatalf("buildTables() returned error: %v", err)
+	}
+	rendered := renderTableForTest(rbacTable)
+	for _, want := range []string{"SOURCE", "role: deploy/role.yaml:8", "binding: operator/templates/binding.yaml#1"} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("RBAC table missing %q:\n%s", want, rendered)
+		}
+	}
+}
+
+func TestCompareEntries(t *testing.T) {
+	entries := []SARoleBindingEntry{
+		{SubjectName: "web", Namespace: "ns1", RoleName: "reader", Resource: "pods", RiskLevel: "Low"},
+		{SubjectName: "operator", Namespace: "ns2", RoleName: "admin", Resource: "secrets", RiskLevel: "Critical"},
+		{SubjectName: "operator", Namespace: "ns1", RoleName: "reader", Resource: "pods", RiskLevel: "Low"},
+		{SubjectName: "alice", RoleName: "admin", Resource: "secrets", RiskLevel: "Critical"},
+		{SubjectName: "operator", Namespace: "ns1", RoleName: "reader", Resource: "configmaps", RiskLevel: "Low"},
+	}
+	slices.SortStableFunc(entries, compareEntries)
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, strings.Join([]string{e.RiskLevel, e.SubjectName, e.Namespace, e.Resource}, "/"))
+	}
+	want := []string{
+		"Critical/alice//secrets",
+		"Critical/operator/ns2/secrets",
+		"Low/operator/ns1/configmaps",
+		"Low/operator/ns1/pods",
+		"Low/web/ns1/pods",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("sorted entries = %v, want %v", got, want)
+	}
+}
@@ -1,6 +1,9 @@
 package formatter
 
-import "github.com/alevsk/rbac-scope/internal/policyevaluation"
+import (
+	"github.com/alevsk/rbac-scope/internal/policyevaluation"
+	"github.com/alevsk/rbac-scope/internal/types"
+)
 
 // Type represents the type of formatter
 type Type string
@@ -14,6 +17,8 @@ const (
 	TypeTable Type = "table"
 	// TypeMarkdown formats data as markdown
 	TypeMarkdown Type = "markdown"
+	// TypeSARIF formats data as SARIF 2.1.0
+	TypeSARIF Type = "sarif"
 )
 
 // JSON implements JSON formatting
@@ -36,6 +41,11 @@ type Markdown struct {
 	opts *Options
 }
 
+// SARIF implements SARIF formatting
+type SARIF struct {
+	opts *Options
+}
+
 type SAIdentityEntry struct {
 	ServiceAccountName string   `json:"serviceAccountName" yaml:"serviceAccountName"`
 	Namespace          string   `json:"namespace" yaml:"namespace"`
@@ -44,8 +54,18 @@ type SAIdentityEntry struct {
 	ImagePullSecrets   []string `json:"imagePullSecrets" yaml:"imagePullSecrets"`
 }
 
+// Subject kinds of a SARoleBindingEntry
+const (
+	SubjectKindServiceAccount = "ServiceAccount"
+	SubjectKindUser           = "User"
+	SubjectKindGroup          = "Group"
+)
+
+// SARoleBindingEntry is a single permission granted to a subject, a service account, user or group
 type SARoleBindingEntry struct {
-	ServiceAccountName string                    `json:"serviceAccountName" yaml:"serviceAccountName"`
+	SubjectName        string                    `json:"subjectName" yaml:"subjectName"`
+	SubjectKind        string                    `json:"subjectKind" yaml:"subjectKind"`
+	ServiceAccountName string                    `json:"serviceAccountName,omitempty" yaml:"serviceAccountName,omitempty"` // Empty for users and groups
 	Namespace          string                    `json:"namespace" yaml:"namespace"`
 	RoleType           string                    `json:"roleType" yaml:"roleType"`
 	RoleName           string                    `json:"roleName" yaml:"roleName"`
@@ -56,6 +76,13 @@ type SARoleBindingEntry struct {
 	RiskLevel          string                    `json:"riskLevel" yaml:"riskLevel"`
 	Tags               policyevaluation.RiskTags `json:"tags" yaml:"tags"`
 	MatchedRiskRules   []SARoleBindingRiskRule   `json:"matchedRiskRules" yaml:"matchedRiskRules"`
+	AggregatedFrom     []string                  `json:"aggregatedFrom,omitempty" yaml:"aggregatedFrom,omitempty"`
+	Rules              []SARoleBindingRule       `json:"rules,omitempty" yaml:"rules,omitempty"`                         // Rules of the role that grant the permission
+	Subchart           string                    `json:"subchart,omitempty" yaml:"subchart,omitempty"`                   // Helm subchart that rendered the role
+	RoleProvenance     *types.Provenance         `json:"roleProvenance,omitempty" yaml:"roleProvenance,omitempty"`       // File, document and line of the role
+	BindingProvenance  *types.Provenance         `json:"bindingProvenance,omitempty" yaml:"bindingProvenance,omitempty"` // File, document and line of the binding
+	Remediation        *Remediation              `json:"remediation,omitempty" yaml:"remediation,omitempty"`             // Least-privilege suggestion for high and critical permissions
+	Suppression        *Suppression              `json:"suppression,omitempty" yaml:"suppression,omitempty"`             // Baseline suppression accepting the finding
 }
 
 type SARoleBindingRiskRule struct {
@@ -64,6 +91,34 @@ type SARoleBindingRiskRule struct {
 	Link string `json:"link" yaml:"link"`
 }
 
+// SARoleBindingRule is a rule of a role as written in its manifest, Index is its position in
+// the rules of the role, or of the ClusterRole in AggregatedFrom when the rule was aggregated
+type SARoleBindingRule struct {
+	Index           int      `json:"index" yaml:"index"`
+	AggregatedFrom  string   `json:"aggregatedFrom,omitempty" yaml:"aggregatedFrom,omitempty"`
+	APIGroups       []string `json:"apiGroups" yaml:"apiGroups"`
+	Resources       []string `json:"resources" yaml:"resources"`
+	ResourceNames   []string `json:"resourceNames,omitempty" yaml:"resourceNames,omitempty"`
+	NonResourceURLs []string `json:"nonResourceURLs,omitempty" yaml:"nonResourceURLs,omitempty"`
+	Verbs           []string `json:"verbs" yaml:"verbs"`
+}
+
+// SACombinationEntry is a combination risk rule matched by the permissions of a subject
+type SACombinationEntry struct {
+	SubjectName        string                    `json:"subjectName" yaml:"subjectName"`
+	SubjectKind        string                    `json:"subjectKind" yaml:"subjectKind"`
+	ServiceAccountName string                    `json:"serviceAccountName,omitempty" yaml:"serviceAccountName,omitempty"` // Empty for users and groups
+	Namespace          string                    `json:"namespace" yaml:"namespace"`
+	Scope              string                    `json:"scope" yaml:"scope"` // Namespace where the combination holds, * for cluster-wide
+	RuleID             int64                     `json:"ruleId" yaml:"ruleId"`
+	RuleName           string                    `json:"ruleName" yaml:"ruleName"`
+	Link               string                    `json:"link" yaml:"link"`
+	RiskLevel          string                    `json:"riskLevel" yaml:"riskLevel"`
+	Tags               policyevaluation.RiskTags `json:"tags" yaml:"tags"`
+	Permissions        []int                     `json:"permissions" yaml:"permissions"`                     // Indexes of the serviceAccountPermissions entries that triggered the rule
+	Suppression        *Suppression              `json:"suppression,omitempty" yaml:"suppression,omitempty"` // Baseline suppression accepting the finding
+}
+
 type SAWorkloadEntry struct {
 	ServiceAccountName string `json:"serviceAccountName" yaml:"serviceAccountName"`
 	Namespace          string `json:"namespace" yaml:"namespace"`
@@ -86,4 +141,8 @@ type ParsedData struct {
 	IdentityData []SAIdentityEntry    `json:"serviceAccountData" yaml:"serviceAccountData"`
 	RBACData     []SARoleBindingEntry `json:"serviceAccountPermissions" yaml:"serviceAccountPermissions"`
 	WorkloadData []SAWorkloadEntry    `json:"serviceAccountWorkloads" yaml:"serviceAccountWorkloads"`
+	// CombinationData lists combination risk rules matched across all permissions of a subject
+	CombinationData []SACombinationEntry `json:"serviceAccountCombinations" yaml:"serviceAccountCombinations"`
+	// ExpiredSuppressions lists baseline suppressions past their expiry date, they no longer accept findings
+	ExpiredSuppressions []Suppression `json:"expiredSuppressions,omitempty" yaml:"expiredSuppressions,omitempty"`
 }
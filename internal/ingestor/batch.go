@@ -0,0 +1,176 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/extractor"
+	"github.com/alevsk/rbac-scope/internal/types"
+)
+
+// SourceResult is the outcome of the ingestion of one source of a batch
+type SourceResult struct {
+	// Source is the ingested source as given
+	Source string
+	// Result is the result of the source, nil when the ingestion failed
+	Result *Result
+	// Err is the ingestion error of the source
+	Err error
+}
+
+// IngestBatch ingests the sources concurrently, running at most MaxConcurrency ingestions at
+// a time. A failing source does not abort the batch, its error is recorded in its SourceResult.
+// The results are returned in the order of sources.
+func (i *Ingestor) IngestBatch(ctx context.Context, sources []string) []SourceResult {
+	concurrency := i.opts.MaxConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]SourceResult, len(sources))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for idx, source := range sources {
+		results[idx].Source = source
+		wg.Add(1)
+		go func(idx int, source string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[idx].Err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			result, err := i.Ingest(ctx, source)
+			if err != nil {
+				results[idx].Err = err
+				return
+			}
+			results[idx].Result = result
+		}(idx, source)
+	}
+	wg.Wait()
+	return results
+}
+
+// Merge combines the successful results into a single formatted result. Each source is
+// extracted on its own, so same-named roles of different sources do not shadow each other, and
+// its manifests record it under the "source" metadata key. The sources with their version or
+// error are listed under the "sources" extra key.
+func (i *Ingestor) Merge(ctx context.Context, results []SourceResult) (*Result, error) {
+	merged := &Result{
+		Name:         fmt.Sprintf("batch of %d sources", len(results)),
+		Success:      true,
+		Timestamp:    time.Now().Unix(),
+		IdentityData: newExtractedData(),
+		WorkloadData: newExtractedData(),
+		RBACData:     newExtractedData(),
+	}
+
+	sources := make([]map[string]interface{}, 0, len(results))
+	for _, r := range results {
+		if r.Err != nil || r.Result == nil {
+			sources = append(sources, map[string]interface{}{"source": r.Source, "error": fmt.Sprint(r.Err)})
+			continue
+		}
+		sources = append(sources, map[string]interface{}{"source": r.Source, "version": r.Result.Version})
+
+		source := &Result{Source: r.Result.Source}
+		for _, manifest := range r.Result.Manifests {
+			if manifest == nil {
+				continue
+			}
+			m := *manifest
+			m.Metadata = make(map[string]interface{}, len(manifest.Metadata)+1)
+			for k, v := range manifest.Metadata {
+				m.Metadata[k] = v
+			}
+			m.Metadata["source"] = r.Result.Source
+			source.Manifests = append(source.Manifests, &m)
+		}
+		if err := extract(ctx, source); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", r.Source, err)
+		}
+		merged.Manifests = append(merged.Manifests, source.Manifests...)
+		mergeExtractedData(merged.IdentityData, source.IdentityData)
+		mergeExtractedData(merged.WorkloadData, source.WorkloadData)
+		mergeExtractedData(merged.RBACData, source.RBACData)
+	}
+	merged.Extra = map[string]interface{}{"sources": sources}
+
+	if i.opts.SkipFormat {
+		return merged, nil
+	}
+	if err := i.format(merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+func newExtractedData() *types.ExtractedData {
+	return &types.ExtractedData{
+		Data:     make(map[string]interface{}),
+		Metadata: make(map[string]interface{}),
+	}
+}
+
+// mergeExtractedData adds the data extracted from one source to dst, appending the entries of
+// subjects, workloads and roles found in several sources and summing the counts
+func mergeExtractedData(dst, src *types.ExtractedData) {
+	if src == nil {
+		return
+	}
+	for key, value := range src.Data {
+		switch v := value.(type) {
+		case map[string]map[string]extractor.Identity:
+			mergeNamespaced(dst.Data, key, v, func(a, b extractor.Identity) extractor.Identity { return b })
+		case map[string]map[string][]extractor.Workload:
+			mergeNamespaced(dst.Data, key, v, func(a, b []extractor.Workload) []extractor.Workload { return append(a, b...) })
+		case map[string]map[string]extractor.ServiceAccountRBAC:
+			mergeNamespaced(dst.Data, key, v, func(a, b extractor.ServiceAccountRBAC) extractor.ServiceAccountRBAC {
+				return extractor.ServiceAccountRBAC{Roles: append(a.Roles, b.Roles...)}
+			})
+		case []extractor.RBACRole:
+			roles, _ := dst.Data[key].([]extractor.RBACRole)
+			dst.Data[key] = append(roles, v...)
+		case []extractor.RBACBinding:
+			bindings, _ := dst.Data[key].([]extractor.RBACBinding)
+			dst.Data[key] = append(bindings, v...)
+		default:
+			dst.Data[key] = value
+		}
+	}
+	for key, value := range src.Metadata {
+		if n, ok := value.(int); ok {
+			count, _ := dst.Metadata[key].(int)
+			dst.Metadata[key] = count + n
+			continue
+		}
+		dst.Metadata[key] = value
+	}
+}
+
+// mergeNamespaced merges the entries of src keyed by name and namespace into data[key], combining
+// the entries present in both
+func mergeNamespaced[T any](data map[string]interface{}, key string, src map[string]map[string]T, combine func(a, b T) T) {
+	dst, _ := data[key].(map[string]map[string]T)
+	if dst == nil {
+		dst = make(map[string]map[string]T, len(src))
+		data[key] = dst
+	}
+	for name, byNamespace := range src {
+		if dst[name] == nil {
+			dst[name] = make(map[string]T, len(byNamespace))
+		}
+		for ns, entry := range byNamespace {
+			if existing, ok := dst[name][ns]; ok {
+				entry = combine(existing, entry)
+			}
+			dst[name][ns] = entry
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package ingestor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alevsk/rbac-scope/internal/extractor"
+)
+
+func TestIngestBatch(t *testing.T) {
+	sources := []string{
+		"testdata/valid.yaml",
+		"testdata/missing.yaml",
+		"../renderer/testdata/cluster-role.yaml",
+		"testdata/truly_invalid.yaml",
+	}
+
+	for _, concurrency := range []int{0, 1, 4} {
+		opts := DefaultOptions()
+		opts.OutputFormat = "json"
+		opts.MaxConcurrency = concurrency
+		results := New(opts).IngestBatch(context.Background(), sources)
+
+		if len(results) != len(sources) {
+			t.Fatalf("concurrency %d: got %d results, want %d", concurrency, len(results), len(sources))
+		}
+		for idx, r := range results {
+			if r.Source != sources[idx] {
+				t.Errorf("concurrency %d: result %d source = %s, want %s", concurrency, idx, r.Source, sources[idx])
+			}
+			wantErr := idx == 1 || idx == 3
+			if (r.Err != nil) != wantErr || (r.Result == nil) != wantErr {
+				t.Errorf("concurrency %d: %s error = %v, wantErr %v", concurrency, r.Source, r.Err, wantErr)
+			}
+			if r.Result != nil && r.Result.OutputFormatted == "" {
+				t.Errorf("concurrency %d: %s has no formatted output", concurrency, r.Source)
+			}
+		}
+	}
+}
+
+func TestIngestBatch_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range New(nil).IngestBatch(ctx, []string{"testdata/valid.yaml", "testdata/valid.yaml"}) {
+		if r.Err == nil {
+			t.Errorf("expected an error for %s with a canceled context", r.Source)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	opts := DefaultOptions()
+	opts.OutputFormat = "json"
+	i := New(opts)
+
+	// The sources are not formatted, only the merged result is
+	unformatted := *opts
+	unformatted.SkipFormat = true
+	results := New(&unformatted).IngestBatch(context.Background(), []string{
+		"testdata/valid.yaml",
+		"../renderer/testdata/cluster-role.yaml",
+		"testdata/missing.yaml",
+	})
+	for _, r := range results {
+		if r.Result != nil && r.Result.OutputFormatted != "" {
+			t.Errorf("%s was formatted with SkipFormat", r.Source)
+		}
+	}
+
+	merged, err := i.Merge(context.Background(), results)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if merged.Name != "batch of 3 sources" || merged.OutputFormatted == "" {
+		t.Errorf("unexpected merged result %q with output %q", merged.Name, merged.OutputFormatted)
+	}
+
+	wantManifests := len(results[0].Result.Manifests) + len(results[1].Result.Manifests)
+	if len(merged.Manifests) != wantManifests {
+		t.Fatalf("got %d manifests, want %d", len(merged.Manifests), wantManifests)
+	}
+	for _, m := range merged.Manifests {
+		if source, _ := m.Metadata["source"].(string); source != results[0].Result.Source && source != results[1].Result.Source {
+			t.Errorf("manifest %s has source %q", m.Name, source)
+		}
+	}
+	if _, ok := results[0].Result.Manifests[0].Metadata["source"]; ok {
+		t.Error("Merge() modified the metadata of the source manifests")
+	}
+
+	sources, _ := merged.Extra["sources"].([]map[string]interface{})
+	if len(sources) != 3 || sources[2]["error"] == nil || sources[0]["version"] != results[0].Result.Version {
+		t.Errorf("unexpected sources %v", merged.Extra["sources"])
+	}
+
+	rbac, _ := merged.RBACData.Data["rbac"].(map[string]map[string]extractor.ServiceAccountRBAC)
+	if _, ok := rbac["pod-reader"]; !ok {
+		t.Errorf("merged RBAC data is missing the bindings of the second source: %v", rbac)
+	}
+}
+
+func TestMerge_CollidingRoleNames(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	writeFile(t, a, `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: a
+  namespace: default
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: manager
+rules:
+- apiGroups: [""]
+  resources: ["configmaps"]
+  verbs: ["get"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: manager
+subjects:
+- kind: ServiceAccount
+  name: a
+  namespace: default
+`)
+	writeFile(t, b, `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: manager
+rules:
+- apiGroups: [""]
+  resources: ["secrets"]
+  verbs: ["*"]
+`)
+
+	opts := DefaultOptions()
+	opts.SkipFormat = true
+	i := New(opts)
+	merged, err := i.Merge(context.Background(), i.IngestBatch(context.Background(), []string{a, b}))
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	rbac, _ := merged.RBACData.Data["rbac"].(map[string]map[string]extractor.ServiceAccountRBAC)
+	roles := rbac["a"]["default"].Roles
+	if len(roles) != 1 {
+		t.Fatalf("got %d roles for a, want 1: %v", len(roles), roles)
+	}
+	if _, ok := roles[0].Permissions[""]["secrets"]; ok {
+		t.Errorf("a was granted the manager ClusterRole of the other source: %v", roles[0].Permissions)
+	}
+	if _, ok := roles[0].Permissions[""]["configmaps"]; !ok {
+		t.Errorf("a is missing configmaps from its own manager ClusterRole: %v", roles[0].Permissions)
+	}
+	if got, _ := merged.RBACData.Metadata["roleCount"].(int); got != 2 {
+		t.Errorf("roleCount = %d, want 2", got)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,10 +4,12 @@ package ingestor
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/alevsk/rbac-scope/internal/extractor"
 	"github.com/alevsk/rbac-scope/internal/formatter"
+	"github.com/alevsk/rbac-scope/internal/renderer"
 	"github.com/alevsk/rbac-scope/internal/resolver"
 	"github.com/alevsk/rbac-scope/internal/types"
 )
@@ -24,8 +26,44 @@ type Options struct {
 	OutputFormat string
 	// IncludeMetadata determines if metadata should be included in the output
 	IncludeMetadata bool
-	// Values is a file path to a values.yaml file used for rendering a helm chart
-	Values string
+	// Values are the value files and overrides used for rendering a helm chart
+	Values renderer.ValuesOptions
+	// ReleaseName is the helm release name used for rendering charts
+	ReleaseName string
+	// Namespace is the helm release namespace used for rendering charts
+	Namespace string
+	// KubeVersion is the Kubernetes version exposed to chart templates
+	KubeVersion string
+	// APIVersions are additional API versions exposed to chart templates
+	APIVersions []string
+	// DependencyDir is a directory with packaged charts used for chart dependencies that are not vendored
+	DependencyDir string
+	// KustomizeRoot bounds the directories Kustomize overlays and their bases may be read from
+	KustomizeRoot string
+	// KustomizeHelm enables the helmCharts generator of Kustomize overlays
+	KustomizeHelm bool
+	// RenderMode selects how directory sources are rendered: auto, discover, helm, kustomize or yaml
+	RenderMode string
+	// Chart selects a chart of a Helm repository source as name or name@version
+	Chart string
+	// PlainHTTP uses HTTP instead of HTTPS to pull charts from OCI registries
+	PlainHTTP bool
+	// Kubeconfig is the kubeconfig file used for cluster sources
+	Kubeconfig string
+	// Namespaces limits the namespaced objects read from cluster sources
+	Namespaces []string
+	// ExcludeNamespaces are namespaces whose objects are not read from cluster sources
+	ExcludeNamespaces []string
+	// SnapshotPath is a file where the objects read from a cluster source are saved as YAML
+	SnapshotPath string
+	// Stdin is the reader used when the source is "-", os.Stdin when nil
+	Stdin io.Reader
+	// Baseline holds accepted findings that are marked as suppressed in the output
+	Baseline *formatter.Baseline
+	// HideSuppressed removes suppressed findings from the output
+	HideSuppressed bool
+	// SkipFormat leaves OutputFormatted empty, for callers that format or merge the results themselves
+	SkipFormat bool
 }
 
 // DefaultOptions returns the default ingestor options
@@ -36,7 +74,6 @@ func DefaultOptions() *Options {
 		ValidateYAML:    true,
 		OutputFormat:    "table",
 		IncludeMetadata: true,
-		Values:          "",
 	}
 }
 
@@ -67,14 +104,44 @@ type Result = types.Result
 // Ingest starts the ingestion process from the given source
 // The context can be used to cancel the operation
 func (i *Ingestor) Ingest(ctx context.Context, source string) (*Result, error) {
+	result, err := i.analyze(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if i.opts.SkipFormat {
+		return result, nil
+	}
+	if err := i.format(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// analyze resolves the source and extracts its identities, workloads and RBAC policies
+func (i *Ingestor) analyze(ctx context.Context, source string) (*Result, error) {
 	if source == "" {
 		return nil, ErrInvalidSource
 	}
 
 	opts := &resolver.Options{
-		ValidateYAML:   i.opts.ValidateYAML,
-		FollowSymlinks: i.opts.FollowSymlinks,
-		Values:         i.opts.Values,
+		ValidateYAML:      i.opts.ValidateYAML,
+		FollowSymlinks:    i.opts.FollowSymlinks,
+		Values:            i.opts.Values,
+		ReleaseName:       i.opts.ReleaseName,
+		Namespace:         i.opts.Namespace,
+		KubeVersion:       i.opts.KubeVersion,
+		APIVersions:       i.opts.APIVersions,
+		DependencyDir:     i.opts.DependencyDir,
+		KustomizeRoot:     i.opts.KustomizeRoot,
+		KustomizeHelm:     i.opts.KustomizeHelm,
+		RenderMode:        resolver.RenderMode(i.opts.RenderMode),
+		Chart:             i.opts.Chart,
+		PlainHTTP:         i.opts.PlainHTTP,
+		Kubeconfig:        i.opts.Kubeconfig,
+		Namespaces:        i.opts.Namespaces,
+		ExcludeNamespaces: i.opts.ExcludeNamespaces,
+		SnapshotPath:      i.opts.SnapshotPath,
+		Stdin:             i.opts.Stdin,
 	}
 	// Get the appropriate resolver for this source
 	r, err := resolver.ResolverFactory(source, opts)
@@ -82,93 +149,103 @@ func (i *Ingestor) Ingest(ctx context.Context, source string) (*Result, error) {
 		return nil, err
 	}
 
+	// Resolve the source
+	renderedResult, metadata, err := r.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Version:   metadata.Version,
+		Name:      metadata.Name,
+		Source:    metadata.Path,
+		Success:   true,
+		Timestamp: time.Now().Unix(),
+		Manifests: renderedResult.Manifests,
+		Extra:     metadata.Extra,
+	}
+	if err := extract(ctx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// extract runs the identity, workload and RBAC extractors over the manifests of result
+func extract(ctx context.Context, result *Result) error {
 	// Create extractors
 	ef := extractor.NewExtractorFactory()
 
 	identityExtractor, err := ef.NewExtractor("identity", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create identity extractor: %w", err)
+		return fmt.Errorf("failed to create identity extractor: %w", err)
 	}
 
 	workloadExtractor, err := ef.NewExtractor("workload", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create workload extractor: %w", err)
+		return fmt.Errorf("failed to create workload extractor: %w", err)
 	}
 
 	rbacExtractor, err := ef.NewExtractor("rbac", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create RBAC extractor: %w", err)
-	}
-
-	// Resolve the source
-	renderedResult, metadata, err := r.Resolve(ctx)
-	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to create RBAC extractor: %w", err)
 	}
 
 	// Extract data using each extractor
-	identityData, err := identityExtractor.Extract(ctx, renderedResult.Manifests)
+	identityData, err := identityExtractor.Extract(ctx, result.Manifests)
 	if err != nil {
-		return nil, fmt.Errorf("identity extraction failed: %w", err)
+		return fmt.Errorf("identity extraction failed: %w", err)
 	}
 
-	workloadData, err := workloadExtractor.Extract(ctx, renderedResult.Manifests)
+	workloadData, err := workloadExtractor.Extract(ctx, result.Manifests)
 	if err != nil {
-		return nil, fmt.Errorf("workload extraction failed: %w", err)
+		return fmt.Errorf("workload extraction failed: %w", err)
 	}
 
-	rbacData, err := rbacExtractor.Extract(ctx, renderedResult.Manifests)
+	rbacData, err := rbacExtractor.Extract(ctx, result.Manifests)
 	if err != nil {
-		return nil, fmt.Errorf("RBAC extraction failed: %w", err)
+		return fmt.Errorf("RBAC extraction failed: %w", err)
 	}
 
 	// Convert extractor results to ExtractedData
-	identityExtracted := &types.ExtractedData{
+	result.IdentityData = &types.ExtractedData{
 		Data:     identityData.Data,
 		Metadata: identityData.Metadata,
 	}
-	workloadExtracted := &types.ExtractedData{
+	result.WorkloadData = &types.ExtractedData{
 		Data:     workloadData.Data,
 		Metadata: workloadData.Metadata,
 	}
-	rbacExtracted := &types.ExtractedData{
+	result.RBACData = &types.ExtractedData{
 		Data:     rbacData.Data,
 		Metadata: rbacData.Metadata,
 	}
+	return nil
+}
 
-	// Create result
-	result := types.Result{
-		Version:      metadata.Version,
-		Name:         metadata.Name,
-		Source:       metadata.Path,
-		Success:      true,
-		Timestamp:    time.Now().Unix(),
-		IdentityData: identityExtracted,
-		WorkloadData: workloadExtracted,
-		RBACData:     rbacExtracted,
-		Extra:        metadata.Extra,
-	}
-
+// format sets the output of result in the configured output format
+func (i *Ingestor) format(result *Result) error {
 	fOpts := &formatter.Options{
 		IncludeMetadata: i.opts.IncludeMetadata,
+		Baseline:        i.opts.Baseline,
+		HideSuppressed:  i.opts.HideSuppressed,
 	}
 
 	// Format the result using the specified output format
 	formatType, err := formatter.ParseType(i.opts.OutputFormat)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse formatter type: %w", err)
+		return fmt.Errorf("failed to parse formatter type: %w", err)
 	}
 
 	f, err := formatter.NewFormatter(formatType, fOpts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create formatter: %w", err)
+		return fmt.Errorf("failed to create formatter: %w", err)
 	}
 
-	formatted, err := f.Format(result)
+	formatted, err := f.Format(*result)
 	if err != nil {
-		return nil, fmt.Errorf("failed to format result: %w", err)
+		return fmt.Errorf("failed to format result: %w", err)
 	}
 
 	result.OutputFormatted = formatted
-	return &result, nil
+	return nil
 }
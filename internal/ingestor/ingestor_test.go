@@ -72,7 +72,7 @@ func TestIngest(t *testing.T) {
 			wantErr: true,
 			// Error will be from resolver.ResolverFactory, specific message depends on factory logic
 			// e.g., "no suitable resolver found for source: testdata/resolver_error.txt"
-			// or "URL does not point to a YAML file" if it were a URL.
+			// or "URL does not point to a YAML or JSON file" if it were a URL.
 			// For now, just check wantErr is true. We can refine errType later if needed.
 		},
 		{
@@ -0,0 +1,110 @@
+package policyevaluation
+
+import (
+	"sort"
+)
+
+// CombinationMatch is a combination rule matched by the permissions of a subject
+type CombinationMatch struct {
+	Rule RiskRule
+	// Namespace is where the combination holds, "*" when all permissions are cluster-wide
+	Namespace string
+	// Policies are the indexes of the policies that satisfied the rule requirements
+	Policies []int
+}
+
+// MatchCombinationRules evaluates all permissions held by a subject against the
+// combination risk rules. A rule matches when every requirement is satisfied by
+// at least one policy within the same scope: either cluster-wide, or a namespace
+// where cluster-wide permissions also apply. Matches are sorted by risk level
+// (highest to lowest), rule ID and namespace.
+func MatchCombinationRules(policies []Policy) []CombinationMatch {
+	var matches []CombinationMatch
+	for _, rule := range GetCombinationRules() {
+		matches = append(matches, matchCombinationRule(policies, rule)...)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Rule.RiskLevel != matches[j].Rule.RiskLevel {
+			return matches[i].Rule.RiskLevel > matches[j].Rule.RiskLevel
+		}
+		if matches[i].Rule.ID != matches[j].Rule.ID {
+			return matches[i].Rule.ID < matches[j].Rule.ID
+		}
+		return matches[i].Namespace < matches[j].Namespace
+	})
+	return matches
+}
+
+// matchCombinationRule returns the scopes in which policies satisfy all requirements of rule
+func matchCombinationRule(policies []Policy, rule RiskRule) []CombinationMatch {
+	// Policies satisfying each requirement
+	candidates := make([][]int, len(rule.Requires))
+	namespaces := make(map[string]struct{})
+	for i, req := range rule.Requires {
+		reqRule := RiskRule{
+			RoleType:   rule.RoleType,
+			APIGroups:  req.APIGroups,
+			Resources:  req.Resources,
+			Verbs:      req.Verbs,
+			VerbGroups: req.VerbGroups,
+		}
+		for j := range policies {
+			if matchesCustomRule(&policies[j], &reqRule) {
+				candidates[i] = append(candidates[i], j)
+				if !isClusterScoped(&policies[j]) {
+					namespaces[policies[j].Namespace] = struct{}{}
+				}
+			}
+		}
+		if len(candidates[i]) == 0 {
+			return nil
+		}
+	}
+
+	// A cluster-wide match covers every namespace, there is no need to report them
+	if match, ok := matchInScope(policies, candidates, "*"); ok {
+		match.Rule = rule
+		return []CombinationMatch{match}
+	}
+
+	scopes := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		scopes = append(scopes, ns)
+	}
+	sort.Strings(scopes)
+
+	var matches []CombinationMatch
+	for _, ns := range scopes {
+		if match, ok := matchInScope(policies, candidates, ns); ok {
+			match.Rule = rule
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// matchInScope checks that every requirement has a candidate policy that applies to namespace
+func matchInScope(policies []Policy, candidates [][]int, namespace string) (CombinationMatch, bool) {
+	match := CombinationMatch{Namespace: namespace}
+	seen := make(map[int]struct{})
+	for _, reqCandidates := range candidates {
+		found := false
+		for _, idx := range reqCandidates {
+			clusterScoped := isClusterScoped(&policies[idx])
+			if !clusterScoped && (namespace == "*" || policies[idx].Namespace != namespace) {
+				continue
+			}
+			found = true
+			if _, ok := seen[idx]; !ok {
+				seen[idx] = struct{}{}
+				match.Policies = append(match.Policies, idx)
+			}
+		}
+		if !found {
+			return CombinationMatch{}, false
+		}
+	}
+	sort.Ints(match.Policies)
+	return match, true
+}
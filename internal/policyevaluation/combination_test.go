@@ -0,0 +1,109 @@
+package policyevaluation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchCombinationRules(t *testing.T) {
+	if err := loadRiskRules(); err != nil {
+		t.Fatalf("loadRiskRules() error = %v", err)
+	}
+	t.Cleanup(ResetRiskRules)
+
+	listSecrets := func(roleType, ns string) Policy {
+		return Policy{Namespace: ns, RoleType: roleType, APIGroup: "", Resource: "secrets", Verbs: []string{"list"}}
+	}
+	execPods := func(roleType, ns string) Policy {
+		return Policy{Namespace: ns, RoleType: roleType, APIGroup: "", Resource: "pods/exec", Verbs: []string{"create"}}
+	}
+	readConfigMaps := Policy{Namespace: "ns1", RoleType: "Role", APIGroup: "", Resource: "configmaps", Verbs: []string{"get"}}
+
+	type want struct {
+		id        int64
+		namespace string
+		policies  []int
+	}
+	tests := []struct {
+		name     string
+		policies []Policy
+		want     []want
+	}{
+		{
+			name:     "same namespace",
+			policies: []Policy{readConfigMaps, listSecrets("Role", "ns1"), execPods("Role", "ns1")},
+			want:     []want{{2000, "ns1", []int{1, 2}}},
+		},
+		{
+			name:     "different namespaces",
+			policies: []Policy{listSecrets("Role", "ns1"), execPods("Role", "ns2")},
+			want:     nil,
+		},
+		{
+			name:     "cluster-wide permission applies to every namespace",
+			policies: []Policy{listSecrets("ClusterRole", "*"), execPods("Role", "ns2")},
+			want:     []want{{2000, "ns2", []int{0, 1}}},
+		},
+		{
+			name:     "cluster-wide combination",
+			policies: []Policy{listSecrets("ClusterRole", "*"), execPods("ClusterRole", "*"), execPods("Role", "ns1")},
+			want:     []want{{2000, "*", []int{0, 1}}},
+		},
+		{
+			name: "ClusterRole bound to a namespace",
+			policies: []Policy{
+				{Namespace: "ns1", RoleType: "ClusterRole", BoundToNamespace: true, APIGroup: "", Resource: "secrets", Verbs: []string{"get"}},
+				execPods("Role", "ns1"),
+			},
+			want: []want{{2000, "ns1", []int{0, 1}}},
+		},
+		{
+			name: "wildcard permission satisfies several requirements",
+			policies: []Policy{
+				{Namespace: "ns1", RoleType: "Role", APIGroup: "", Resource: "*", Verbs: []string{"*"}},
+			},
+			want: []want{{2000, "ns1", []int{0}}, {2001, "ns1", []int{0}}},
+		},
+		{
+			name: "rbac escalation",
+			policies: []Policy{
+				{Namespace: "ns1", RoleType: "Role", APIGroup: "rbac.authorization.k8s.io", Resource: "roles", Verbs: []string{"bind"}},
+				{Namespace: "ns1", RoleType: "Role", APIGroup: "rbac.authorization.k8s.io", Resource: "rolebindings", Verbs: []string{"create", "get"}},
+			},
+			want: []want{{2002, "ns1", []int{0, 1}}},
+		},
+		{
+			name:     "single requirement",
+			policies: []Policy{listSecrets("ClusterRole", "*")},
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []want
+			for _, m := range MatchCombinationRules(tt.policies) {
+				got = append(got, want{m.Rule.ID, m.Namespace, m.Policies})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchCombinationRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCombinationRules(t *testing.T) {
+	if err := loadRiskRules(); err != nil {
+		t.Fatalf("loadRiskRules() error = %v", err)
+	}
+	for _, rule := range GetCombinationRules() {
+		if rule.Kind != RuleKindCombination {
+			t.Errorf("rule %d has kind %q", rule.ID, rule.Kind)
+		}
+	}
+	for _, rule := range GetRiskRules() {
+		if rule.Kind == RuleKindCombination {
+			t.Errorf("combination rule %d returned as a permission rule", rule.ID)
+		}
+	}
+}
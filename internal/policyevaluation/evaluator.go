@@ -2,6 +2,8 @@ package policyevaluation
 
 import (
 	"fmt"
+	"path"
+	"slices"
 	"sort"
 	"strings"
 
@@ -30,7 +32,7 @@ func containsWildcardInSlice(items []string) bool {
 
 // isClusterScoped determines if a policy is cluster-scoped based on RoleType and Namespace
 func isClusterScoped(policy *Policy) bool {
-	return policy.RoleType == "ClusterRole" || policy.Namespace == ""
+	return (policy.RoleType == "ClusterRole" && !policy.BoundToNamespace) || policy.Namespace == ""
 }
 
 // determineBaseRiskRule evaluates a policy against base risk rules
@@ -66,7 +68,7 @@ func determineBaseRiskRule(policy *Policy) RiskRule {
 // matchesAPIGroups checks if policy's APIGroup matches any of rule's APIGroups
 func matchesAPIGroups(policy *Policy, rule *RiskRule) bool {
 	// Case 1: If rule has wildcard, policy must have wildcard
-	if containsWildcardInSlice(rule.APIGroups) {
+	if slices.Contains(rule.APIGroups, "*") {
 		if policy.APIGroup != "*" {
 			logger.Debug().Msg("Rule has wildcard APIGroup but policy doesn't")
 			return false
@@ -94,12 +96,19 @@ func matchesAPIGroups(policy *Policy, rule *RiskRule) bool {
 		return false
 	}
 
-	// Case 4: Check if policy's APIGroup matches any of rule's APIGroups
+	// Case 4: Check if policy's APIGroup matches any of rule's APIGroups,
+	// rule groups such as *.example.com match any group with that suffix
 	for _, ruleGroup := range rule.APIGroups {
 		if ruleGroup == policy.APIGroup || (ruleGroup == "" && policy.APIGroup == "") {
 			logger.Debug().Msgf("Rule's APIGroup %s matches policy's APIGroup %s", ruleGroup, policy.APIGroup)
 			return true
 		}
+		if containsWildcard(ruleGroup) {
+			if matched, _ := path.Match(ruleGroup, policy.APIGroup); matched {
+				logger.Debug().Msgf("Rule's APIGroup pattern %s matches policy's APIGroup %s", ruleGroup, policy.APIGroup)
+				return true
+			}
+		}
 	}
 
 	logger.Debug().Msgf("No rule's APIGroup matches policy's APIGroup %s", policy.APIGroup)
@@ -198,8 +207,8 @@ func matchesVerbs(policy *Policy, rule *RiskRule) bool {
 // matchesCustomRule checks if a policy matches a custom risk rule
 func matchesCustomRule(policy *Policy, rule *RiskRule) bool {
 	// RoleType must match exactly, except for Role vs ClusterRole
-	if policy.RoleType == "Role" && rule.RoleType == "ClusterRole" {
-		// A Role cannot match a ClusterRole rule
+	if (policy.RoleType == "Role" || policy.BoundToNamespace) && rule.RoleType == "ClusterRole" {
+		// A Role, or a ClusterRole granted through a RoleBinding, cannot match a ClusterRole rule
 		logger.Debug().Msgf("RoleType mismatch: rule=%s, policy=%s", rule.RoleType, policy.RoleType)
 		return false
 	}
@@ -129,6 +129,21 @@ func TestMatchRiskRules(t *testing.T) {
 			testType:      "count",
 			wantCount:     2,
 		},
+		{
+			name: "ClusterRole pod exec bound to a namespace",
+			policy: Policy{
+				RoleType:         "ClusterRole",
+				Namespace:        "default",
+				APIGroup:         "",
+				Resource:         "pods/exec",
+				Verbs:            []string{"create"},
+				BoundToNamespace: true,
+			},
+			wantErr:       false,
+			wantRiskLevel: RiskLevelHigh,
+			testType:      "count",
+			wantCount:     2,
+		},
 		{
 			name: "Cluster-wide pod attach",
 			policy: Policy{
@@ -2015,6 +2030,11 @@ func TestIsClusterScoped(t *testing.T) {
 			policy: Policy{RoleType: "Role", Namespace: "default"},
 			want:   false,
 		},
+		{
+			name:   "ClusterRole bound to a namespace",
+			policy: Policy{RoleType: "ClusterRole", Namespace: "default", BoundToNamespace: true},
+			want:   false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -2066,6 +2086,11 @@ func TestDetermineBaseRiskLevel(t *testing.T) {
 			policy: Policy{RoleType: "Role", Namespace: "default", APIGroup: "", Resource: "pods", Verbs: []string{"*"}},
 			want:   RiskLevelMedium,
 		},
+		{
+			name:   "Medium - ClusterRole bound to a namespace, all wildcards",
+			policy: Policy{RoleType: "ClusterRole", Namespace: "default", BoundToNamespace: true, APIGroup: "*", Resource: "*", Verbs: []string{"*"}},
+			want:   RiskLevelMedium,
+		},
 		{
 			name:   "Low - Namespaced Role, no wildcards",
 			policy: Policy{RoleType: "Role", Namespace: "default", APIGroup: "", Resource: "pods", Verbs: []string{"get"}},
@@ -2151,6 +2176,19 @@ func TestMatchesAPIGroups(t *testing.T) {
 			rule:   RiskRule{APIGroups: []string{"apps", "extensions"}},
 			want:   false,
 		},
+		// Pattern matching
+		{
+			name:   "Policy specific, rule suffix pattern (match)",
+			policy: Policy{APIGroup: "widgets.internal.example.com"},
+			rule:   RiskRule{APIGroups: []string{"*.internal.example.com"}},
+			want:   true,
+		},
+		{
+			name:   "Policy specific, rule suffix pattern (no match)",
+			policy: Policy{APIGroup: "apps"},
+			rule:   RiskRule{APIGroups: []string{"*.internal.example.com"}},
+			want:   false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -3,6 +3,12 @@ package policyevaluation
 import (
 	_ "embed"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,9 +16,18 @@ import (
 //go:embed risks.yaml
 var risksYAMLBytes []byte
 
-// riskRules contains the loaded risk rules from the YAML file.
-// It is unexported to prevent direct modification from other packages.
-var riskRules []RiskRule
+var (
+	// rulesMu guards riskRules, combinationRules and builtinRiskRules
+	rulesMu sync.RWMutex
+	// riskRules contains the permission risk rules used for evaluation, the built-in
+	// rules from the YAML file plus any custom rules.
+	// It is unexported to prevent direct modification from other packages.
+	riskRules []RiskRule
+	// combinationRules contains the combination risk rules used for evaluation
+	combinationRules []RiskRule
+	// builtinRiskRules contains all rules loaded from the embedded YAML file
+	builtinRiskRules []RiskRule
+)
 
 // validateRiskRule ensures a risk rule has all required fields.
 func validateRiskRule(rule RiskRule) error {
@@ -28,9 +43,42 @@ func validateRiskRule(rule RiskRule) error {
 	if rule.RiskLevel < RiskLevelLow || rule.RiskLevel > RiskLevelCritical {
 		return fmt.Errorf("invalid risk level %d in rule %q", rule.RiskLevel, rule.Name)
 	}
+	switch rule.Kind {
+	case "", RuleKindPermission:
+		if len(rule.Requires) > 0 {
+			return fmt.Errorf("rule %q sets requires but is not a combination rule", rule.Name)
+		}
+	case RuleKindCombination:
+		if len(rule.Requires) < 2 {
+			return fmt.Errorf("combination rule %q must require at least two permissions", rule.Name)
+		}
+		for i, req := range rule.Requires {
+			if len(req.APIGroups) == 0 || len(req.Resources) == 0 {
+				return fmt.Errorf("requirement %d of rule %q missing api groups or resources", i, rule.Name)
+			}
+			if len(req.Verbs) == 0 && len(req.VerbGroups) == 0 {
+				return fmt.Errorf("requirement %d of rule %q missing verbs", i, rule.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid kind %q in rule %q", rule.Kind, rule.Name)
+	}
 	return nil
 }
 
+// setRiskRules splits rules by kind into the rule sets used for evaluation.
+// Callers must hold rulesMu.
+func setRiskRules(rules []RiskRule) {
+	riskRules, combinationRules = nil, nil
+	for _, rule := range rules {
+		if rule.Kind == RuleKindCombination {
+			combinationRules = append(combinationRules, rule)
+		} else {
+			riskRules = append(riskRules, rule)
+		}
+	}
+}
+
 // loadRiskRules loads and validates the risk rules from the embedded YAML.
 // It returns an error if the YAML is invalid or if any rule is invalid.
 func loadRiskRules() error {
@@ -46,18 +94,158 @@ func loadRiskRules() error {
 		}
 	}
 
-	riskRules = rules
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	builtinRiskRules = rules
+	setRiskRules(rules)
 	return nil
 }
 
 // GetRiskRules returns a copy of the loaded risk rules.
 // This prevents external packages from modifying the rules directly.
 func GetRiskRules() []RiskRule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
 	rulesCopy := make([]RiskRule, len(riskRules))
 	copy(rulesCopy, riskRules)
 	return rulesCopy
 }
 
+// GetCombinationRules returns a copy of the loaded combination risk rules
+func GetCombinationRules() []RiskRule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	rulesCopy := make([]RiskRule, len(combinationRules))
+	copy(rulesCopy, combinationRules)
+	return rulesCopy
+}
+
+// GetRiskRuleByID returns the loaded permission, combination or base risk rule with the given ID
+func GetRiskRuleByID(id int64) (RiskRule, bool) {
+	for _, base := range []RiskRule{BaseRiskRuleCritical, BaseRiskRuleHigh, BaseRiskRuleMedium, BaseRiskRuleLow} {
+		if base.ID == id {
+			return base, true
+		}
+	}
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	for _, rules := range [][]RiskRule{riskRules, combinationRules} {
+		for _, rule := range rules {
+			if rule.ID == id {
+				return rule, true
+			}
+		}
+	}
+	return RiskRule{}, false
+}
+
+// LoadCustomRiskRules loads risk rules from the given files and directories and
+// makes them available for evaluation. Directories are walked recursively and
+// every .yaml or .yml file in them is loaded. Files may contain permission and
+// combination rules. Custom rules are merged with the
+// built-in rules unless replace is true, in which case only the custom rules are used.
+// Rules are validated and IDs must be unique across all files and, when merging,
+// must not conflict with the built-in rules.
+func LoadCustomRiskRules(paths []string, replace bool) error {
+	var files []string
+	for _, path := range paths {
+		found, err := riskRuleFiles(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+
+	rulesMu.RLock()
+	builtin := builtinRiskRules
+	rulesMu.RUnlock()
+
+	// Source of every rule ID, used to report conflicts
+	sources := make(map[int64]string)
+	var rules []RiskRule
+	if !replace {
+		for _, rule := range builtin {
+			sources[rule.ID] = "built-in rules"
+		}
+		rules = append(rules, builtin...)
+	}
+
+	for _, file := range files {
+		fileRules, err := readRiskRuleFile(file)
+		if err != nil {
+			return err
+		}
+		for _, rule := range fileRules {
+			if rule.ID >= BaseRiskRuleLow.ID && rule.ID <= BaseRiskRuleCritical.ID {
+				return fmt.Errorf("risk rule %d in %s: IDs %d-%d are reserved for base risk levels", rule.ID, file, BaseRiskRuleLow.ID, BaseRiskRuleCritical.ID)
+			}
+			if source, ok := sources[rule.ID]; ok {
+				return fmt.Errorf("conflicting risk rule ID %d in %s and %s", rule.ID, source, file)
+			}
+			sources[rule.ID] = file
+			rules = append(rules, rule)
+		}
+	}
+
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	setRiskRules(rules)
+	return nil
+}
+
+// ResetRiskRules restores the built-in risk rules, discarding any custom rules
+func ResetRiskRules() {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	setRiskRules(builtinRiskRules)
+}
+
+// riskRuleFiles returns path if it is a file, or the YAML files below it if it is a directory
+func riskRuleFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access risk rules %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read risk rules directory %s: %w", path, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readRiskRuleFile parses and validates the risk rules in a YAML file
+func readRiskRuleFile(file string) ([]RiskRule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read risk rules %s: %w", file, err)
+	}
+	var rules []RiskRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse risk rules %s: %w", file, err)
+	}
+	for _, rule := range rules {
+		if err := validateRiskRule(rule); err != nil {
+			return nil, fmt.Errorf("invalid risk rule in %s: %w", file, err)
+		}
+	}
+	return rules, nil
+}
+
 func init() {
 	if err := loadRiskRules(); err != nil {
 		// In production, you might want to handle this differently
@@ -66,14 +254,16 @@ func init() {
 	}
 }
 
-// UniqueRiskTags returns a slice of unique risk tags from the loaded risk rules.
+// UniqueRiskTags returns a slice of unique risk tags from the loaded risk rules, in the order
+// they first appear so the output of the formatters is stable.
 func UniqueRiskTags(tags []RiskTag) []RiskTag {
-	tagMap := make(map[RiskTag]struct{})
+	seen := make(map[RiskTag]struct{}, len(tags))
+	uniqueTags := make([]RiskTag, 0, len(tags))
 	for _, tag := range tags {
-		tagMap[tag] = struct{}{}
-	}
-	uniqueTags := make([]RiskTag, 0, len(tagMap))
-	for tag := range tagMap {
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
 		uniqueTags = append(uniqueTags, tag)
 	}
 	return uniqueTags
@@ -2,8 +2,11 @@ package policyevaluation
 
 import (
 	_ "embed"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -92,6 +95,21 @@ func TestValidateRiskRule(t *testing.T) {
 		{"missing name", RiskRule{ID: 2, RoleType: "Role", RiskLevel: RiskLevelLow}, true},
 		{"bad role", RiskRule{ID: 3, Name: "n", RoleType: "Bad", RiskLevel: RiskLevelLow}, true},
 		{"bad level", RiskRule{ID: 4, Name: "n", RoleType: "Role", RiskLevel: 99}, true},
+		{"bad kind", RiskRule{ID: 5, Name: "n", Kind: "other", RoleType: "Role", RiskLevel: RiskLevelLow}, true},
+		{"requires without combination", RiskRule{ID: 6, Name: "n", RoleType: "Role", RiskLevel: RiskLevelLow,
+			Requires: []PermissionRequirement{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}}, true},
+		{"combination", RiskRule{ID: 7, Name: "n", Kind: RuleKindCombination, RoleType: "Role", RiskLevel: RiskLevelLow,
+			Requires: []PermissionRequirement{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, VerbGroups: [][]string{{"get"}}},
+			}}, false},
+		{"combination with one requirement", RiskRule{ID: 8, Name: "n", Kind: RuleKindCombination, RoleType: "Role", RiskLevel: RiskLevelLow,
+			Requires: []PermissionRequirement{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}}, true},
+		{"combination requirement without verbs", RiskRule{ID: 9, Name: "n", Kind: RuleKindCombination, RoleType: "Role", RiskLevel: RiskLevelLow,
+			Requires: []PermissionRequirement{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"secrets"}},
+			}}, true},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -136,3 +154,120 @@ func TestLoadRiskRules(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadCustomRiskRules(t *testing.T) {
+	if err := loadRiskRules(); err != nil {
+		t.Fatalf("loadRiskRules() error = %v", err)
+	}
+	t.Cleanup(ResetRiskRules)
+	builtin := len(GetRiskRules())
+
+	crdRule := `- id: 50000
+  name: "Internal CRD access"
+  role_type: ClusterRole
+  risk_level: RiskLevelHigh
+  api_groups: ["*.internal.example.com"]
+  resources: ["widgets"]
+  verbs: ["get"]
+`
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	single := writeFile("single/crd.yaml", crdRule)
+	writeFile("tree/a.yaml", crdRule)
+	writeFile("tree/nested/b.yml", strings.ReplaceAll(crdRule, "50000", "50001"))
+	writeFile("tree/README.md", "not a rule file")
+	conflict := writeFile("conflict/dup.yaml", crdRule)
+	builtinConflict := writeFile("builtin/dup.yaml", strings.ReplaceAll(crdRule, "50000", "1000"))
+	reserved := writeFile("reserved/base.yaml", strings.ReplaceAll(crdRule, "50000", "9999"))
+	invalid := writeFile("invalid/rule.yaml", strings.ReplaceAll(crdRule, "ClusterRole", "Bad"))
+	malformed := writeFile("malformed/rule.yaml", "id: [")
+
+	cases := []struct {
+		name      string
+		paths     []string
+		replace   bool
+		wantCount int
+		wantErr   string
+	}{
+		{"merge file", []string{single}, false, builtin + 1, ""},
+		{"replace with directory", []string{filepath.Join(dir, "tree")}, true, 2, ""},
+		{"merge directory", []string{filepath.Join(dir, "tree")}, false, builtin + 2, ""},
+		{"replace with built-in ID", []string{builtinConflict}, true, 1, ""},
+		{"conflicting files", []string{single, conflict}, false, 0, "conflicting risk rule ID 50000"},
+		{"conflicting built-in", []string{builtinConflict}, false, 0, "conflicting risk rule ID 1000 in built-in rules"},
+		{"reserved ID", []string{reserved}, true, 0, "reserved for base risk levels"},
+		{"invalid rule", []string{invalid}, false, 0, "invalid risk rule"},
+		{"malformed yaml", []string{malformed}, false, 0, "failed to parse risk rules"},
+		{"missing path", []string{filepath.Join(dir, "missing")}, false, 0, "failed to access risk rules"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ResetRiskRules()
+			err := LoadCustomRiskRules(c.paths, c.replace)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("LoadCustomRiskRules() error = %v, want %q", err, c.wantErr)
+				}
+				if got := len(GetRiskRules()); got != builtin {
+					t.Errorf("rules changed on error: got %d, want %d", got, builtin)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadCustomRiskRules() error = %v", err)
+			}
+			if got := len(GetRiskRules()); got != c.wantCount {
+				t.Errorf("got %d rules, want %d", got, c.wantCount)
+			}
+		})
+	}
+
+	t.Run("custom rules are evaluated", func(t *testing.T) {
+		ResetRiskRules()
+		if err := LoadCustomRiskRules([]string{single}, false); err != nil {
+			t.Fatal(err)
+		}
+		matches, err := MatchRiskRules(Policy{RoleType: "ClusterRole", APIGroup: "widgets.internal.example.com", Resource: "widgets", Verbs: []string{"get"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) == 0 || matches[0].ID != 50000 {
+			t.Errorf("expected custom rule 50000 to match first, got %+v", matches)
+		}
+	})
+}
+
+func TestGetRiskRuleByID(t *testing.T) {
+	if err := loadRiskRules(); err != nil {
+		t.Fatalf("loadRiskRules() error = %v", err)
+	}
+	for _, id := range []int64{1000, 2000, 9999} {
+		if rule, ok := GetRiskRuleByID(id); !ok || rule.ID != id {
+			t.Errorf("GetRiskRuleByID(%d) = %d, %v", id, rule.ID, ok)
+		}
+	}
+	if _, ok := GetRiskRuleByID(1); ok {
+		t.Error("GetRiskRuleByID(1) found a rule, want none")
+	}
+}
+
+func TestRiskRulesRemediation(t *testing.T) {
+	if err := loadRiskRules(); err != nil {
+		t.Fatalf("loadRiskRules() error = %v", err)
+	}
+	rules := append(GetRiskRules(), GetCombinationRules()...)
+	for _, rule := range rules {
+		if rule.RiskLevel >= RiskLevelHigh && rule.Remediation == "" {
+			t.Errorf("rule %d (%s) is %s and has no remediation", rule.ID, rule.Name, rule.RiskLevel)
+		}
+	}
+}
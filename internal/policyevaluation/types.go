@@ -54,6 +54,16 @@ func (rl RiskLevel) String() string {
 	}
 }
 
+// ParseRiskLevel converts a risk level name such as "High" or "high" to a RiskLevel
+func ParseRiskLevel(s string) (RiskLevel, error) {
+	for _, rl := range []RiskLevel{RiskLevelLow, RiskLevelMedium, RiskLevelHigh, RiskLevelCritical} {
+		if strings.EqualFold(s, rl.String()) {
+			return rl, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid risk level: %s", s)
+}
+
 type RiskTag string
 
 // Implement Stringer for RiskTag
@@ -159,8 +169,17 @@ const (
 	ResourceNameRestricted       RiskTag = "ResourceNameRestricted"
 )
 
+// Kinds of risk rules
+const (
+	// RuleKindPermission rules are evaluated against a single permission, it is the default kind
+	RuleKindPermission = "permission"
+	// RuleKindCombination rules are evaluated against all permissions held by a subject
+	RuleKindCombination = "combination"
+)
+
 type RiskRule struct {
 	ID           int64      `yaml:"id"`
+	Kind         string     `yaml:"kind,omitempty"`
 	Name         string     `yaml:"name"`
 	Description  string     `yaml:"description"`
 	Category     string     `yaml:"category"`
@@ -173,6 +192,18 @@ type RiskRule struct {
 	VerbGroups   [][]string `yaml:"verb_groups,omitempty"`
 	Tags         RiskTags   `yaml:"tags"`
 	Commands     []Command  `yaml:"commands"`
+	// Remediation is defender guidance on how to narrow a permission that matches the rule
+	Remediation string `yaml:"remediation,omitempty"`
+	// Requires lists the permissions that must all be held for a combination rule to match
+	Requires []PermissionRequirement `yaml:"requires,omitempty"`
+}
+
+// PermissionRequirement is one of the permissions required by a combination rule
+type PermissionRequirement struct {
+	APIGroups  []string   `yaml:"api_groups"`
+	Resources  []string   `yaml:"resources"`
+	Verbs      []string   `yaml:"verbs,omitempty"`
+	VerbGroups [][]string `yaml:"verb_groups,omitempty"`
 }
 
 type Command struct {
@@ -188,6 +219,9 @@ type Policy struct {
 	Resource     string   `json:"resource" yaml:"resource"`
 	ResourceName string   `json:"resourceName" yaml:"resourceName"`
 	Verbs        []string `json:"verbs" yaml:"verbs"`
+	// BoundToNamespace marks a ClusterRole granted through a RoleBinding,
+	// its permissions only apply within the namespace of the binding
+	BoundToNamespace bool `json:"boundToNamespace,omitempty" yaml:"boundToNamespace,omitempty"`
 }
 
 var BaseRiskRuleCritical = RiskRule{
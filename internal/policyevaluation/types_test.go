@@ -26,6 +26,32 @@ func TestRiskLevel_String(t *testing.T) {
 	}
 }
 
+func TestParseRiskLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    RiskLevel
+		wantErr bool
+	}{
+		{"Low", RiskLevelLow, false},
+		{"medium", RiskLevelMedium, false},
+		{"HIGH", RiskLevelHigh, false},
+		{"Critical", RiskLevelCritical, false},
+		{"", 0, true},
+		{"severe", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRiskLevel(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRiskLevel(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRiskLevel(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestRiskTag_String(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,54 @@
+package renderer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// FindPackagedChart returns the highest version of the name-version.tgz archives in dir that
+// satisfies constraint, an empty constraint matches any stable version. A nil archive is
+// returned when no version matches.
+func FindPackagedChart(dir, name, constraint string) ([]byte, string, error) {
+	if constraint == "" {
+		constraint = "*"
+	}
+	versionConstraint, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid version %q: %w", constraint, err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, name+"-*.tgz"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var best *semver.Version
+	var bestPath string
+	for _, match := range matches {
+		raw := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), name+"-"), ".tgz")
+		version, err := semver.NewVersion(raw)
+		if err != nil {
+			// Another chart sharing the name prefix, such as redis-cluster for redis
+			continue
+		}
+		if !versionConstraint.Check(version) {
+			continue
+		}
+		if best == nil || version.GreaterThan(best) {
+			best, bestPath = version, match
+		}
+	}
+	if best == nil {
+		return nil, "", nil
+	}
+
+	archive, err := os.ReadFile(bestPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return archive, best.Original(), nil
+}
@@ -0,0 +1,41 @@
+package renderer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindPackagedChart(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"redis-17.0.0.tgz", "redis-17.3.1.tgz", "redis-18.0.0-rc.1.tgz", "redis-cluster-9.0.0.tgz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		constraint  string
+		wantVersion string
+		wantErr     bool
+	}{
+		{constraint: "17.x", wantVersion: "17.3.1"},
+		{constraint: "~17.0.0", wantVersion: "17.0.0"},
+		{constraint: "", wantVersion: "17.3.1"},
+		{constraint: ">=18.0.0-0", wantVersion: "18.0.0-rc.1"},
+		{constraint: "19.x", wantVersion: ""},
+		{constraint: "not a version", wantErr: true},
+	}
+	for _, tt := range tests {
+		archive, version, err := FindPackagedChart(dir, "redis", tt.constraint)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("FindPackagedChart(%q) error = %v, wantErr %v", tt.constraint, err, tt.wantErr)
+		}
+		if version != tt.wantVersion {
+			t.Errorf("FindPackagedChart(%q) version = %q, want %q", tt.constraint, version, tt.wantVersion)
+		}
+		if tt.wantVersion != "" && string(archive) != "redis-"+tt.wantVersion+".tgz" {
+			t.Errorf("FindPackagedChart(%q) read %q", tt.constraint, archive)
+		}
+	}
+}
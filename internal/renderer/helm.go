@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"os"
+	"sort"
+	"strings"
 	"sync"
 
 	yaml "gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/engine"
@@ -104,32 +106,39 @@ func (r *HelmRenderer) Render(ctx context.Context, folder []byte) (*Result, erro
 		return nil, fmt.Errorf("failed to load chart: %w", err)
 	}
 
-	values := make(map[string]interface{})
-	if r.opts.Values != "" {
-		// Parse values from the specified values file path
-		valueBytes, err := os.ReadFile(r.opts.Values)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read values file %s: %w", r.opts.Values, err)
-		}
+	// User values are coalesced over the chart defaults by ToRenderValues
+	values, err := r.opts.Values.Merge()
+	if err != nil {
+		return nil, err
+	}
 
-		if err := yaml.Unmarshal(valueBytes, &values); err != nil {
-			return nil, fmt.Errorf("failed to parse values file %s: %w", r.opts.Values, err)
-		}
-	} else if chart.Values != nil {
-		// Load values from values.yaml if it exists
-		values = chart.Values
+	caps, err := r.capabilities()
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the subcharts disabled by condition or tags, as helm install does
+	declared := chartDependencies(chart)
+	if err := chartutil.ProcessDependenciesWithMerge(chart, values); err != nil {
+		return nil, fmt.Errorf("failed to process chart dependencies: %w", err)
 	}
 
 	// Create chart config
 	options := chartutil.ReleaseOptions{
-		Name:      chart.Name(),
-		Namespace: "default",
+		Name:      r.opts.ReleaseName,
+		Namespace: r.opts.Namespace,
 		Revision:  1,
 		IsInstall: true,
 	}
+	if options.Name == "" {
+		options.Name = chart.Name()
+	}
+	if options.Namespace == "" {
+		options.Namespace = "default"
+	}
 
 	// Create chart values
-	valuesToRender, err := chartutil.ToRenderValues(chart, values, options, nil)
+	valuesToRender, err := chartutil.ToRenderValues(chart, values, options, caps)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chart values: %w", err)
 	}
@@ -154,7 +163,13 @@ func (r *HelmRenderer) Render(ctx context.Context, folder []byte) (*Result, erro
 		Extra:     make(map[string]interface{}),
 	}
 
-	result.Extra["helm"] = map[string]interface{}{
+	dependencies, missing := dependencyStatus(chart, declared)
+	for _, dep := range missing {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("dependency %s %s is missing from charts/, its templates are not rendered", dep.Name, dep.Version))
+	}
+	subcharts := make(map[string]interface{})
+
+	helmExtra := map[string]interface{}{
 		"description": chart.Metadata.Description,
 		"keywords":    chart.Metadata.Keywords,
 		"home":        chart.Metadata.Home,
@@ -162,6 +177,21 @@ func (r *HelmRenderer) Render(ctx context.Context, folder []byte) (*Result, erro
 		"kubeVersion": chart.Metadata.KubeVersion,
 		"maintainers": chart.Metadata.Maintainers,
 		"sources":     chart.Metadata.Sources,
+		"release": map[string]interface{}{
+			"name":      options.Name,
+			"namespace": options.Namespace,
+		},
+		"capabilities": map[string]interface{}{
+			"kubeVersion": caps.KubeVersion.String(),
+		},
+		"dependencies": dependencies,
+	}
+	result.Extra["helm"] = helmExtra
+
+	// helm install refuses charts requiring another Kubernetes version, the RBAC is still reported
+	if chart.Metadata.KubeVersion != "" && !chartutil.IsCompatibleRange(chart.Metadata.KubeVersion, caps.KubeVersion.String()) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("chart requires kubeVersion: %s which is incompatible with Kubernetes %s",
+			chart.Metadata.KubeVersion, caps.KubeVersion.String()))
 	}
 
 	// Process each rendered template
@@ -206,10 +236,146 @@ func (r *HelmRenderer) Render(ctx context.Context, folder []byte) (*Result, erro
 					"docNum":   i + 1,
 				}
 			}
+			// Manifests of subcharts are always attributed, RBAC rows refer to them
+			if subchart := subchartOf(name); subchart != "" {
+				if manifest.Metadata == nil {
+					manifest.Metadata = make(map[string]interface{})
+				}
+				manifest.Metadata["subchart"] = subchart
+				subcharts[manifestName] = subchart
+			}
 
 			result.Manifests = append(result.Manifests, manifest)
 		}
 	}
 
+	if len(subcharts) > 0 {
+		helmExtra["subcharts"] = subcharts
+	}
+
 	return result, nil
 }
+
+// chartDependencies returns a copy of the dependencies declared by chart and its subcharts,
+// keyed by their path such as redis or redis.sentinel
+func chartDependencies(c *chart.Chart) map[string]chart.Dependency {
+	deps := make(map[string]chart.Dependency)
+	var walk func(c *chart.Chart, prefix string)
+	walk = func(c *chart.Chart, prefix string) {
+		for _, dep := range c.Metadata.Dependencies {
+			if dep != nil {
+				deps[prefix+dependencyName(dep)] = *dep
+			}
+		}
+		for _, sub := range c.Dependencies() {
+			walk(sub, prefix+sub.Name()+".")
+		}
+	}
+	walk(c, "")
+	return deps
+}
+
+// dependencyName returns the name a dependency is rendered with
+func dependencyName(dep *chart.Dependency) string {
+	if dep.Alias != "" {
+		return dep.Alias
+	}
+	return dep.Name
+}
+
+// dependencyStatus reports whether each declared dependency was rendered, disabled by its
+// condition or tags, or missing from the chart. Declared dependencies are keyed by path.
+func dependencyStatus(c *chart.Chart, declared map[string]chart.Dependency) ([]map[string]interface{}, []chart.Dependency) {
+	// Subcharts left after processing the conditions and tags
+	rendered := make(map[string]bool)
+	var walk func(c *chart.Chart, prefix string)
+	walk = func(c *chart.Chart, prefix string) {
+		for _, sub := range c.Dependencies() {
+			rendered[prefix+sub.Name()] = true
+			walk(sub, prefix+sub.Name()+".")
+		}
+	}
+	walk(c, "")
+	enabled := make(map[string]bool)
+	var walkEnabled func(c *chart.Chart, prefix string)
+	walkEnabled = func(c *chart.Chart, prefix string) {
+		for _, dep := range c.Metadata.Dependencies {
+			if dep != nil {
+				enabled[prefix+dep.Name] = true
+			}
+		}
+		for _, sub := range c.Dependencies() {
+			walkEnabled(sub, prefix+sub.Name()+".")
+		}
+	}
+	walkEnabled(c, "")
+
+	paths := make([]string, 0, len(declared))
+	for p := range declared {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var status []map[string]interface{}
+	var missing []chart.Dependency
+	for _, p := range paths {
+		dep := declared[p]
+		state := "disabled"
+		switch {
+		case rendered[p]:
+			state = "rendered"
+		case enabled[p]:
+			state = "missing"
+			missing = append(missing, dep)
+		}
+		status = append(status, map[string]interface{}{
+			"name":       p,
+			"chart":      dep.Name,
+			"version":    dep.Version,
+			"repository": dep.Repository,
+			"condition":  dep.Condition,
+			"tags":       dep.Tags,
+			"status":     state,
+		})
+	}
+	return status, missing
+}
+
+// subchartOf returns the subchart path of a rendered template such as
+// parent/charts/redis/templates/role.yaml, nested subcharts are joined with a dot
+func subchartOf(template string) string {
+	// Drop the name of the parent chart
+	_, rest, ok := strings.Cut(template, "/")
+	if !ok {
+		return ""
+	}
+	var subcharts []string
+	for strings.HasPrefix(rest, "charts/") {
+		name, remaining, ok := strings.Cut(strings.TrimPrefix(rest, "charts/"), "/")
+		if !ok {
+			break
+		}
+		subcharts = append(subcharts, name)
+		rest = remaining
+	}
+	return strings.Join(subcharts, ".")
+}
+
+// capabilities returns the cluster capabilities exposed to the templates, the helm defaults
+// with the configured Kubernetes version and additional API versions
+func (r *HelmRenderer) capabilities() (*chartutil.Capabilities, error) {
+	caps := chartutil.DefaultCapabilities.Copy()
+	if r.opts.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(r.opts.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kube version %q: %w", r.opts.KubeVersion, err)
+		}
+		caps.KubeVersion = *kubeVersion
+	}
+	if len(r.opts.APIVersions) > 0 {
+		apiVersions := make(chartutil.VersionSet, 0, len(caps.APIVersions)+len(r.opts.APIVersions))
+		apiVersions = append(apiVersions, caps.APIVersions...)
+		caps.APIVersions = append(apiVersions, r.opts.APIVersions...)
+	}
+	return caps, nil
+}
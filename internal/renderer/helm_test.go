@@ -3,6 +3,7 @@ package renderer
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -75,7 +76,11 @@ func TestHelmRenderer_RenderWithValues(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create renderer with values path
-			r := NewHelmRenderer(&Options{Values: tt.valuesPath})
+			opts := &Options{}
+			if tt.valuesPath != "" {
+				opts.Values.ValueFiles = []string{tt.valuesPath}
+			}
+			r := NewHelmRenderer(opts)
 
 			// Add chart files
 			files := map[string]string{
@@ -372,3 +377,291 @@ func TestHelmRenderer_SetOptionsNil(t *testing.T) {
 		t.Fatal("expected error when setting nil options")
 	}
 }
+
+func TestHelmRenderer_MergeValues(t *testing.T) {
+	files := map[string][]byte{
+		"Chart.yaml": []byte(`apiVersion: v2
+name: test-chart
+version: 0.1.0`),
+		"values.yaml": []byte(`rbac:
+  name: test-role
+  verbs: ["get", "list"]`),
+		"templates/role.yaml": []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: {{ .Values.rbac.name }}
+rules:
+  - apiGroups: [""]
+    resources: ["pods"]
+    verbs: {{ toJson .Values.rbac.verbs }}`),
+	}
+
+	// A partial values file only overrides the name, the chart default verbs are kept
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(valuesFile, []byte("rbac:\n  name: from-file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		values    ValuesOptions
+		wantName  string
+		wantVerbs []interface{}
+	}{
+		{
+			name:      "chart defaults",
+			wantName:  "test-role",
+			wantVerbs: []interface{}{"get", "list"},
+		},
+		{
+			name:      "partial values file",
+			values:    ValuesOptions{ValueFiles: []string{valuesFile}},
+			wantName:  "from-file",
+			wantVerbs: []interface{}{"get", "list"},
+		},
+		{
+			name:      "set overrides values file",
+			values:    ValuesOptions{ValueFiles: []string{valuesFile}, Values: []string{"rbac.name=from-set,rbac.verbs={*}"}},
+			wantName:  "from-set",
+			wantVerbs: []interface{}{"*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewHelmRenderer(&Options{Values: tt.values})
+			for name, content := range files {
+				if err := r.AddFile(name, content); err != nil {
+					t.Fatalf("failed to add file %s: %v", name, err)
+				}
+			}
+
+			result, err := r.Render(context.Background(), nil)
+			if err != nil {
+				t.Fatalf("HelmRenderer.Render() error = %v", err)
+			}
+			if len(result.Manifests) != 1 {
+				t.Fatalf("expected 1 manifest, got %d", len(result.Manifests))
+			}
+
+			content := result.Manifests[0].Content
+			metadata, _ := content["metadata"].(map[string]interface{})
+			if metadata["name"] != tt.wantName {
+				t.Errorf("name = %v, want %v", metadata["name"], tt.wantName)
+			}
+			rules, _ := content["rules"].([]interface{})
+			rule, _ := rules[0].(map[string]interface{})
+			if !reflect.DeepEqual(rule["verbs"], tt.wantVerbs) {
+				t.Errorf("verbs = %v, want %v", rule["verbs"], tt.wantVerbs)
+			}
+		})
+	}
+}
+
+func TestHelmRenderer_ReleaseAndCapabilities(t *testing.T) {
+	files := map[string][]byte{
+		"Chart.yaml": []byte(`apiVersion: v2
+name: test-chart
+version: 0.1.0
+kubeVersion: ">=1.25.0-0"`),
+		"templates/role.yaml": []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+rules:
+  - apiGroups: [""]
+    resources: ["pods"]
+    verbs: ["get"]
+{{- if semverCompare ">=1.29.0-0" .Capabilities.KubeVersion.Version }}
+  - apiGroups: [""]
+    resources: ["secrets"]
+    verbs: ["get"]
+{{- end }}
+{{- if .Capabilities.APIVersions.Has "monitoring.coreos.com/v1" }}
+  - apiGroups: ["monitoring.coreos.com"]
+    resources: ["servicemonitors"]
+    verbs: ["create"]
+{{- end }}`),
+	}
+
+	tests := []struct {
+		name          string
+		opts          *Options
+		wantName      string
+		wantNamespace string
+		wantRules     int
+		wantWarnings  int
+		wantErr       bool
+	}{
+		{
+			name:          "defaults",
+			opts:          &Options{},
+			wantName:      "test-chart",
+			wantNamespace: "default",
+			wantRules:     1,
+			wantWarnings:  1,
+		},
+		{
+			name:          "release and capabilities",
+			opts:          &Options{ReleaseName: "prod", Namespace: "operators", KubeVersion: "v1.29.2", APIVersions: []string{"monitoring.coreos.com/v1"}},
+			wantName:      "prod",
+			wantNamespace: "operators",
+			wantRules:     3,
+		},
+		{
+			name:    "invalid kube version",
+			opts:    &Options{KubeVersion: "latest"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewHelmRenderer(tt.opts)
+			for name, content := range files {
+				if err := r.AddFile(name, content); err != nil {
+					t.Fatalf("failed to add file %s: %v", name, err)
+				}
+			}
+
+			result, err := r.Render(context.Background(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HelmRenderer.Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(result.Manifests) != 1 {
+				t.Fatalf("expected 1 manifest, got %d", len(result.Manifests))
+			}
+
+			content := result.Manifests[0].Content
+			metadata, _ := content["metadata"].(map[string]interface{})
+			if metadata["name"] != tt.wantName || metadata["namespace"] != tt.wantNamespace {
+				t.Errorf("metadata = %v, want name %s namespace %s", metadata, tt.wantName, tt.wantNamespace)
+			}
+			if rules, _ := content["rules"].([]interface{}); len(rules) != tt.wantRules {
+				t.Errorf("expected %d rules, got %d", tt.wantRules, len(rules))
+			}
+			if len(result.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %v, want %d", result.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestHelmRenderer_Dependencies(t *testing.T) {
+	files := map[string][]byte{
+		"Chart.yaml": []byte(`apiVersion: v2
+name: parent
+version: 0.1.0
+dependencies:
+  - name: metrics
+    version: 1.x
+    repository: https://charts.example.com
+    condition: metrics.enabled
+  - name: webhook
+    version: 2.0.0
+    repository: https://charts.example.com
+    tags: [admission]
+  - name: cache
+    version: 3.0.0
+    repository: https://charts.example.com`),
+		"values.yaml": []byte(`metrics:
+  enabled: true
+tags:
+  admission: false`),
+		"templates/role.yaml": []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: parent-role`),
+		"charts/metrics/Chart.yaml": []byte(`apiVersion: v2
+name: metrics
+version: 1.2.0`),
+		"charts/metrics/templates/role.yaml": []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: metrics-role`),
+		"charts/webhook/Chart.yaml": []byte(`apiVersion: v2
+name: webhook
+version: 2.0.0`),
+		"charts/webhook/templates/role.yaml": []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: webhook-role`),
+	}
+
+	tests := []struct {
+		name          string
+		values        ValuesOptions
+		wantSubcharts map[string]string // Role name to subchart
+		wantStatus    map[string]string
+	}{
+		{
+			name:          "conditions and tags from chart values",
+			wantSubcharts: map[string]string{"parent-role": "", "metrics-role": "metrics"},
+			wantStatus:    map[string]string{"metrics": "rendered", "webhook": "disabled", "cache": "missing"},
+		},
+		{
+			name:          "conditions and tags from user values",
+			values:        ValuesOptions{Values: []string{"metrics.enabled=false,tags.admission=true"}},
+			wantSubcharts: map[string]string{"parent-role": "", "webhook-role": "webhook"},
+			wantStatus:    map[string]string{"metrics": "disabled", "webhook": "rendered", "cache": "missing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewHelmRenderer(&Options{Values: tt.values})
+			for name, content := range files {
+				if err := r.AddFile(name, content); err != nil {
+					t.Fatalf("failed to add file %s: %v", name, err)
+				}
+			}
+
+			result, err := r.Render(context.Background(), nil)
+			if err != nil {
+				t.Fatalf("HelmRenderer.Render() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, m := range result.Manifests {
+				metadata, _ := m.Content["metadata"].(map[string]interface{})
+				name, _ := metadata["name"].(string)
+				got[name], _ = m.Metadata["subchart"].(string)
+			}
+			if !reflect.DeepEqual(got, tt.wantSubcharts) {
+				t.Errorf("subcharts = %v, want %v", got, tt.wantSubcharts)
+			}
+
+			helm, _ := result.Extra["helm"].(map[string]interface{})
+			deps, _ := helm["dependencies"].([]map[string]interface{})
+			status := make(map[string]string)
+			for _, dep := range deps {
+				status[dep["name"].(string)] = dep["status"].(string)
+			}
+			if !reflect.DeepEqual(status, tt.wantStatus) {
+				t.Errorf("dependency status = %v, want %v", status, tt.wantStatus)
+			}
+			if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "dependency cache 3.0.0 is missing") {
+				t.Errorf("warnings = %v, want the missing cache dependency", result.Warnings)
+			}
+		})
+	}
+}
+
+func TestSubchartOf(t *testing.T) {
+	tests := map[string]string{
+		"parent/templates/role.yaml":                        "",
+		"parent/templates/charts/role.yaml":                 "",
+		"parent/charts/redis/templates/role.yaml":           "redis",
+		"parent/charts/redis/charts/sentinel/templates/a.y": "redis.sentinel",
+		"role.yaml": "",
+	}
+	for template, want := range tests {
+		if got := subchartOf(template); got != want {
+			t.Errorf("subchartOf(%q) = %q, want %q", template, got, want)
+		}
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"crypto/sha512"
 	"fmt"
-	// "io/fs" // Standard library io/fs - REMOVED as it's unused
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 
 	yaml "gopkg.in/yaml.v3"
 	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
@@ -32,8 +33,19 @@ func NewKustomizeRenderer(opts *Options) *KustomizeRenderer {
 	}
 }
 
-// Render processes a Kustomize directory and returns the rendered manifests
+// Render builds a Kustomize overlay and returns the rendered manifests. When files were added
+// they are built in memory, otherwise input is the overlay directory, which is built on disk
+// so bases in parent directories can be reached, within the configured root.
 func (r *KustomizeRenderer) Render(ctx context.Context, folder []byte) (*Result, error) {
+	r.mux.RLock()
+	inMemory := len(r.files) > 0
+	r.mux.RUnlock()
+	if !inMemory {
+		if info, err := os.Stat(string(folder)); err == nil && info.IsDir() {
+			return r.renderDir(ctx, string(folder))
+		}
+	}
+
 	// Create an in-memory filesystem
 	fsys := filesys.MakeFsInMemory() // Renamed to fsys to avoid conflict with package fs
 
@@ -69,6 +81,58 @@ func (r *KustomizeRenderer) Render(ctx context.Context, folder []byte) (*Result,
 		return nil, fmt.Errorf("failed to build resources: %w", err)
 	}
 
+	return kustomizeResult(string(folder), "", resources)
+}
+
+// renderDir builds the overlay in dir on disk, bounded by the kustomize root
+func (r *KustomizeRenderer) renderDir(ctx context.Context, dir string) (*Result, error) {
+	opts := r.GetOptions()
+	root, err := kustomizeRoot(dir, opts.KustomizeRoot)
+	if err != nil {
+		return nil, err
+	}
+	fsys, err := newRootedFS(ctx, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := fsys.check(dir); err != nil {
+		return nil, err
+	}
+	target, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	fsys.target = target
+
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resources, err := k.Run(fsys, target)
+	if err != nil {
+		if fsys.err != nil {
+			err = fsys.err
+		}
+		return nil, fmt.Errorf("failed to build resources: %w", err)
+	}
+
+	result, err := kustomizeResult(dir, dir, resources)
+	if err != nil {
+		return nil, err
+	}
+	kustomizeExtra := map[string]interface{}{"root": fsys.root}
+	if len(fsys.charts) > 0 {
+		kustomizeExtra["helmCharts"] = fsys.charts
+	}
+	result.Extra = map[string]interface{}{"kustomize": kustomizeExtra}
+	return result, nil
+}
+
+// kustomizeResult converts the built resources of the overlay name into a Result. Resources
+// with an origin annotation record their file, resolved against dir, with their document and line.
+func kustomizeResult(name, dir string, resources resmap.ResMap) (*Result, error) {
+	origins, err := originMetadata(resources, dir)
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert resources to yaml
 	yamlData, err := resources.AsYaml()
 	if err != nil {
@@ -81,14 +145,14 @@ func (r *KustomizeRenderer) Render(ctx context.Context, folder []byte) (*Result,
 
 	// Parse the rendered manifests
 	result := &Result{
-		Name:      string(folder), // This is the original source folder path
+		Name:      name, // This is the original source folder path
 		Version:   version,
 		Manifests: make([]*Manifest, 0),
 	}
 
 	decoder := yaml.NewDecoder(strings.NewReader(string(yamlData)))
 
-	for {
+	for idx := 0; ; idx++ {
 		var obj map[string]interface{}
 		err := decoder.Decode(&obj)
 		if err == nil {
@@ -105,6 +169,11 @@ func (r *KustomizeRenderer) Render(ctx context.Context, folder []byte) (*Result,
 				Content:  obj,
 				Metadata: make(map[string]interface{}),
 			}
+			if idx < len(origins) {
+				for k, v := range origins[idx] {
+					manifest.Metadata[k] = v
+				}
+			}
 
 			// Extract name from metadata if present
 			if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
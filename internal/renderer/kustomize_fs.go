@@ -0,0 +1,417 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// originAnnotation records the file a built resource was read from
+const originAnnotation = "config.kubernetes.io/origin"
+
+// rootedFS is the on-disk filesystem a Kustomize overlay is built from. Paths outside root are
+// rejected, except the temporary clones of remote bases made by kustomize. Kustomization files
+// are read through inflateHelmCharts, which replaces their helmCharts with rendered manifests.
+type rootedFS struct {
+	filesys.FileSystem
+	ctx    context.Context
+	root   string
+	target string // Overlay being built, its kustomization is read with origin annotations enabled
+	opts   *Options
+
+	mux       sync.Mutex
+	inflated  map[string][]byte        // Kustomization files with their helmCharts inflated, keyed by path
+	generated map[string][]byte        // Manifests rendered from helmCharts, keyed by path
+	charts    []map[string]interface{} // Helm charts rendered for the helmCharts of the overlays
+	err       error                    // First helmCharts error, kustomize reports unreadable kustomizations as missing
+}
+
+// newRootedFS creates a rootedFS bounded by root
+func newRootedFS(ctx context.Context, root string, opts *Options) (*rootedFS, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kustomize root %s: %w", root, err)
+	}
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kustomize root %s: %w", root, err)
+	}
+	return &rootedFS{
+		FileSystem: filesys.MakeFsOnDisk(),
+		ctx:        ctx,
+		root:       abs,
+		opts:       opts,
+		inflated:   make(map[string][]byte),
+		generated:  make(map[string][]byte),
+	}, nil
+}
+
+// kustomizeRoot returns the configured root, or the git working tree enclosing dir so overlays
+// can reach the bases of the repository, or dir itself when it is not in a git working tree
+func kustomizeRoot(dir, configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for current := abs; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current, nil
+		}
+		if filepath.Dir(current) == current {
+			return abs, nil
+		}
+	}
+}
+
+// check returns an error when path is outside the root
+func (f *rootedFS) check(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	if isWithin(f.root, abs) || isRemoteClone(abs) {
+		return nil
+	}
+	return fmt.Errorf("security; %s is outside the kustomize root %s", path, f.root)
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isRemoteClone reports whether path is in a temporary directory where kustomize clones remote bases
+func isRemoteClone(path string) bool {
+	tmp := os.TempDir()
+	candidates := []string{tmp}
+	if resolved, err := filepath.EvalSymlinks(tmp); err == nil {
+		candidates = append(candidates, resolved)
+	}
+	for _, dir := range candidates {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && strings.HasPrefix(rel, "kustomize-") {
+			return true
+		}
+	}
+	return false
+}
+
+// isKustomizationFile reports whether path is named like a kustomization file
+func isKustomizationFile(path string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if filepath.Base(path) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedFile returns the content of a manifest file rendered from helmCharts
+func (f *rootedFS) generatedFile(path string) ([]byte, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	content, ok := f.generated[abs]
+	return content, ok
+}
+
+// Create creates a file below the root
+func (f *rootedFS) Create(path string) (filesys.File, error) {
+	if err := f.check(path); err != nil {
+		return nil, err
+	}
+	return f.FileSystem.Create(path)
+}
+
+// Mkdir creates a directory below the root
+func (f *rootedFS) Mkdir(path string) error {
+	if err := f.check(path); err != nil {
+		return err
+	}
+	return f.FileSystem.Mkdir(path)
+}
+
+// MkdirAll creates a directory and its parents below the root
+func (f *rootedFS) MkdirAll(path string) error {
+	if err := f.check(path); err != nil {
+		return err
+	}
+	return f.FileSystem.MkdirAll(path)
+}
+
+// RemoveAll removes a path below the root
+func (f *rootedFS) RemoveAll(path string) error {
+	if err := f.check(path); err != nil {
+		return err
+	}
+	return f.FileSystem.RemoveAll(path)
+}
+
+// Open opens a file below the root
+func (f *rootedFS) Open(path string) (filesys.File, error) {
+	if err := f.check(path); err != nil {
+		return nil, err
+	}
+	return f.FileSystem.Open(path)
+}
+
+// IsDir reports whether path is a directory below the root
+func (f *rootedFS) IsDir(path string) bool {
+	return f.check(path) == nil && f.FileSystem.IsDir(path)
+}
+
+// ReadDir lists a directory below the root
+func (f *rootedFS) ReadDir(path string) ([]string, error) {
+	if err := f.check(path); err != nil {
+		return nil, err
+	}
+	return f.FileSystem.ReadDir(path)
+}
+
+// CleanedAbs splits path into a confirmed directory and a file name, the path must be below the root
+func (f *rootedFS) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
+	if _, ok := f.generatedFile(path); ok {
+		abs, _ := filepath.Abs(path)
+		return filesys.ConfirmedDir(filepath.Dir(abs)), filepath.Base(abs), nil
+	}
+	if err := f.check(path); err != nil {
+		return "", "", err
+	}
+	return f.FileSystem.CleanedAbs(path)
+}
+
+// Exists reports whether path exists below the root
+func (f *rootedFS) Exists(path string) bool {
+	if _, ok := f.generatedFile(path); ok {
+		return true
+	}
+	return f.check(path) == nil && f.FileSystem.Exists(path)
+}
+
+// Glob returns the matches of pattern below the root
+func (f *rootedFS) Glob(pattern string) ([]string, error) {
+	matches, err := f.FileSystem.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var allowed []string
+	for _, match := range matches {
+		if f.check(match) == nil {
+			allowed = append(allowed, match)
+		}
+	}
+	return allowed, nil
+}
+
+// ReadFile reads a file below the root, kustomization files are returned with their helmCharts inflated
+func (f *rootedFS) ReadFile(path string) ([]byte, error) {
+	if content, ok := f.generatedFile(path); ok {
+		return content, nil
+	}
+	if err := f.check(path); err != nil {
+		return nil, err
+	}
+	content, err := f.FileSystem.ReadFile(path)
+	if err != nil || !isKustomizationFile(path) {
+		return content, err
+	}
+	inflated, err := f.inflateHelmCharts(path, content)
+	if err != nil {
+		f.mux.Lock()
+		if f.err == nil {
+			f.err = err
+		}
+		f.mux.Unlock()
+		return nil, err
+	}
+	if abs, err := filepath.Abs(path); err == nil && filepath.Dir(abs) == f.target {
+		return withOriginAnnotations(inflated), nil
+	}
+	return inflated, nil
+}
+
+// WriteFile writes a file below the root
+func (f *rootedFS) WriteFile(path string, data []byte) error {
+	if err := f.check(path); err != nil {
+		return err
+	}
+	return f.FileSystem.WriteFile(path, data)
+}
+
+// Walk walks a directory below the root
+func (f *rootedFS) Walk(path string, walkFn filepath.WalkFunc) error {
+	if err := f.check(path); err != nil {
+		return err
+	}
+	return f.FileSystem.Walk(path, walkFn)
+}
+
+// withOriginAnnotations enables the originAnnotations build metadata of a kustomization, so every
+// built resource records the file it was read from. Invalid kustomizations are returned as is.
+func withOriginAnnotations(content []byte) []byte {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil || doc == nil {
+		return content
+	}
+	buildMetadata, _ := doc["buildMetadata"].([]interface{})
+	for _, option := range buildMetadata {
+		if option == types.OriginAnnotations {
+			return content
+		}
+	}
+	doc["buildMetadata"] = append(buildMetadata, types.OriginAnnotations)
+	annotated, err := yaml.Marshal(doc)
+	if err != nil {
+		return content
+	}
+	return annotated
+}
+
+// originMetadata removes the origin annotations from the built resources and returns the file
+// metadata of each resource: the file it was read from, resolved against the overlay directory,
+// and the document and line it starts at in that file. Resources rendered from helmCharts and
+// generated resources have no metadata.
+func originMetadata(resources resmap.ResMap, dir string) ([]map[string]interface{}, error) {
+	metadata := make([]map[string]interface{}, resources.Size())
+	documents := make(map[string][]*Manifest)
+	affixes := make(map[string]*[2]string) // Name prefix and suffix of the resources of a file, nil when unknown
+	for i, res := range resources.Resources() {
+		annotations := res.GetAnnotations()
+		value, ok := annotations[originAnnotation]
+		if !ok {
+			continue
+		}
+		delete(annotations, originAnnotation)
+		if err := res.SetAnnotations(annotations); err != nil {
+			return nil, fmt.Errorf("failed to remove the origin of %s: %w", res.CurId(), err)
+		}
+
+		var origin struct {
+			Path string `json:"path"`
+		}
+		if err := yaml.Unmarshal([]byte(value), &origin); err != nil || origin.Path == "" || filepath.Base(origin.Path) == helmChartsFile {
+			continue
+		}
+		file := origin.Path
+		if strings.Contains(file, "://") || filepath.IsAbs(file) || dir == "" {
+			metadata[i] = map[string]interface{}{"file": file}
+			continue
+		}
+		file = filepath.Join(dir, file)
+		metadata[i] = map[string]interface{}{"file": file}
+
+		// Find the document of the resource by its kind and name, as renamed by the kustomizations
+		// that include the file. Without their prefix and suffix the line is left out.
+		if _, ok := documents[file]; !ok {
+			documents[file] = fileDocuments(file)
+			if prefix, suffix, ok := nameAffixes(dir, file, make(map[string]bool)); ok {
+				affixes[file] = &[2]string{prefix, suffix}
+			}
+		}
+		if affixes[file] == nil {
+			continue
+		}
+		prefix, suffix := affixes[file][0], affixes[file][1]
+		if unaffixedKinds[res.GetKind()] {
+			prefix, suffix = "", ""
+		}
+		if doc := originDocument(documents[file], res.GetKind(), res.GetName(), prefix, suffix); doc != nil {
+			metadata[i]["docNum"] = doc.Metadata["docNum"]
+			if line, ok := doc.Metadata["line"]; ok {
+				metadata[i]["line"] = line
+			}
+		}
+	}
+	return metadata, nil
+}
+
+// unaffixedKinds are the kinds kustomize does not add a name prefix or suffix to
+var unaffixedKinds = map[string]bool{"CustomResourceDefinition": true, "APIService": true, "Namespace": true}
+
+// originDocument returns the document of kind whose name with prefix and suffix is name
+func originDocument(documents []*Manifest, kind, name, prefix, suffix string) *Manifest {
+	for _, doc := range documents {
+		if docKind, _ := doc.Content["kind"].(string); docKind == kind && prefix+doc.Name+suffix == name {
+			return doc
+		}
+	}
+	return nil
+}
+
+// nameAffixes returns the name prefix and suffix added to the resources of file by the
+// kustomization in dir and the local bases it includes down to the one listing file. ok is
+// false when file is not listed by them.
+func nameAffixes(dir, file string, visited map[string]bool) (prefix, suffix string, ok bool) {
+	if visited[dir] {
+		return "", "", false
+	}
+	visited[dir] = true
+
+	var kustomization struct {
+		NamePrefix string   `json:"namePrefix"`
+		NameSuffix string   `json:"nameSuffix"`
+		Resources  []string `json:"resources"`
+		Bases      []string `json:"bases"`
+	}
+	found := false
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if err := yaml.Unmarshal(content, &kustomization); err != nil {
+			return "", "", false
+		}
+		found = true
+		break
+	}
+	if !found {
+		return "", "", false
+	}
+
+	for _, entry := range append(kustomization.Resources, kustomization.Bases...) {
+		path := filepath.Join(dir, entry)
+		if path == file {
+			return kustomization.NamePrefix, kustomization.NameSuffix, true
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		if p, s, ok := nameAffixes(path, file, visited); ok {
+			return kustomization.NamePrefix + p, s + kustomization.NameSuffix, true
+		}
+	}
+	return "", "", false
+}
+
+// fileDocuments returns the documents of a manifest file, nil when it cannot be read
+func fileDocuments(path string) []*Manifest {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	result, err := NewYAMLRenderer().Render(context.Background(), content)
+	if err != nil {
+		return nil
+	}
+	return result.Manifests
+}
@@ -0,0 +1,239 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+// helmChartsFile is the name of the manifest file rendered from the helmCharts of a kustomization,
+// it is added to the resources of the kustomization in place of its helmCharts
+const helmChartsFile = ".rbac-scope-helm-charts.yaml"
+
+// inflateHelmCharts renders the helmCharts of the kustomization file at path with the helm
+// renderer and returns the kustomization with the rendered manifests as a resource instead.
+// Charts are read unpacked from the chartHome of the kustomization or packaged from the chart
+// cache, they are never pulled.
+func (f *rootedFS) inflateHelmCharts(path string, content []byte) ([]byte, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	f.mux.Lock()
+	inflated, ok := f.inflated[abs]
+	f.mux.Unlock()
+	if ok {
+		return inflated, nil
+	}
+
+	var kustomization struct {
+		HelmGlobals *types.HelmGlobals `json:"helmGlobals,omitempty"`
+		HelmCharts  []types.HelmChart  `json:"helmCharts,omitempty"`
+	}
+	if err := yaml.Unmarshal(content, &kustomization); err != nil || len(kustomization.HelmCharts) == 0 {
+		// Invalid kustomizations are reported by kustomize
+		return content, nil
+	}
+	if !f.opts.KustomizeHelm {
+		return nil, fmt.Errorf("%s inflates helm charts with helmCharts, which are only rendered when enabled (--kustomize-helm)", path)
+	}
+
+	dir := filepath.Dir(abs)
+	chartHome := types.HelmDefaultHome
+	if kustomization.HelmGlobals != nil && kustomization.HelmGlobals.ChartHome != "" {
+		chartHome = kustomization.HelmGlobals.ChartHome
+	}
+	if !filepath.IsAbs(chartHome) {
+		chartHome = filepath.Join(dir, chartHome)
+	}
+
+	var rendered bytes.Buffer
+	for _, chart := range kustomization.HelmCharts {
+		manifests, err := f.renderHelmChart(dir, chartHome, chart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inflate helm chart %s of %s: %w", chart.Name, path, err)
+		}
+		rendered.Write(manifests)
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	delete(doc, "helmCharts")
+	delete(doc, "helmGlobals")
+	resources, _ := doc["resources"].([]interface{})
+	doc["resources"] = append(resources, helmChartsFile)
+	inflated, err = yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.generated[filepath.Join(dir, helmChartsFile)] = rendered.Bytes()
+	f.inflated[abs] = inflated
+	return inflated, nil
+}
+
+// renderHelmChart renders a helmCharts entry of the kustomization in dir as a YAML stream
+func (f *rootedFS) renderHelmChart(dir, chartHome string, chart types.HelmChart) ([]byte, error) {
+	if chart.Name == "" {
+		return nil, fmt.Errorf("chart name cannot be empty")
+	}
+	files, source, err := f.loadHelmChart(chartHome, chart)
+	if err != nil {
+		return nil, err
+	}
+	values, err := f.helmChartValues(dir, chart)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeVersion := chart.KubeVersion
+	if kubeVersion == "" {
+		kubeVersion = f.opts.KubeVersion
+	}
+	helm := NewHelmRenderer(&Options{
+		Values:      ValuesOptions{Inline: values},
+		ReleaseName: chart.ReleaseName,
+		Namespace:   chart.Namespace,
+		KubeVersion: kubeVersion,
+		APIVersions: append(append([]string{}, chart.ApiVersions...), f.opts.APIVersions...),
+	})
+	for _, file := range files {
+		if err := helm.AddFile(file.Name, file.Data); err != nil {
+			return nil, err
+		}
+	}
+	result, err := helm.Render(f.ctx, []byte(source))
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates are rendered in random order, sort them so the build is reproducible
+	sort.Slice(result.Manifests, func(i, j int) bool {
+		return result.Manifests[i].Name < result.Manifests[j].Name
+	})
+	var out bytes.Buffer
+	for _, manifest := range result.Manifests {
+		out.WriteString("---\n")
+		out.Write(manifest.Raw)
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.charts = append(f.charts, map[string]interface{}{
+		"name":    result.Name,
+		"version": result.Version,
+		"release": chart.ReleaseName,
+		"source":  source,
+	})
+	return out.Bytes(), nil
+}
+
+// loadHelmChart returns the files of the chart and where they were read from. The chart is
+// looked up unpacked under chartHome, as kustomize stores pulled charts, then in the chart cache.
+func (f *rootedFS) loadHelmChart(chartHome string, chart types.HelmChart) ([]*loader.BufferedFile, string, error) {
+	candidates := []string{filepath.Join(chartHome, chart.Name)}
+	if chart.Version != "" && chart.Repo != "" {
+		candidates = append([]string{filepath.Join(chartHome, chart.Name+"-"+chart.Version, chart.Name)}, candidates...)
+	}
+	for _, candidate := range candidates {
+		if !f.IsDir(candidate) {
+			continue
+		}
+		files, err := readChartDir(candidate)
+		return files, candidate, err
+	}
+
+	if f.opts.ChartCache != "" {
+		archive, version, err := FindPackagedChart(f.opts.ChartCache, chart.Name, chart.Version)
+		if err != nil {
+			return nil, "", err
+		}
+		if archive != nil {
+			files, err := loader.LoadArchiveFiles(bytes.NewReader(archive))
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to read packaged chart %s-%s: %w", chart.Name, version, err)
+			}
+			return files, filepath.Join(f.opts.ChartCache, chart.Name+"-"+version+".tgz"), nil
+		}
+	}
+	return nil, "", fmt.Errorf("chart not found under %s or in the chart cache", chartHome)
+}
+
+// readChartDir reads the files of an unpacked chart
+func readChartDir(dir string) ([]*loader.BufferedFile, error) {
+	var files []*loader.BufferedFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, &loader.BufferedFile{Name: filepath.ToSlash(rel), Data: content})
+		return nil
+	})
+	return files, err
+}
+
+// helmChartValues merges the values of a helmCharts entry as kustomize does: valuesInline is
+// merged with valuesFile according to valuesMerge, then additionalValuesFiles are applied
+func (f *rootedFS) helmChartValues(dir string, chart types.HelmChart) (map[string]interface{}, error) {
+	read := func(name string) (map[string]interface{}, error) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		content, err := f.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file %s: %w", name, err)
+		}
+		values := make(map[string]interface{})
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse values file %s: %w", name, err)
+		}
+		return values, nil
+	}
+
+	values := make(map[string]interface{})
+	if chart.ValuesFile != "" {
+		fileValues, err := read(chart.ValuesFile)
+		if err != nil {
+			return nil, err
+		}
+		values = fileValues
+	}
+	switch chart.ValuesMerge {
+	case "", "override":
+		values = mergeValues(values, chart.ValuesInline)
+	case "merge":
+		values = mergeValues(chart.ValuesInline, values)
+	case "replace":
+		if len(chart.ValuesInline) > 0 {
+			values = chart.ValuesInline
+		}
+	default:
+		return nil, fmt.Errorf("valuesMerge must be one of merge, override or replace, got %q", chart.ValuesMerge)
+	}
+	for _, name := range chart.AdditionalValuesFiles {
+		additional, err := read(name)
+		if err != nil {
+			return nil, err
+		}
+		values = mergeValues(values, additional)
+	}
+	return values, nil
+}
@@ -2,8 +2,15 @@ package renderer
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 )
 
 func TestKustomizeRenderer_GetOptions(t *testing.T) {
@@ -182,3 +189,221 @@ func TestKustomizeRenderer_SetOptionsNil(t *testing.T) {
 		}
 	}
 }
+
+func TestKustomizeRenderer_RenderDir(t *testing.T) {
+	root := t.TempDir()
+	role := func(name string) string {
+		return "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: " + name + "\nrules:\n  - apiGroups: [\"\"]\n    resources: [\"pods\"]\n    verbs: [\"get\"]\n"
+	}
+	chart := map[string]string{
+		"Chart.yaml":          "apiVersion: v2\nname: operator\nversion: 1.2.0\n",
+		"values.yaml":         "roleName: operator\n",
+		"templates/role.yaml": "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: {{ .Values.roleName }}\n",
+	}
+	files := map[string]string{
+		"repo/.git/HEAD":                           "ref: refs/heads/main\n",
+		"repo/base/kustomization.yaml":             "resources:\n  - role.yaml\n",
+		"repo/base/role.yaml":                      role("base"),
+		"repo/overlays/prod/kustomization.yaml":    "resources:\n  - ../../base\nnamePrefix: prod-\n",
+		"repo/overlays/outside/kustomization.yaml": "resources:\n  - ../../../shared\n",
+		"repo/overlays/helm/kustomization.yaml":    "helmCharts:\n  - name: operator\n    releaseName: ops\n    valuesInline:\n      roleName: inline\n",
+		"repo/overlays/cached/kustomization.yaml":  "resources:\n  - ../../base\nhelmCharts:\n  - name: operator\n    version: 1.x\n",
+		"shared/kustomization.yaml":                "resources:\n  - role.yaml\n",
+		"shared/role.yaml":                         role("shared"),
+		"chart/Chart.yaml":                         chart["Chart.yaml"],
+		"chart/values.yaml":                        chart["values.yaml"],
+		"chart/templates/role.yaml":                chart["templates/role.yaml"],
+	}
+	for name, content := range chart {
+		files["repo/overlays/helm/charts/operator/"+name] = content
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Package the chart into a chart cache
+	cache := filepath.Join(root, "cache")
+	loaded, err := loader.LoadDir(filepath.Join(root, "chart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(cache, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chartutil.Save(loaded, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		overlay   string
+		opts      *Options
+		wantRoles []string
+		wantErr   string
+	}{
+		{
+			name:      "base in a parent directory",
+			overlay:   "repo/overlays/prod",
+			opts:      &Options{},
+			wantRoles: []string{"prod-base"},
+		},
+		{
+			name:    "base outside the git working tree",
+			overlay: "repo/overlays/outside",
+			opts:    &Options{},
+			wantErr: "outside the kustomize root",
+		},
+		{
+			name:      "base inside the configured root",
+			overlay:   "repo/overlays/outside",
+			opts:      &Options{KustomizeRoot: root},
+			wantRoles: []string{"shared"},
+		},
+		{
+			name:    "base outside the configured root",
+			overlay: "repo/overlays/prod",
+			opts:    &Options{KustomizeRoot: filepath.Join(root, "repo", "overlays")},
+			wantErr: "outside the kustomize root",
+		},
+		{
+			name:    "helm charts disabled",
+			overlay: "repo/overlays/helm",
+			opts:    &Options{},
+			wantErr: "--kustomize-helm",
+		},
+		{
+			name:      "helm chart from the chart home",
+			overlay:   "repo/overlays/helm",
+			opts:      &Options{KustomizeHelm: true},
+			wantRoles: []string{"inline"},
+		},
+		{
+			name:      "helm chart from the chart cache",
+			overlay:   "repo/overlays/cached",
+			opts:      &Options{KustomizeHelm: true, ChartCache: cache},
+			wantRoles: []string{"base", "operator"},
+		},
+		{
+			name:    "helm chart missing from the chart cache",
+			overlay: "repo/overlays/cached",
+			opts:    &Options{KustomizeHelm: true},
+			wantErr: "chart not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewKustomizeRenderer(tt.opts)
+			result, err := r.Render(context.Background(), []byte(filepath.Join(root, tt.overlay)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			var roles []string
+			for _, m := range result.Manifests {
+				roles = append(roles, m.Name)
+			}
+			sort.Strings(roles)
+			if !reflect.DeepEqual(roles, tt.wantRoles) {
+				t.Errorf("roles = %v, want %v", roles, tt.wantRoles)
+			}
+			for _, m := range result.Manifests {
+				// Resources record the file they were read from instead of an origin annotation
+				if metadata, _ := m.Content["metadata"].(map[string]interface{}); metadata["annotations"] != nil {
+					t.Errorf("manifest %s annotations = %v, want none", m.Name, metadata["annotations"])
+				}
+				if strings.HasSuffix(m.Name, "base") {
+					want := map[string]interface{}{"file": filepath.Join(root, "repo", "base", "role.yaml"), "docNum": 1, "line": 1}
+					if !reflect.DeepEqual(m.Metadata, want) {
+						t.Errorf("manifest %s metadata = %v, want %v", m.Name, m.Metadata, want)
+					}
+				}
+			}
+			kustomize, _ := result.Extra["kustomize"].(map[string]interface{})
+			if kustomize["root"] == nil {
+				t.Errorf("kustomize metadata = %v, want the root", result.Extra)
+			}
+		})
+	}
+}
+
+func TestOriginDocument(t *testing.T) {
+	role := func(name string) *Manifest {
+		return &Manifest{Name: name, Content: map[string]interface{}{"kind": "Role", "metadata": map[string]interface{}{"name": name}}}
+	}
+	documents := []*Manifest{role("reader"), role("reader-extra"), role("base")}
+
+	tests := []struct {
+		name           string
+		resource       string
+		prefix, suffix string
+		want           string
+	}{
+		{name: "unchanged name", resource: "reader", want: "reader"},
+		{name: "prefix and suffix", resource: "prod-reader-v1", prefix: "prod-", suffix: "-v1", want: "reader"},
+		{name: "name containing another document", resource: "prod-reader-extra", prefix: "prod-", want: "reader-extra"},
+		{name: "unknown prefix is not guessed", resource: "staging-base", prefix: "prod-"},
+		{name: "other kind", resource: "reader-binding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := originDocument(documents, "Role", tt.resource, tt.prefix, tt.suffix)
+			if (got == nil && tt.want != "") || (got != nil && got.Name != tt.want) {
+				t.Errorf("originDocument() = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameAffixes(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"base/kustomization.yaml":             "resources:\n  - role.yaml\nnameSuffix: -v1\n",
+		"base/role.yaml":                      "",
+		"overlays/staging/kustomization.yaml": "resources:\n  - ../../base\n  - binding.yaml\nnamePrefix: staging-\n",
+		"overlays/staging/binding.yaml":       "",
+		"overlays/prod/kustomization.yaml":    "resources:\n  - ../staging\n  - ../prod\nnamePrefix: prod-\n",
+		"other/role.yaml":                     "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		file       string
+		wantPrefix string
+		wantSuffix string
+		wantOK     bool
+	}{
+		{name: "base through two overlays", file: "base/role.yaml", wantPrefix: "prod-staging-", wantSuffix: "-v1", wantOK: true},
+		{name: "resource of an intermediate overlay", file: "overlays/staging/binding.yaml", wantPrefix: "prod-staging-", wantOK: true},
+		{name: "file not included", file: "other/role.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, suffix, ok := nameAffixes(filepath.Join(root, "overlays", "prod"), filepath.Join(root, tt.file), make(map[string]bool))
+			if prefix != tt.wantPrefix || suffix != tt.wantSuffix || ok != tt.wantOK {
+				t.Errorf("nameAffixes() = %q, %q, %v, want %q, %q, %v", prefix, suffix, ok, tt.wantPrefix, tt.wantSuffix, tt.wantOK)
+			}
+		})
+	}
+}
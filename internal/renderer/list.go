@@ -0,0 +1,40 @@
+package renderer
+
+import "strings"
+
+// isList reports whether obj is a list kind, such as List, RoleList or ClusterRoleBindingList,
+// holding its objects under items
+func isList(obj map[string]interface{}) bool {
+	kind, _ := obj["kind"].(string)
+	_, ok := obj["items"].([]interface{})
+	return ok && strings.HasSuffix(kind, "List")
+}
+
+// unwrapList returns the items of a list kind, or obj itself for any other kind. Items of typed
+// lists returned by the Kubernetes API, such as RoleList, omit their kind and apiVersion, which
+// are taken from the list. Nested lists are unwrapped and items that are not objects are skipped.
+func unwrapList(obj map[string]interface{}) []map[string]interface{} {
+	if !isList(obj) {
+		return []map[string]interface{}{obj}
+	}
+
+	kind, _ := obj["kind"].(string)
+	itemKind := strings.TrimSuffix(kind, "List")
+	apiVersion, _ := obj["apiVersion"].(string)
+
+	var objects []map[string]interface{}
+	for _, raw := range obj["items"].([]interface{}) {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := item["kind"]; !ok && itemKind != "" {
+			item["kind"] = itemKind
+		}
+		if _, ok := item["apiVersion"]; !ok && apiVersion != "" && itemKind != "" {
+			item["apiVersion"] = apiVersion
+		}
+		objects = append(objects, unwrapList(item)...)
+	}
+	return objects
+}
@@ -0,0 +1,60 @@
+package renderer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnwrapList(t *testing.T) {
+	role := map[string]interface{}{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "Role", "metadata": map[string]interface{}{"name": "reader"}}
+
+	tests := []struct {
+		name string
+		obj  map[string]interface{}
+		want []map[string]interface{}
+	}{
+		{
+			name: "not a list",
+			obj:  role,
+			want: []map[string]interface{}{role},
+		},
+		{
+			name: "kind ending in List without items",
+			obj:  map[string]interface{}{"kind": "WatchList"},
+			want: []map[string]interface{}{{"kind": "WatchList"}},
+		},
+		{
+			name: "list keeps the kind of its items",
+			obj:  map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": []interface{}{role, "not an object"}},
+			want: []map[string]interface{}{role},
+		},
+		{
+			name: "typed list sets the kind and apiVersion of its items",
+			obj: map[string]interface{}{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRoleBindingList", "items": []interface{}{
+				map[string]interface{}{"metadata": map[string]interface{}{"name": "admin"}},
+			}},
+			want: []map[string]interface{}{
+				{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRoleBinding", "metadata": map[string]interface{}{"name": "admin"}},
+			},
+		},
+		{
+			name: "nested lists",
+			obj: map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": []interface{}{
+				map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": []interface{}{role}},
+			}},
+			want: []map[string]interface{}{role},
+		},
+		{
+			name: "empty list",
+			obj:  map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": []interface{}{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unwrapList(tt.obj); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unwrapList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,8 +17,24 @@ type Options struct {
 	IncludeMetadata bool
 	// OutputFormat specifies the desired output format (e.g., yaml, json)
 	OutputFormat string
-	// Values is a path to a values.yaml file used for rendering a helm chart
-	Values string
+	// Values are the user supplied values used for rendering a helm chart
+	Values ValuesOptions
+	// ReleaseName is the helm release name, the chart name is used when empty
+	ReleaseName string
+	// Namespace is the helm release namespace, "default" is used when empty
+	Namespace string
+	// KubeVersion is the Kubernetes version exposed as .Capabilities.KubeVersion
+	KubeVersion string
+	// APIVersions are additional API versions exposed as .Capabilities.APIVersions
+	APIVersions []string
+	// KustomizeRoot bounds the directories a Kustomize overlay and its bases may be read from,
+	// the enclosing git working tree or the overlay directory when empty
+	KustomizeRoot string
+	// KustomizeHelm enables the helmCharts generator of Kustomize overlays
+	KustomizeHelm bool
+	// ChartCache is a directory with packaged charts (name-version.tgz) used for the helmCharts
+	// of Kustomize overlays that are not unpacked under their chartHome
+	ChartCache string
 }
 
 // DefaultOptions returns a new Options with default values
@@ -27,7 +43,6 @@ func DefaultOptions() *Options {
 		ValidateOutput:  true,
 		IncludeMetadata: true,
 		OutputFormat:    "yaml",
-		Values:          "",
 	}
 }
 
@@ -0,0 +1,87 @@
+package renderer
+
+import (
+	"fmt"
+	"os"
+
+	yaml "gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/strvals"
+)
+
+// ValuesOptions holds the user supplied values of a helm chart. They are merged over
+// the chart defaults in the same order as helm: value files, --set, --set-string and --set-file
+type ValuesOptions struct {
+	// ValueFiles are paths to values.yaml files, later files take precedence
+	ValueFiles []string
+	// Inline values are merged over the value files, such as the valuesInline of a Kustomize helm chart
+	Inline map[string]interface{}
+	// Values are key=value overrides (--set)
+	Values []string
+	// StringValues are key=value overrides kept as strings (--set-string)
+	StringValues []string
+	// FileValues are key=path overrides set to the content of a file (--set-file)
+	FileValues []string
+}
+
+// Merge merges the value files and overrides into a single values map
+func (o ValuesOptions) Merge() (map[string]interface{}, error) {
+	base := make(map[string]interface{})
+
+	for _, path := range o.ValueFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file %s: %w", path, err)
+		}
+		current := make(map[string]interface{})
+		if err := yaml.Unmarshal(content, &current); err != nil {
+			return nil, fmt.Errorf("failed to parse values file %s: %w", path, err)
+		}
+		base = mergeValues(base, current)
+	}
+	base = mergeValues(base, o.Inline)
+
+	for _, value := range o.Values {
+		if err := strvals.ParseInto(value, base); err != nil {
+			return nil, fmt.Errorf("failed to parse --set %s: %w", value, err)
+		}
+	}
+
+	for _, value := range o.StringValues {
+		if err := strvals.ParseIntoString(value, base); err != nil {
+			return nil, fmt.Errorf("failed to parse --set-string %s: %w", value, err)
+		}
+	}
+
+	readFile := func(rs []rune) (interface{}, error) {
+		content, err := os.ReadFile(string(rs))
+		if err != nil {
+			return nil, err
+		}
+		return string(content), nil
+	}
+	for _, value := range o.FileValues {
+		if err := strvals.ParseIntoFile(value, base, readFile); err != nil {
+			return nil, fmt.Errorf("failed to parse --set-file %s: %w", value, err)
+		}
+	}
+
+	return base, nil
+}
+
+// mergeValues merges b into a recursively, values of b take precedence
+func mergeValues(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		if v, ok := v.(map[string]interface{}); ok {
+			if bv, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeValues(bv, v)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package renderer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValuesOptions_Merge(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.yaml", "rbac:\n  create: true\n  name: base\nreplicas: 1\n")
+	override := write("override.yaml", "rbac:\n  name: override\n")
+	invalid := write("invalid.yaml", "rbac: [\n")
+	policy := write("policy.rego", "package rbac")
+
+	tests := []struct {
+		name    string
+		opts    ValuesOptions
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "no values",
+			want: map[string]interface{}{},
+		},
+		{
+			name: "later files take precedence",
+			opts: ValuesOptions{ValueFiles: []string{base, override}},
+			want: map[string]interface{}{
+				"rbac":     map[string]interface{}{"create": true, "name": "override"},
+				"replicas": 1,
+			},
+		},
+		{
+			name: "set overrides files",
+			opts: ValuesOptions{ValueFiles: []string{base}, Values: []string{"rbac.create=false,replicas=3"}},
+			want: map[string]interface{}{
+				"rbac":     map[string]interface{}{"create": false, "name": "base"},
+				"replicas": int64(3),
+			},
+		},
+		{
+			name: "inline values override files",
+			opts: ValuesOptions{ValueFiles: []string{base}, Inline: map[string]interface{}{"rbac": map[string]interface{}{"name": "inline"}}},
+			want: map[string]interface{}{
+				"rbac":     map[string]interface{}{"create": true, "name": "inline"},
+				"replicas": 1,
+			},
+		},
+		{
+			name: "set-string keeps strings",
+			opts: ValuesOptions{Values: []string{"replicas=3"}, StringValues: []string{"replicas=3", "tag=true"}},
+			want: map[string]interface{}{"replicas": "3", "tag": "true"},
+		},
+		{
+			name: "set-file reads the file content",
+			opts: ValuesOptions{Values: []string{"policy=inline"}, FileValues: []string{"policy=" + policy}},
+			want: map[string]interface{}{"policy": "package rbac"},
+		},
+		{
+			name:    "missing values file",
+			opts:    ValuesOptions{ValueFiles: []string{filepath.Join(tmpDir, "missing.yaml")}},
+			wantErr: "failed to read values file",
+		},
+		{
+			name:    "invalid values file",
+			opts:    ValuesOptions{ValueFiles: []string{invalid}},
+			wantErr: "failed to parse values file",
+		},
+		{
+			name:    "invalid set",
+			opts:    ValuesOptions{Values: []string{"rbac.create"}},
+			wantErr: "failed to parse --set",
+		},
+		{
+			name:    "missing set-file",
+			opts:    ValuesOptions{FileValues: []string{"policy=" + filepath.Join(tmpDir, "missing.rego")}},
+			wantErr: "failed to parse --set-file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Merge()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Merge() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
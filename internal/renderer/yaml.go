@@ -48,11 +48,16 @@ func (r *YAMLRenderer) Render(ctx context.Context, input []byte) (*Result, error
 		default:
 		}
 
-		var obj map[string]interface{}
-		err := decoder.Decode(&obj)
+		// Decode the node first to keep the line the document starts at
+		var node yaml.Node
+		err := decoder.Decode(&node)
 		if err == io.EOF {
 			break
 		}
+		var obj map[string]interface{}
+		if err == nil {
+			err = node.Decode(&obj)
+		}
 		if err != nil {
 			result.Warnings = append(result.Warnings,
 				fmt.Sprintf("failed to parse document %d: %v", docNum+1, err))
@@ -66,55 +71,99 @@ func (r *YAMLRenderer) Render(ctx context.Context, input []byte) (*Result, error
 			continue
 		}
 
-		// Get name from metadata if available
-		var name string
-		if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
-			if n, ok := metadata["name"].(string); ok {
-				name = n
+		objects := unwrapList(obj)
+		lines := documentLines(&node, len(objects), isList(obj))
+		for idx, object := range objects {
+			// Get name from metadata if available
+			var name string
+			if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+				if n, ok := metadata["name"].(string); ok {
+					name = n
+				}
 			}
-		}
-
-		// If no name found, generate one based on document number
-		if name == "" {
-			name = fmt.Sprintf("document-%d", docNum+1)
-		}
 
-		// Re-encode the document based on output format
-		var raw []byte
-		if r.opts.OutputFormat == "json" {
-			raw, err = yaml.Marshal(obj)
-			if err != nil {
-				result.Warnings = append(result.Warnings,
-					fmt.Sprintf("document %d: failed to encode as JSON: %v", docNum, err))
-				continue
+			// If no name found, generate one based on document number
+			if name == "" {
+				name = fmt.Sprintf("document-%d", docNum)
+				if isList(obj) {
+					name = fmt.Sprintf("document-%d-item-%d", docNum, idx+1)
+				}
 			}
-		} else {
-			raw, err = yaml.Marshal(obj)
-			if err != nil {
-				result.Warnings = append(result.Warnings,
-					fmt.Sprintf("document %d: failed to encode as YAML: %v", docNum, err))
-				continue
+
+			// Re-encode the document based on output format
+			var raw []byte
+			if r.opts.OutputFormat == "json" {
+				raw, err = yaml.Marshal(object)
+				if err != nil {
+					result.Warnings = append(result.Warnings,
+						fmt.Sprintf("document %d: failed to encode as JSON: %v", docNum, err))
+					continue
+				}
+			} else {
+				raw, err = yaml.Marshal(object)
+				if err != nil {
+					result.Warnings = append(result.Warnings,
+						fmt.Sprintf("document %d: failed to encode as YAML: %v", docNum, err))
+					continue
+				}
 			}
-		}
 
-		manifest := &Manifest{
-			Name:    name,
-			Content: obj,
-			Raw:     raw,
-		}
+			manifest := &Manifest{
+				Name:    name,
+				Content: object,
+				Raw:     raw,
+			}
 
-		if r.opts.IncludeMetadata {
-			manifest.Metadata = map[string]interface{}{
-				"docNum": docNum + 1,
+			if r.opts.IncludeMetadata {
+				manifest.Metadata = map[string]interface{}{
+					"docNum": docNum,
+				}
+				if idx < len(lines) && lines[idx] > 0 {
+					manifest.Metadata["line"] = lines[idx]
+				}
+				// Objects unwrapped from a list record their position in the items
+				if isList(obj) {
+					manifest.Metadata["listItem"] = idx + 1
+				}
 			}
-		}
 
-		result.Manifests = append(result.Manifests, manifest)
+			result.Manifests = append(result.Manifests, manifest)
+		}
 	}
 
 	return result, nil
 }
 
+// documentLines returns the line each of the n objects of a document starts at. The items of
+// a list start at their own line, unless nested lists or skipped items make them ambiguous, in
+// which case every object starts at the line of the document.
+func documentLines(doc *yaml.Node, n int, list bool) []int {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	lines := make([]int, n)
+	for i := range lines {
+		lines[i] = root.Line
+	}
+	if !list || root.Kind != yaml.MappingNode {
+		return lines
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		items := root.Content[i+1]
+		if root.Content[i].Value != "items" || items.Kind != yaml.SequenceNode || len(items.Content) != n {
+			continue
+		}
+		for j, item := range items.Content {
+			if item.Kind != yaml.MappingNode {
+				return lines
+			}
+			lines[j] = item.Line
+		}
+	}
+	return lines
+}
+
 // Validate checks if the input is valid YAML
 func (r *YAMLRenderer) Validate(input []byte) error {
 	if len(input) == 0 {
@@ -2,6 +2,7 @@ package renderer
 
 import (
 	"context"
+	"reflect"
 	"testing"
 )
 
@@ -41,6 +42,20 @@ func TestYAMLRenderer(t *testing.T) {
 			wantWarnings:  0,
 			wantErr:       false,
 		},
+		{
+			name:          "kubectl json list",
+			input:         "list.json",
+			wantManifests: 2, // ClusterRole and ClusterRoleBinding
+			wantWarnings:  0,
+			wantErr:       false,
+		},
+		{
+			name:          "typed role list",
+			input:         "role-list.yaml",
+			wantManifests: 2, // Two Roles
+			wantWarnings:  0,
+			wantErr:       false,
+		},
 		{
 			name:          "invalid yaml",
 			input:         "invalid.yaml",
@@ -268,3 +283,57 @@ func TestYAMLRendererRenderContextCancel(t *testing.T) {
 		t.Fatalf("expected context canceled, got %v", err)
 	}
 }
+
+func TestYAMLRenderer_List(t *testing.T) {
+	input := []byte("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: operator\n---\n" +
+		"apiVersion: rbac.authorization.k8s.io/v1\nkind: RoleList\nitems:\n  - metadata:\n      name: reader\n  - rules: []\n")
+
+	result, err := NewYAMLRenderer().Render(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	want := []struct {
+		name     string
+		kind     string
+		docNum   int
+		listItem interface{}
+		line     int
+	}{
+		{name: "operator", kind: "ServiceAccount", docNum: 1, line: 1},
+		{name: "reader", kind: "Role", docNum: 2, listItem: 1, line: 9},
+		{name: "document-2-item-2", kind: "Role", docNum: 2, listItem: 2, line: 11},
+	}
+	if len(result.Manifests) != len(want) {
+		t.Fatalf("got %d manifests, want %d", len(result.Manifests), len(want))
+	}
+	for i, w := range want {
+		m := result.Manifests[i]
+		if m.Name != w.name || m.Content["kind"] != w.kind || m.Metadata["docNum"] != w.docNum || m.Metadata["listItem"] != w.listItem || m.Metadata["line"] != w.line {
+			t.Errorf("manifest %d = %s %v %v, want %+v", i, m.Name, m.Content["kind"], m.Metadata, w)
+		}
+	}
+}
+
+func TestYAMLRenderer_Lines(t *testing.T) {
+	input := []byte("# operator permissions\n---\napiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: operator\n---\n\n" +
+		"# the role\napiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: reader\n")
+
+	result, err := NewYAMLRenderer().Render(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	want := []map[string]interface{}{
+		{"docNum": 1, "line": 3},
+		{"docNum": 2, "line": 10},
+	}
+	if len(result.Manifests) != len(want) {
+		t.Fatalf("got %d manifests, want %d", len(result.Manifests), len(want))
+	}
+	for i, w := range want {
+		if !reflect.DeepEqual(result.Manifests[i].Metadata, w) {
+			t.Errorf("manifest %d metadata = %v, want %v", i, result.Manifests[i].Metadata, w)
+		}
+	}
+}
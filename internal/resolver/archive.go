@@ -0,0 +1,151 @@
+package resolver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+)
+
+// ChartArchiveResolver implements SourceResolver for packaged Helm charts (.tgz),
+// stored locally or behind an HTTP/HTTPS URL
+type ChartArchiveResolver struct {
+	source string
+	remote bool
+	opts   *Options
+	client *http.Client
+}
+
+// NewChartArchiveResolver creates a new ChartArchiveResolver, a nil client uses the default HTTP client
+func NewChartArchiveResolver(source string, opts *Options, client *http.Client) (*ChartArchiveResolver, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	r := &ChartArchiveResolver{
+		source: source,
+		remote: strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"),
+		opts:   opts,
+		client: client,
+	}
+	if r.remote && !isValidURL(source) {
+		return nil, fmt.Errorf("invalid URL: %s", source)
+	}
+	if !isChartArchive(source) {
+		return nil, fmt.Errorf("not a chart archive: %s", source)
+	}
+	return r, nil
+}
+
+// CanResolve checks if this resolver can handle the given source
+func (r *ChartArchiveResolver) CanResolve(source string) bool {
+	if !isChartArchive(source) {
+		return false
+	}
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return isValidURL(source)
+	}
+	info, err := os.Stat(source)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Resolve reads the chart archive and renders it
+func (r *ChartArchiveResolver) Resolve(ctx context.Context) (*renderer.Result, *ResolverMetadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var archive []byte
+	var err error
+	typ := SourceTypeFile
+	if r.remote {
+		typ = SourceTypeRemote
+		archive, err = fetchURL(ctx, r.client, r.source, r.opts.maxArchiveSize())
+	} else {
+		archive, err = readArchiveFile(r.source, r.opts.maxArchiveSize())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := renderChartArchive(ctx, archive, r.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum := sha256.Sum256(archive)
+	return result, chartArchiveMetadata(result, typ, r.source, int64(len(archive)), map[string]interface{}{
+		"archive": r.source,
+		"digest":  "sha256:" + hex.EncodeToString(sum[:]),
+	}), nil
+}
+
+// isChartArchive reports whether source points to a packaged chart by its extension,
+// query strings of URLs are ignored
+func isChartArchive(source string) bool {
+	name := source
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" && u.Host != "" {
+		name = u.Path
+	}
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".tar.gz")
+}
+
+// readArchiveFile reads a local chart archive of at most maxSize bytes
+func readArchiveFile(path string, maxSize int64) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a regular file: %s", path)
+	}
+	if info.Size() > maxSize {
+		return nil, fmt.Errorf("chart archive %s is larger than the maximum size of %d bytes", path, maxSize)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return content, nil
+}
+
+// fetchURL downloads url and returns at most maxSize bytes of the response body,
+// larger responses are rejected
+func fetchURL(ctx context.Context, client *http.Client, url string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "rbac-scope/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP request to %s failed with status: %s", url, resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("response of %s is larger than the maximum size of %d bytes", url, maxSize)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("response of %s is larger than the maximum size of %d bytes", url, maxSize)
+	}
+	return content, nil
+}
@@ -0,0 +1,199 @@
+package resolver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarEntry is a file of a generated test archive
+type tarEntry struct {
+	name     string
+	content  string
+	typeflag byte
+}
+
+func buildArchive(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hd := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: e.typeflag}
+		if e.typeflag == 0 {
+			hd.Typeflag = tar.TypeReg
+		}
+		if hd.Typeflag == tar.TypeSymlink {
+			hd.Linkname = e.content
+			hd.Size = 0
+		}
+		if err := tw.WriteHeader(hd); err != nil {
+			t.Fatal(err)
+		}
+		if hd.Size > 0 {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestChartArchiveResolver_Resolve(t *testing.T) {
+	archive := readTestChart(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/test-chart-0.1.0.tgz" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		source   string
+		opts     *Options
+		wantType SourceType
+		wantErr  string
+	}{
+		{name: "local archive", source: testChartArchive, wantType: SourceTypeFile},
+		{name: "remote archive", source: server.URL + "/test-chart-0.1.0.tgz", wantType: SourceTypeRemote},
+		{name: "remote archive with query", source: server.URL + "/test-chart-0.1.0.tgz?token=abc", wantType: SourceTypeRemote},
+		{name: "local archive too large", source: testChartArchive, opts: &Options{MaxArchiveSize: 16}, wantErr: "larger than the maximum size"},
+		{name: "remote archive too large", source: server.URL + "/test-chart-0.1.0.tgz", opts: &Options{MaxArchiveSize: 16}, wantErr: "larger than the maximum size"},
+		{name: "missing local archive", source: "missing-0.1.0.tgz", wantErr: "failed to stat file"},
+		{name: "missing remote archive", source: server.URL + "/missing.tgz", wantErr: "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewChartArchiveResolver(tt.source, tt.opts, server.Client())
+			if err != nil {
+				t.Fatalf("NewChartArchiveResolver() error = %v", err)
+			}
+			result, meta, err := r.Resolve(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if meta.Type != tt.wantType || meta.RendererType != RendererTypeHelm {
+				t.Errorf("metadata type = %v/%v, want %v/helm", meta.Type, meta.RendererType, tt.wantType)
+			}
+			if meta.Name != "test-chart" || meta.Version != "0.1.0" || meta.Size != int64(len(archive)) {
+				t.Errorf("unexpected metadata %+v", meta)
+			}
+			chart, _ := meta.Extra["chart"].(map[string]interface{})
+			if chart["digest"] != sha256Digest(archive) {
+				t.Errorf("chart digest = %v, want %v", chart["digest"], sha256Digest(archive))
+			}
+			if len(result.Manifests) != 1 || result.Manifests[0].Content["kind"] != "Role" {
+				t.Errorf("unexpected manifests %+v", result.Manifests)
+			}
+		})
+	}
+}
+
+func TestChartArchiveResolver_CanResolve(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "dir.tgz"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	r := &ChartArchiveResolver{}
+
+	tests := map[string]bool{
+		testChartArchive: true,
+		"https://example.com/charts/operator-1.0.0.tgz":   true,
+		"https://example.com/operator-1.0.0.tar.gz?ref=1": true,
+		"https://example.com/rbac.yaml":                   false,
+		"missing-0.1.0.tgz":                               false,
+		filepath.Join(tmpDir, "dir.tgz"):                  false,
+	}
+	for source, want := range tests {
+		if got := r.CanResolve(source); got != want {
+			t.Errorf("CanResolve(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestCheckChartArchive(t *testing.T) {
+	chartYAML := "apiVersion: v2\nname: test\nversion: 0.1.0\n"
+
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		maxSize int64
+		wantErr string
+	}{
+		{
+			name:    "valid chart",
+			entries: []tarEntry{{name: "test/", typeflag: tar.TypeDir}, {name: "test/Chart.yaml", content: chartYAML}},
+		},
+		{
+			name:    "parent directory",
+			entries: []tarEntry{{name: "test/../../etc/passwd", content: "x"}},
+			wantErr: "parent directory",
+		},
+		{
+			name:    "absolute path",
+			entries: []tarEntry{{name: "/etc/passwd", content: "x"}},
+			wantErr: "absolute path",
+		},
+		{
+			name:    "windows drive path",
+			entries: []tarEntry{{name: "test\\c:\\windows", content: "x"}},
+			wantErr: "absolute path",
+		},
+		{
+			name:    "symlink",
+			entries: []tarEntry{{name: "test/values.yaml", content: "/etc/passwd", typeflag: tar.TypeSymlink}},
+			wantErr: "not a regular file",
+		},
+		{
+			name:    "decompressed size",
+			entries: []tarEntry{{name: "test/Chart.yaml", content: chartYAML}, {name: "test/big.yaml", content: strings.Repeat("a", 4096)}},
+			maxSize: 2048,
+			wantErr: "decompressed archive is larger",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = DefaultMaxArchiveSize
+			}
+			err := checkChartArchive(buildArchive(t, tt.entries...), maxSize)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkChartArchive() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkChartArchive() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := checkChartArchive([]byte("not gzip"), DefaultMaxArchiveSize); err == nil {
+		t.Error("checkChartArchive() expected error for invalid gzip data")
+	}
+}
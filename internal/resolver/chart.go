@@ -0,0 +1,123 @@
+package resolver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// renderChartArchive renders a packaged Helm chart (.tgz) with the helm renderer
+func renderChartArchive(ctx context.Context, archive []byte, opts *Options) (*renderer.Result, error) {
+	if err := checkChartArchive(archive, opts.maxArchiveSize()); err != nil {
+		return nil, fmt.Errorf("invalid chart archive: %w", err)
+	}
+	archiveFiles, err := loader.LoadArchiveFiles(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart archive: %w", err)
+	}
+	files := make(map[string][]byte, len(archiveFiles))
+	for _, file := range archiveFiles {
+		files[file.Name] = file.Data
+	}
+
+	// Archives have no directory for file:// dependencies, only packaged ones are added
+	if err := vendorChartDependencies(files, "", opts); err != nil {
+		return nil, err
+	}
+
+	r, err := GetRendererForType(RendererTypeHelm, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get renderer: %w", err)
+	}
+	for name, content := range files {
+		if err := r.AddFile(name, content); err != nil {
+			return nil, fmt.Errorf("failed to add file %s: %w", name, err)
+		}
+	}
+
+	result, err := r.Render(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render: %w", err)
+	}
+	return result, nil
+}
+
+// chartArchiveMetadata creates the resolver metadata of a rendered chart archive,
+// chart describes where the archive was pulled from
+func chartArchiveMetadata(result *renderer.Result, typ SourceType, source string, size int64, chart map[string]interface{}) *ResolverMetadata {
+	extra := result.Extra
+	if extra == nil {
+		extra = make(map[string]interface{})
+	}
+	extra["chart"] = chart
+	return &ResolverMetadata{
+		Name:         result.Name,
+		Version:      result.Version,
+		Type:         typ,
+		RendererType: RendererTypeHelm,
+		Path:         source,
+		Size:         size,
+		ModTime:      time.Now(),
+		Extra:        extra,
+	}
+}
+
+// checkChartArchive walks the entries of a chart archive before it is loaded, it rejects
+// entries escaping the chart directory, links and archives decompressing to more than maxSize bytes
+func checkChartArchive(archive []byte, maxSize int64) error {
+	if int64(len(archive)) > maxSize {
+		return fmt.Errorf("archive is larger than the maximum size of %d bytes", maxSize)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	remaining := maxSize
+	for {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := strings.ReplaceAll(hd.Name, "\\", "/")
+		if path.IsAbs(name) || strings.Contains(name, ":") {
+			return fmt.Errorf("entry %q has an absolute path", hd.Name)
+		}
+		for _, part := range strings.Split(name, "/") {
+			if part == ".." {
+				return fmt.Errorf("entry %q references a parent directory", hd.Name)
+			}
+		}
+
+		switch hd.Typeflag {
+		case tar.TypeReg, tar.TypeDir, tar.TypeXGlobalHeader, tar.TypeXHeader:
+		default:
+			return fmt.Errorf("entry %q is not a regular file", hd.Name)
+		}
+
+		// Count the decompressed bytes instead of trusting the header size
+		n, err := io.Copy(io.Discard, io.LimitReader(tr, remaining+1))
+		if err != nil {
+			return err
+		}
+		remaining -= n
+		if remaining < 0 {
+			return fmt.Errorf("decompressed archive is larger than the maximum size of %d bytes", maxSize)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+// clusterScheme is the prefix of live cluster sources, followed by an optional kubeconfig context
+const clusterScheme = "cluster://"
+
+// serviceAccountsGroupPrefix is followed by the namespace in the group of its service accounts
+const serviceAccountsGroupPrefix = "system:serviceaccounts:"
+
+// ClusterResolver implements SourceResolver for the RBAC policies, service accounts and workloads
+// deployed in a live cluster, such as cluster://prod for the prod context of the kubeconfig or
+// cluster:// for its current context. The objects are read into a YAML bundle that is rendered
+// like a local file, so a snapshot saved with SnapshotPath is analyzed the same way offline.
+type ClusterResolver struct {
+	source  string
+	context string // Kubeconfig context
+	server  string // API server URL
+	opts    *Options
+	client  kubernetes.Interface
+}
+
+// NewClusterResolver creates a new ClusterResolver. When client is nil a client is created for
+// the context of source from opts.Kubeconfig, or from the default kubeconfig loading rules.
+func NewClusterResolver(source string, opts *Options, client kubernetes.Interface) (*ClusterResolver, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	if !strings.HasPrefix(source, clusterScheme) {
+		return nil, fmt.Errorf("invalid cluster source: %s", source)
+	}
+	r := &ClusterResolver{
+		source:  source,
+		context: strings.TrimPrefix(source, clusterScheme),
+		opts:    opts,
+		client:  client,
+	}
+	if client != nil {
+		return r, nil
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = opts.Kubeconfig
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: r.context})
+	raw, err := config.RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if r.context == "" {
+		r.context = raw.CurrentContext
+	}
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load context %q from kubeconfig: %w", r.context, err)
+	}
+	r.server = restConfig.Host
+	if r.client, err = kubernetes.NewForConfig(restConfig); err != nil {
+		return nil, fmt.Errorf("failed to create cluster client: %w", err)
+	}
+	return r, nil
+}
+
+// CanResolve checks if this resolver can handle the given source
+func (r *ClusterResolver) CanResolve(source string) bool {
+	return strings.HasPrefix(source, clusterScheme)
+}
+
+// Resolve reads the objects from the cluster and returns them as rendered manifests
+func (r *ClusterResolver) Resolve(ctx context.Context) (*renderer.Result, *ResolverMetadata, error) {
+	objects, counts, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(objects) == 0 {
+		return nil, nil, fmt.Errorf("no RBAC objects found in cluster context %q", r.context)
+	}
+
+	bundle, err := r.bundle(objects)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.opts.SnapshotPath != "" {
+		if err := os.WriteFile(r.opts.SnapshotPath, bundle, 0o600); err != nil {
+			return nil, nil, fmt.Errorf("failed to write snapshot: %w", err)
+		}
+	}
+
+	rf := renderer.NewRendererFactory(&renderer.Options{
+		ValidateOutput:  r.opts.ValidateYAML,
+		IncludeMetadata: true,
+		OutputFormat:    "yaml",
+	})
+	yamlRenderer, err := rf.GetRenderer(renderer.RendererTypeYAML)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create renderer: %w", err)
+	}
+	result, err := yamlRenderer.Render(ctx, bundle)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.opts.SnapshotPath != "" {
+		setManifestFile(result.Manifests, r.opts.SnapshotPath)
+	}
+
+	cluster := map[string]interface{}{
+		"context": r.context,
+		"objects": counts,
+	}
+	if r.server != "" {
+		cluster["server"] = r.server
+	}
+	if len(r.opts.Namespaces) > 0 {
+		cluster["namespaces"] = r.opts.Namespaces
+	}
+	if len(r.opts.ExcludeNamespaces) > 0 {
+		cluster["excludeNamespaces"] = r.opts.ExcludeNamespaces
+	}
+	if r.opts.SnapshotPath != "" {
+		cluster["snapshot"] = r.opts.SnapshotPath
+	}
+
+	return result, &ResolverMetadata{
+		Name:    r.context,
+		Version: result.Version,
+		Type:    SourceTypeCluster,
+		Path:    r.source,
+		Size:    int64(len(bundle)),
+		ModTime: time.Now(),
+		Extra: map[string]interface{}{
+			"manifests": len(result.Manifests),
+			"warnings":  result.Warnings,
+			"cluster":   cluster,
+		},
+	}, nil
+}
+
+// clusterObject is an object read from the cluster with its type
+type clusterObject struct {
+	apiVersion string
+	kind       string
+	object     metav1.Object
+}
+
+// snapshot reads the ClusterRoles and ClusterRoleBindings, and the Roles, RoleBindings,
+// ServiceAccounts and workloads of the selected namespaces. Workloads owned by another
+// object, such as the ReplicaSets of a Deployment or its Pods, are left out.
+func (r *ClusterResolver) snapshot(ctx context.Context) ([]clusterObject, map[string]int, error) {
+	var objects []clusterObject
+	counts := make(map[string]int)
+	add := func(apiVersion, kind string, object metav1.Object) {
+		objects = append(objects, clusterObject{apiVersion: apiVersion, kind: kind, object: object})
+		counts[kind]++
+	}
+	const rbacVersion = "rbac.authorization.k8s.io/v1"
+
+	clusterRoles, err := r.client.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list cluster roles: %w", err)
+	}
+	for i := range clusterRoles.Items {
+		add(rbacVersion, "ClusterRole", &clusterRoles.Items[i])
+	}
+
+	clusterRoleBindings, err := r.client.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	for i := range clusterRoleBindings.Items {
+		if r.bindsSelectedNamespace(clusterRoleBindings.Items[i].Subjects) {
+			add(rbacVersion, "ClusterRoleBinding", &clusterRoleBindings.Items[i])
+		}
+	}
+
+	scopes := r.opts.Namespaces
+	if len(scopes) == 0 {
+		scopes = []string{metav1.NamespaceAll}
+	}
+	for _, ns := range scopes {
+		roles, err := r.client.RbacV1().Roles(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list roles: %w", err)
+		}
+		for i := range roles.Items {
+			add(rbacVersion, "Role", &roles.Items[i])
+		}
+
+		roleBindings, err := r.client.RbacV1().RoleBindings(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list role bindings: %w", err)
+		}
+		for i := range roleBindings.Items {
+			add(rbacVersion, "RoleBinding", &roleBindings.Items[i])
+		}
+
+		serviceAccounts, err := r.client.CoreV1().ServiceAccounts(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list service accounts: %w", err)
+		}
+		for i := range serviceAccounts.Items {
+			add("v1", "ServiceAccount", &serviceAccounts.Items[i])
+		}
+
+		if err := r.listWorkloads(ctx, ns, add); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Namespaced objects of excluded namespaces are dropped here, the API only filters by a single namespace
+	selected := objects[:0]
+	for _, o := range objects {
+		if o.object.GetNamespace() == "" || r.selectedNamespace(o.object.GetNamespace()) {
+			selected = append(selected, o)
+		} else {
+			counts[o.kind]--
+		}
+	}
+	objects = selected
+	for kind, count := range counts {
+		if count == 0 {
+			delete(counts, kind)
+		}
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.object.GetNamespace() != b.object.GetNamespace() {
+			return a.object.GetNamespace() < b.object.GetNamespace()
+		}
+		return a.object.GetName() < b.object.GetName()
+	})
+	return objects, counts, nil
+}
+
+// listWorkloads adds the workloads of namespace ns that are not owned by another object
+func (r *ClusterResolver) listWorkloads(ctx context.Context, ns string, add func(string, string, metav1.Object)) error {
+	addTopLevel := func(apiVersion, kind string, object metav1.Object) {
+		if len(object.GetOwnerReferences()) == 0 {
+			add(apiVersion, kind, object)
+		}
+	}
+
+	deployments, err := r.client.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		addTopLevel("apps/v1", "Deployment", &deployments.Items[i])
+	}
+
+	statefulSets, err := r.client.AppsV1().StatefulSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list stateful sets: %w", err)
+	}
+	for i := range statefulSets.Items {
+		addTopLevel("apps/v1", "StatefulSet", &statefulSets.Items[i])
+	}
+
+	daemonSets, err := r.client.AppsV1().DaemonSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list daemon sets: %w", err)
+	}
+	for i := range daemonSets.Items {
+		addTopLevel("apps/v1", "DaemonSet", &daemonSets.Items[i])
+	}
+
+	replicaSets, err := r.client.AppsV1().ReplicaSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list replica sets: %w", err)
+	}
+	for i := range replicaSets.Items {
+		addTopLevel("apps/v1", "ReplicaSet", &replicaSets.Items[i])
+	}
+
+	cronJobs, err := r.client.BatchV1().CronJobs(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list cron jobs: %w", err)
+	}
+	for i := range cronJobs.Items {
+		addTopLevel("batch/v1", "CronJob", &cronJobs.Items[i])
+	}
+
+	jobs, err := r.client.BatchV1().Jobs(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for i := range jobs.Items {
+		addTopLevel("batch/v1", "Job", &jobs.Items[i])
+	}
+
+	pods, err := r.client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+	for i := range pods.Items {
+		addTopLevel("v1", "Pod", &pods.Items[i])
+	}
+	return nil
+}
+
+// selectedNamespace reports whether ns passes the namespace filters
+func (r *ClusterResolver) selectedNamespace(ns string) bool {
+	for _, excluded := range r.opts.ExcludeNamespaces {
+		if ns == excluded {
+			return false
+		}
+	}
+	if len(r.opts.Namespaces) == 0 {
+		return true
+	}
+	for _, included := range r.opts.Namespaces {
+		if ns == included {
+			return true
+		}
+	}
+	return false
+}
+
+// bindsSelectedNamespace reports whether a cluster role binding with subjects is kept. Without
+// namespace filters every binding is kept, otherwise the bindings of a service account or the
+// service accounts group of a selected namespace, and the bindings of users and other groups which
+// do not belong to a namespace.
+func (r *ClusterResolver) bindsSelectedNamespace(subjects []rbacv1.Subject) bool {
+	if len(r.opts.Namespaces) == 0 && len(r.opts.ExcludeNamespaces) == 0 {
+		return true
+	}
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case rbacv1.ServiceAccountKind:
+			if r.selectedNamespace(subject.Namespace) {
+				return true
+			}
+		case rbacv1.GroupKind:
+			ns, ok := strings.CutPrefix(subject.Name, serviceAccountsGroupPrefix)
+			if !ok || r.selectedNamespace(ns) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// bundle encodes objects as a multi-document YAML snapshot. Server managed fields and the
+// status are dropped, they do not affect permissions.
+func (r *ClusterResolver) bundle(objects []clusterObject) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# rbac-scope snapshot of cluster context %q\n", r.context)
+	for _, o := range objects {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o.object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s: %w", o.kind, o.object.GetName(), err)
+		}
+		content["apiVersion"] = o.apiVersion
+		content["kind"] = o.kind
+		delete(content, "status")
+		if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+			delete(metadata, "managedFields")
+			delete(metadata, "creationTimestamp")
+			delete(metadata, "resourceVersion")
+			delete(metadata, "uid")
+			delete(metadata, "generation")
+		}
+
+		out, err := yaml.Marshal(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s %s: %w", o.kind, o.object.GetName(), err)
+		}
+		buf.WriteString("---\n")
+		buf.Write(out)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,274 @@
+package resolver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestCluster returns a fake clientset with an operator in the operators namespace, a
+// controller in kube-system and a cluster role bound to both service accounts, a user, all
+// authenticated users and the service accounts of kube-system
+func newTestCluster() *fake.Clientset {
+	meta := func(name, namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace, ResourceVersion: "42", UID: types.UID("uid-" + name)}
+	}
+	podSpec := func(sa string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			ServiceAccountName: sa,
+			Containers:         []corev1.Container{{Name: "manager", Image: "example.com/" + sa + ":1.0"}},
+		}}
+	}
+	owned := meta("operator-7d9f", "operators")
+	owned.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "operator"}}
+
+	return fake.NewClientset(
+		&rbacv1.ClusterRole{
+			ObjectMeta: meta("secret-reader", ""),
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "list"}}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: meta("operator-secret-reader", ""),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "secret-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "operator", Namespace: "operators"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: meta("controller-secret-reader", ""),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "secret-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "controller", Namespace: "kube-system"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: meta("authenticated-secret-reader", ""),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "secret-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:authenticated"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: meta("admin-secret-reader", ""),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "secret-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "jane"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: meta("kube-system-secret-reader", ""),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "secret-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:serviceaccounts:kube-system"}},
+		},
+		&rbacv1.Role{
+			ObjectMeta: meta("leader-election", "operators"),
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"*"}}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: meta("leader-election", "operators"),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "leader-election"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "operator", Namespace: "operators"}},
+		},
+		&corev1.ServiceAccount{ObjectMeta: meta("operator", "operators")},
+		&corev1.ServiceAccount{ObjectMeta: meta("controller", "kube-system")},
+		&appsv1.Deployment{ObjectMeta: meta("operator", "operators"), Spec: appsv1.DeploymentSpec{Template: podSpec("operator")}},
+		&appsv1.ReplicaSet{ObjectMeta: owned, Spec: appsv1.ReplicaSetSpec{Template: podSpec("operator")}},
+		&appsv1.DaemonSet{ObjectMeta: meta("controller", "kube-system"), Spec: appsv1.DaemonSetSpec{Template: podSpec("controller")}},
+	)
+}
+
+func TestClusterResolver_Resolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *Options
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "all namespaces",
+			opts: &Options{},
+			want: []string{
+				"ClusterRole/secret-reader",
+				"ClusterRoleBinding/admin-secret-reader",
+				"ClusterRoleBinding/authenticated-secret-reader",
+				"ClusterRoleBinding/controller-secret-reader",
+				"ClusterRoleBinding/kube-system-secret-reader",
+				"ClusterRoleBinding/operator-secret-reader",
+				"DaemonSet/kube-system/controller",
+				"Deployment/operators/operator",
+				"Role/operators/leader-election",
+				"RoleBinding/operators/leader-election",
+				"ServiceAccount/kube-system/controller",
+				"ServiceAccount/operators/operator",
+			},
+		},
+		{
+			name: "included namespace",
+			opts: &Options{Namespaces: []string{"operators"}},
+			want: []string{
+				"ClusterRole/secret-reader",
+				"ClusterRoleBinding/admin-secret-reader",
+				"ClusterRoleBinding/authenticated-secret-reader",
+				"ClusterRoleBinding/operator-secret-reader",
+				"Deployment/operators/operator",
+				"Role/operators/leader-election",
+				"RoleBinding/operators/leader-election",
+				"ServiceAccount/operators/operator",
+			},
+		},
+		{
+			name: "excluded namespace",
+			opts: &Options{ExcludeNamespaces: []string{"operators"}},
+			want: []string{
+				"ClusterRole/secret-reader",
+				"ClusterRoleBinding/admin-secret-reader",
+				"ClusterRoleBinding/authenticated-secret-reader",
+				"ClusterRoleBinding/controller-secret-reader",
+				"ClusterRoleBinding/kube-system-secret-reader",
+				"DaemonSet/kube-system/controller",
+				"ServiceAccount/kube-system/controller",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewClusterResolver("cluster://test", tt.opts, newTestCluster())
+			if err != nil {
+				t.Fatalf("NewClusterResolver() error = %v", err)
+			}
+			result, meta, err := r.Resolve(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got []string
+			for _, m := range result.Manifests {
+				metadata, _ := m.Content["metadata"].(map[string]interface{})
+				id := m.Content["kind"].(string) + "/"
+				if ns, _ := metadata["namespace"].(string); ns != "" {
+					id += ns + "/"
+				}
+				got = append(got, id+metadata["name"].(string))
+				if _, ok := metadata["resourceVersion"]; ok {
+					t.Errorf("%s keeps its resourceVersion", id)
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("manifests = %v, want %v", got, tt.want)
+			}
+
+			if meta.Type != SourceTypeCluster || meta.Name != "test" || meta.Path != "cluster://test" {
+				t.Errorf("metadata = %v/%s/%s", meta.Type, meta.Name, meta.Path)
+			}
+			cluster, _ := meta.Extra["cluster"].(map[string]interface{})
+			if counts, _ := cluster["objects"].(map[string]int); counts["ClusterRole"] != 1 {
+				t.Errorf("object counts = %v", cluster["objects"])
+			}
+		})
+	}
+}
+
+func TestClusterResolver_Snapshot(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "snapshot.yaml")
+	r, err := NewClusterResolver("cluster://test", &Options{SnapshotPath: snapshot}, newTestCluster())
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, meta, err := r.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if cluster, _ := meta.Extra["cluster"].(map[string]interface{}); cluster["snapshot"] != snapshot {
+		t.Errorf("snapshot metadata = %v", meta.Extra["cluster"])
+	}
+
+	content, err := os.ReadFile(snapshot)
+	if err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	if info, err := os.Stat(snapshot); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("snapshot mode = %v, want 0600", info.Mode().Perm())
+	}
+	for _, field := range []string{"managedFields", "uid:", "status:"} {
+		if strings.Contains(string(content), field) {
+			t.Errorf("snapshot contains %s:\n%s", field, content)
+		}
+	}
+
+	// The snapshot is analyzed offline like the live cluster
+	offline, _, err := NewLocalYAMLResolver(snapshot, DefaultOptions()).Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("resolving the snapshot failed: %v", err)
+	}
+	if offline.Version != live.Version || len(offline.Manifests) != len(live.Manifests) {
+		t.Errorf("snapshot has version %s and %d manifests, live cluster %s and %d",
+			offline.Version, len(offline.Manifests), live.Version, len(live.Manifests))
+	}
+}
+
+func TestNewClusterResolver_Kubeconfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	content := `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+  - name: dev
+    cluster:
+      server: https://dev.example.com:6443
+  - name: prod
+    cluster:
+      server: https://prod.example.com:6443
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+      user: admin
+  - name: prod
+    context:
+      cluster: prod
+      user: admin
+users:
+  - name: admin
+    user:
+      token: secret
+`
+	if err := os.WriteFile(kubeconfig, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source      string
+		wantContext string
+		wantServer  string
+		wantErr     bool
+	}{
+		{source: "cluster://", wantContext: "dev", wantServer: "https://dev.example.com:6443"},
+		{source: "cluster://prod", wantContext: "prod", wantServer: "https://prod.example.com:6443"},
+		{source: "cluster://missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		r, err := ResolverFactory(tt.source, &Options{Kubeconfig: kubeconfig})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ResolverFactory(%s) error = %v, wantErr %v", tt.source, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		cluster, ok := r.(*ClusterResolver)
+		if !ok {
+			t.Fatalf("ResolverFactory(%s) = %T, want *ClusterResolver", tt.source, r)
+		}
+		if cluster.context != tt.wantContext || cluster.server != tt.wantServer {
+			t.Errorf("ResolverFactory(%s) context %s server %s, want %s %s", tt.source, cluster.context, cluster.server, tt.wantContext, tt.wantServer)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+	"helm.sh/helm/v3/pkg/chart"
+	"sigs.k8s.io/yaml"
+)
+
+// fileRepository is the repository prefix of dependencies stored next to the chart
+const fileRepository = "file://"
+
+// vendorChartDependencies adds the dependencies declared in the Chart.yaml of files that are
+// not vendored under charts/. Dependencies from file:// repositories are read relative to
+// chartDir and must stay inside it, other dependencies are taken from the packaged charts in
+// opts.DependencyDir. Dependencies that cannot be found are left out, the helm renderer reports
+// them as missing.
+func vendorChartDependencies(files map[string][]byte, chartDir string, opts *Options) error {
+	v := &dependencyVendor{
+		root:      chartDir,
+		opts:      opts,
+		visited:   map[string]bool{},
+		remaining: opts.maxArchiveSize(),
+	}
+	return v.vendorDependencies(files, chartDir)
+}
+
+// dependencyVendor vendors the dependencies of a chart
+type dependencyVendor struct {
+	// root is the directory file:// dependencies must stay inside
+	root string
+	opts *Options
+	// visited holds the file:// directories being vendored
+	visited map[string]bool
+	// remaining is the number of bytes that can still be read from file:// dependencies
+	remaining int64
+}
+
+// vendorDependencies vendors the dependencies of the chart files read from chartDir
+func (v *dependencyVendor) vendorDependencies(files map[string][]byte, chartDir string) error {
+	content, ok := files["Chart.yaml"]
+	if !ok {
+		return nil
+	}
+	var metadata chart.Metadata
+	if err := yaml.Unmarshal(content, &metadata); err != nil {
+		return fmt.Errorf("failed to parse Chart.yaml: %w", err)
+	}
+
+	for _, dep := range metadata.Dependencies {
+		if dep == nil || dep.Name == "" || isVendored(files, dep.Name) {
+			continue
+		}
+
+		if strings.HasPrefix(dep.Repository, fileRepository) {
+			if chartDir == "" {
+				continue
+			}
+			depDir := strings.TrimPrefix(dep.Repository, fileRepository)
+			if filepath.IsAbs(depDir) {
+				return fmt.Errorf("dependency %s has an absolute path %s", dep.Name, depDir)
+			}
+			if err := v.vendorDirectory(files, filepath.Join(chartDir, depDir), dep.Name); err != nil {
+				return fmt.Errorf("failed to load dependency %s: %w", dep.Name, err)
+			}
+			continue
+		}
+
+		if v.opts == nil || v.opts.DependencyDir == "" {
+			continue
+		}
+		archive, version, err := renderer.FindPackagedChart(v.opts.DependencyDir, dep.Name, dep.Version)
+		if err != nil {
+			return fmt.Errorf("failed to load dependency %s: %w", dep.Name, err)
+		}
+		if archive == nil {
+			continue
+		}
+		if err := checkChartArchive(archive, v.opts.maxArchiveSize()); err != nil {
+			return fmt.Errorf("invalid archive of dependency %s: %w", dep.Name, err)
+		}
+		files[fmt.Sprintf("charts/%s-%s.tgz", dep.Name, version)] = archive
+	}
+	return nil
+}
+
+// isVendored reports whether the dependency name is unpacked or packaged under charts/
+func isVendored(files map[string][]byte, name string) bool {
+	if _, ok := files[path.Join("charts", name, "Chart.yaml")]; ok {
+		return true
+	}
+	for file := range files {
+		if path.Dir(file) == "charts" && strings.HasPrefix(path.Base(file), name+"-") && strings.HasSuffix(file, ".tgz") {
+			return true
+		}
+	}
+	return false
+}
+
+// vendorDirectory adds the chart in dir and its own dependencies to files under charts/name.
+// Only regular files are read, up to the remaining bytes of the vendor.
+func (v *dependencyVendor) vendorDirectory(files map[string][]byte, dir, name string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.Abs(v.root)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(root, abs); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside of the chart directory", dir)
+	}
+	if v.visited[abs] {
+		return fmt.Errorf("dependency cycle through %s", dir)
+	}
+	v.visited[abs] = true
+	defer delete(v.visited, abs)
+
+	subchart := make(map[string][]byte)
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if info.Size() > v.remaining {
+			return fmt.Errorf("dependencies are larger than the maximum size of %d bytes", v.opts.maxArchiveSize())
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		v.remaining -= int64(len(content))
+		if v.remaining < 0 {
+			return fmt.Errorf("dependencies are larger than the maximum size of %d bytes", v.opts.maxArchiveSize())
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		subchart[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := v.vendorDependencies(subchart, dir); err != nil {
+		return err
+	}
+
+	for rel, content := range subchart {
+		files[path.Join("charts", name, rel)] = content
+	}
+	return nil
+}
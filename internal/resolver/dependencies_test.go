@@ -0,0 +1,198 @@
+package resolver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// packagedChart returns a chart archive with a single ClusterRole named name-version
+func packagedChart(t *testing.T, name, version string) []byte {
+	t.Helper()
+	return buildArchive(t,
+		tarEntry{name: name + "/Chart.yaml", content: "apiVersion: v2\nname: " + name + "\nversion: " + version + "\n"},
+		tarEntry{name: name + "/templates/role.yaml", content: "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: " + name + "-" + version + "\n"},
+	)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFolderResolver_ChartDependencies(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"app/Chart.yaml": `apiVersion: v2
+name: app
+version: 0.1.0
+dependencies:
+  - name: common
+    version: 0.1.0
+    repository: file://deps/common
+  - name: operator
+    version: 1.x
+    repository: https://charts.example.com
+  - name: vendored
+    version: 0.1.0
+    repository: https://charts.example.com
+`,
+		"app/templates/role.yaml":                 "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: app\n",
+		"app/charts/vendored/Chart.yaml":          "apiVersion: v2\nname: vendored\nversion: 0.1.0\n",
+		"app/charts/vendored/templates/role.yaml": "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: vendored\n",
+		// A file:// dependency with its own file:// dependency
+		"app/deps/common/Chart.yaml": `apiVersion: v2
+name: common
+version: 0.1.0
+dependencies:
+  - name: base
+    version: 0.1.0
+    repository: file://../base
+`,
+		"app/deps/common/templates/role.yaml": "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: common\n",
+		"app/deps/base/Chart.yaml":            "apiVersion: v2\nname: base\nversion: 0.1.0\n",
+		"app/deps/base/templates/role.yaml":   "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: base\n",
+	})
+
+	repoDir := filepath.Join(root, "repo")
+	if err := os.Mkdir(repoDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, archive := range map[string][]byte{
+		"operator-1.0.0.tgz":      packagedChart(t, "operator", "1.0.0"),
+		"operator-1.1.0.tgz":      packagedChart(t, "operator", "1.1.0"),
+		"operator-2.0.0.tgz":      packagedChart(t, "operator", "2.0.0"),
+		"operator-crds-1.5.0.tgz": packagedChart(t, "operator-crds", "1.5.0"),
+	} {
+		if err := os.WriteFile(filepath.Join(repoDir, name), archive, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		opts         *Options
+		wantRoles    []string
+		wantWarnings int
+	}{
+		{
+			name:         "without dependency directory",
+			opts:         &Options{},
+			wantRoles:    []string{"app", "base (common.base)", "common (common)", "vendored (vendored)"},
+			wantWarnings: 1,
+		},
+		{
+			name:      "with dependency directory",
+			opts:      &Options{DependencyDir: repoDir},
+			wantRoles: []string{"app", "base (common.base)", "common (common)", "operator-1.1.0 (operator)", "vendored (vendored)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, err := NewFolderResolver(filepath.Join(root, "app"), tt.opts).Resolve(context.Background())
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			var roles []string
+			for _, m := range result.Manifests {
+				metadata, _ := m.Content["metadata"].(map[string]interface{})
+				role, _ := metadata["name"].(string)
+				if subchart, _ := m.Metadata["subchart"].(string); subchart != "" {
+					role += " (" + subchart + ")"
+				}
+				roles = append(roles, role)
+			}
+			sort.Strings(roles)
+			if strings.Join(roles, ",") != strings.Join(tt.wantRoles, ",") {
+				t.Errorf("roles = %v, want %v", roles, tt.wantRoles)
+			}
+			if len(result.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %v, want %d", result.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestVendorChartDependencies_Cycle(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a/Chart.yaml": "apiVersion: v2\nname: a\nversion: 0.1.0\ndependencies:\n  - name: b\n    repository: file://../b\n",
+		"b/Chart.yaml": "apiVersion: v2\nname: b\nversion: 0.1.0\ndependencies:\n  - name: a\n    repository: file://../a\n",
+	})
+	files := map[string][]byte{"Chart.yaml": []byte("apiVersion: v2\nname: app\nversion: 0.1.0\ndependencies:\n  - name: a\n    repository: file://a\n")}
+	err := vendorChartDependencies(files, root, nil)
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("vendorChartDependencies() error = %v, want a dependency cycle", err)
+	}
+}
+
+func TestVendorChartDependencies_Bounds(t *testing.T) {
+	root := t.TempDir()
+	chartDir := filepath.Join(root, "app")
+	writeFiles(t, root, map[string]string{
+		"app/Chart.yaml":              "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+		"app/deps/lib/Chart.yaml":     "apiVersion: v2\nname: lib\nversion: 0.1.0\n",
+		"app/deps/lib/values.yaml":    strings.Repeat("#", 1024),
+		"app/deps/linked/Chart.yaml":  "apiVersion: v2\nname: linked\nversion: 0.1.0\n",
+		"secret/Chart.yaml":           "apiVersion: v2\nname: secret\nversion: 0.1.0\n",
+		"secret/templates/token.yaml": "token",
+	})
+	if err := os.Symlink(filepath.Join(root, "secret", "templates", "token.yaml"), filepath.Join(chartDir, "deps", "linked", "token.yaml")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	chartYAML := func(repository string) map[string][]byte {
+		return map[string][]byte{"Chart.yaml": []byte("apiVersion: v2\nname: app\nversion: 0.1.0\ndependencies:\n  - name: dep\n    repository: " + repository + "\n")}
+	}
+
+	tests := []struct {
+		name       string
+		repository string
+		opts       *Options
+		wantErr    string
+		wantFiles  []string
+	}{
+		{name: "inside the chart", repository: "file://deps/lib", wantFiles: []string{"Chart.yaml", "charts/dep/Chart.yaml", "charts/dep/values.yaml"}},
+		{name: "absolute path", repository: "file://" + filepath.Join(root, "secret"), wantErr: "absolute path"},
+		{name: "outside the chart", repository: "file://../secret", wantErr: "outside of the chart directory"},
+		{name: "larger than the maximum size", repository: "file://deps/lib", opts: &Options{MaxArchiveSize: 512}, wantErr: "maximum size"},
+		{name: "symlinked file", repository: "file://deps/linked", wantFiles: []string{"Chart.yaml", "charts/dep/Chart.yaml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := chartYAML(tt.repository)
+			err := vendorChartDependencies(files, chartDir, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("vendorChartDependencies() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("vendorChartDependencies() error = %v", err)
+			}
+			var names []string
+			for name := range files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.wantFiles, ",") {
+				t.Errorf("files = %v, want %v", names, tt.wantFiles)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/alevsk/rbac-scope/internal/renderer"
+	"sigs.k8s.io/kustomize/api/konfig"
 )
 
 // RendererType represents the type of renderer to use
@@ -20,6 +21,20 @@ const (
 	RendererTypeKustomize
 )
 
+// String returns the name of a RendererType
+func (t RendererType) String() string {
+	switch t {
+	case RendererTypeYAML:
+		return "yaml"
+	case RendererTypeHelm:
+		return "helm"
+	case RendererTypeKustomize:
+		return "kustomize"
+	default:
+		return "unknown"
+	}
+}
+
 // rendererDefinition defines a renderer type and its identifiers
 type rendererDefinition struct {
 	Type        RendererType
@@ -36,7 +51,7 @@ func DetectRendererType(dirPath string) (RendererType, error) {
 		},
 		{
 			Type:        RendererTypeKustomize,
-			Identifiers: []string{"kustomization.yaml", "kustomization.yml"},
+			Identifiers: konfig.RecognizedKustomizationFileNames(),
 		},
 	}
 
@@ -71,10 +86,22 @@ func GetRendererForType(typ RendererType, opts *Options) (renderer.Renderer, err
 		rOpts := renderer.DefaultOptions()
 		if opts != nil {
 			rOpts.Values = opts.Values
+			rOpts.ReleaseName = opts.ReleaseName
+			rOpts.Namespace = opts.Namespace
+			rOpts.KubeVersion = opts.KubeVersion
+			rOpts.APIVersions = opts.APIVersions
 		}
 		return renderer.NewHelmRenderer(rOpts), nil
 	case RendererTypeKustomize:
-		return renderer.NewKustomizeRenderer(nil), nil
+		rOpts := renderer.DefaultOptions()
+		if opts != nil {
+			rOpts.KustomizeRoot = opts.KustomizeRoot
+			rOpts.KustomizeHelm = opts.KustomizeHelm
+			rOpts.ChartCache = opts.DependencyDir
+			rOpts.KubeVersion = opts.KubeVersion
+			rOpts.APIVersions = opts.APIVersions
+		}
+		return renderer.NewKustomizeRenderer(rOpts), nil
 	default:
 		return nil, fmt.Errorf("unknown renderer type: %v", typ)
 	}
@@ -35,6 +35,11 @@ func TestDetectRendererType(t *testing.T) {
 			dirPath:  filepath.Join("testdata", "fixtures", "kustomize_yml"),
 			wantType: RendererTypeKustomize,
 		},
+		{
+			name:     "detect kustomize with a Kustomization file",
+			dirPath:  filepath.Join("testdata", "fixtures", "kustomize_kind"),
+			wantType: RendererTypeKustomize,
+		},
 		{
 			name:     "fallback to yaml",
 			dirPath:  filepath.Join("testdata", "fixtures", "yaml"),
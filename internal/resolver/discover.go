@@ -0,0 +1,156 @@
+package resolver
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/yaml"
+)
+
+// RenderMode selects how a directory source is rendered
+type RenderMode string
+
+const (
+	// RenderModeAuto detects a Chart.yaml or kustomization.yaml at the top level of the directory
+	RenderModeAuto RenderMode = "auto"
+	// RenderModeDiscover renders every Helm chart and Kustomize overlay found under the directory
+	RenderModeDiscover RenderMode = "discover"
+	// RenderModeHelm renders the directory as a Helm chart
+	RenderModeHelm RenderMode = "helm"
+	// RenderModeKustomize renders the directory as a Kustomize overlay
+	RenderModeKustomize RenderMode = "kustomize"
+	// RenderModeYAML concatenates the YAML and JSON files of the directory
+	RenderModeYAML RenderMode = "yaml"
+)
+
+// ParseRenderMode parses a render mode, an empty mode is RenderModeAuto
+func ParseRenderMode(mode string) (RenderMode, error) {
+	switch m := RenderMode(strings.ToLower(mode)); m {
+	case "":
+		return RenderModeAuto, nil
+	case RenderModeAuto, RenderModeDiscover, RenderModeHelm, RenderModeKustomize, RenderModeYAML:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid render mode %q: must be one of auto, discover, helm, kustomize or yaml", mode)
+	}
+}
+
+// rendererType returns the renderer forced by the mode, ok is false when the mode detects it
+func (m RenderMode) rendererType() (RendererType, bool) {
+	switch m {
+	case RenderModeHelm:
+		return RendererTypeHelm, true
+	case RenderModeKustomize:
+		return RendererTypeKustomize, true
+	case RenderModeYAML:
+		return RendererTypeYAML, true
+	default:
+		return RendererTypeYAML, false
+	}
+}
+
+// Artifact is a Helm chart or Kustomize overlay found under a directory
+type Artifact struct {
+	// Path is the directory of the chart or overlay
+	Path string
+	// RendererType is the renderer of the artifact
+	RendererType RendererType
+}
+
+// DiscoverArtifacts returns the Helm charts and Kustomize overlays under dir, sorted by path.
+// Subcharts and the files of an overlay are part of their artifact and are not searched, and
+// kustomizations used as a base or component by another kustomization are not reported on
+// their own. Hidden directories such as .git are skipped.
+func DiscoverArtifacts(dir string) ([]Artifact, error) {
+	var artifacts []Artifact
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		rendererType, err := DetectRendererType(path)
+		if err != nil {
+			return err
+		}
+		if rendererType == RendererTypeYAML {
+			return nil
+		}
+		artifacts = append(artifacts, Artifact{Path: path, RendererType: rendererType})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover charts and overlays: %w", err)
+	}
+
+	// Drop the kustomizations other kustomizations are built from
+	referenced := make(map[string]bool)
+	for _, artifact := range artifacts {
+		if artifact.RendererType != RendererTypeKustomize {
+			continue
+		}
+		for _, base := range kustomizationBases(artifact.Path) {
+			referenced[base] = true
+		}
+	}
+	var roots []Artifact
+	for _, artifact := range artifacts {
+		abs, err := filepath.Abs(artifact.Path)
+		if err == nil && artifact.RendererType == RendererTypeKustomize && referenced[abs] {
+			continue
+		}
+		roots = append(roots, artifact)
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Path < roots[j].Path })
+	return roots, nil
+}
+
+// kustomizationBases returns the absolute paths of the local directories the kustomization in
+// dir lists as resources, bases or components
+func kustomizationBases(dir string) []string {
+	var content []byte
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			content = data
+			break
+		}
+	}
+	var kustomization struct {
+		Resources  []string `json:"resources"`
+		Bases      []string `json:"bases"`
+		Components []string `json:"components"`
+	}
+	if content == nil || yaml.Unmarshal(content, &kustomization) != nil {
+		return nil
+	}
+
+	var bases []string
+	for _, entries := range [][]string{kustomization.Resources, kustomization.Bases, kustomization.Components} {
+		for _, entry := range entries {
+			if strings.Contains(entry, "://") {
+				continue
+			}
+			path := entry
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			if info, err := os.Stat(path); err != nil || !info.IsDir() {
+				continue
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				bases = append(bases, abs)
+			}
+		}
+	}
+	return bases
+}
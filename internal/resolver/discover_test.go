@@ -0,0 +1,197 @@
+package resolver
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+)
+
+// newTestRepository creates a repository with a Helm chart under deploy/helm, a kubebuilder
+// style kustomization under config/ and plain YAML samples. It returns the repository root.
+func newTestRepository(t *testing.T) string {
+	t.Helper()
+	role := func(name string) string {
+		return "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: " + name + "\nrules:\n  - apiGroups: [\"\"]\n    resources: [\"pods\"]\n    verbs: [\"get\"]\n"
+	}
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"deploy/helm/Chart.yaml":                    "apiVersion: v2\nname: operator\nversion: 1.0.0\n",
+		"deploy/helm/templates/role.yaml":           role("helm-role"),
+		"deploy/helm/charts/sub/Chart.yaml":         "apiVersion: v2\nname: sub\nversion: 0.1.0\n",
+		"deploy/helm/charts/sub/templates/sub.yaml": role("sub-role"),
+		"config/default/kustomization.yaml":         "resources:\n  - ../rbac\n  - https://example.com/remote.yaml\nnamePrefix: op-\n",
+		"config/rbac/kustomization.yaml":            "resources:\n  - role.yaml\n",
+		"config/rbac/role.yaml":                     role("manager-role"),
+		"config/samples/sample.yaml":                role("sample-role"),
+		".git/Chart.yaml":                           "apiVersion: v2\nname: hidden\nversion: 0.1.0\n",
+	})
+	return root
+}
+
+func TestDiscoverArtifacts(t *testing.T) {
+	root := newTestRepository(t)
+
+	tests := []struct {
+		name string
+		dir  string
+		want []string
+	}{
+		{name: "repository", dir: root, want: []string{"config/default:kustomize", "deploy/helm:helm"}},
+		{name: "chart", dir: filepath.Join(root, "deploy", "helm"), want: []string{".:helm"}},
+		{name: "base on its own", dir: filepath.Join(root, "config", "rbac"), want: []string{".:kustomize"}},
+		{name: "plain YAML", dir: filepath.Join(root, "config", "samples")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			artifacts, err := DiscoverArtifacts(tt.dir)
+			if err != nil {
+				t.Fatalf("DiscoverArtifacts() error = %v", err)
+			}
+			var got []string
+			for _, artifact := range artifacts {
+				rel, _ := filepath.Rel(tt.dir, artifact.Path)
+				got = append(got, filepath.ToSlash(rel)+":"+artifact.RendererType.String())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("DiscoverArtifacts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFolderResolver_RenderMode(t *testing.T) {
+	root := newTestRepository(t)
+
+	tests := []struct {
+		name        string
+		dir         string
+		mode        RenderMode
+		wantRoles   []string
+		wantWarning string
+		wantErr     bool
+	}{
+		{
+			name:        "auto reads nested artifacts as YAML",
+			dir:         root,
+			mode:        RenderModeAuto,
+			wantRoles:   []string{"helm-role", "manager-role", "sample-role", "sub-role"},
+			wantWarning: "use the discover render mode",
+		},
+		{
+			name:      "yaml",
+			dir:       root,
+			mode:      RenderModeYAML,
+			wantRoles: []string{"helm-role", "manager-role", "sample-role", "sub-role"},
+		},
+		{
+			name:      "helm",
+			dir:       filepath.Join(root, "deploy", "helm"),
+			mode:      RenderModeHelm,
+			wantRoles: []string{"helm-role", "sub-role"},
+		},
+		{
+			name:    "helm without a chart",
+			dir:     filepath.Join(root, "config", "samples"),
+			mode:    RenderModeHelm,
+			wantErr: true,
+		},
+		{
+			name:      "discover plain YAML",
+			dir:       filepath.Join(root, "config", "samples"),
+			mode:      RenderModeDiscover,
+			wantRoles: []string{"sample-role"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, err := NewFolderResolver(tt.dir, &Options{RenderMode: tt.mode}).Resolve(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := manifestNames(result.Manifests); strings.Join(got, ",") != strings.Join(tt.wantRoles, ",") {
+				t.Errorf("roles = %v, want %v", got, tt.wantRoles)
+			}
+			warned := strings.Contains(strings.Join(result.Warnings, "\n"), "use the discover render mode")
+			if warned != (tt.wantWarning != "") {
+				t.Errorf("warnings = %v, want discover warning %v", result.Warnings, tt.wantWarning != "")
+			}
+		})
+	}
+}
+
+func TestFolderResolver_Discover(t *testing.T) {
+	root := newTestRepository(t)
+	// Remote resources are not fetched by the test
+	writeFiles(t, root, map[string]string{"config/default/kustomization.yaml": "resources:\n  - ../rbac\nnamePrefix: op-\n"})
+
+	result, meta, err := NewFolderResolver(root, &Options{RenderMode: RenderModeDiscover}).Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	sources := make(map[string]string)
+	for _, m := range result.Manifests {
+		metadata, _ := m.Content["metadata"].(map[string]interface{})
+		source, _ := m.Metadata["source"].(string)
+		rel, _ := filepath.Rel(root, source)
+		sources[metadata["name"].(string)] = filepath.ToSlash(rel)
+	}
+	want := map[string]string{"op-manager-role": "config/default", "helm-role": "deploy/helm", "sub-role": "deploy/helm"}
+	if len(sources) != len(want) {
+		t.Fatalf("manifest sources = %v, want %v", sources, want)
+	}
+	for name, source := range want {
+		if sources[name] != source {
+			t.Errorf("source of %s = %q, want %q", name, sources[name], source)
+		}
+	}
+
+	artifacts, _ := meta.Extra["artifacts"].([]map[string]interface{})
+	if len(artifacts) != 2 || artifacts[0]["renderer"] != "kustomize" || artifacts[1]["name"] != "operator" || artifacts[1]["version"] != "1.0.0" {
+		t.Errorf("artifacts = %v", meta.Extra["artifacts"])
+	}
+	if !strings.HasPrefix(meta.Version, "sha512:") {
+		t.Errorf("version = %q, want a digest of the artifact versions", meta.Version)
+	}
+}
+
+func TestParseRenderMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    RenderMode
+		wantErr bool
+	}{
+		{mode: "", want: RenderModeAuto},
+		{mode: "discover", want: RenderModeDiscover},
+		{mode: "Kustomize", want: RenderModeKustomize},
+		{mode: "jsonnet", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRenderMode(tt.mode)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRenderMode(%q) = %q, %v, want %q, wantErr %v", tt.mode, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// manifestNames returns the sorted metadata names of manifests, Chart.yaml and kustomization
+// files read as plain YAML have no name and are left out
+func manifestNames(manifests []*renderer.Manifest) []string {
+	var names []string
+	for _, m := range manifests {
+		metadata, _ := m.Content["metadata"].(map[string]interface{})
+		if name, _ := metadata["name"].(string); name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -2,6 +2,8 @@ package resolver
 
 import (
 	"context"
+	"crypto/sha512"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -54,10 +56,21 @@ func (r *FolderResolver) Resolve(ctx context.Context) (*renderer.Result, *Resolv
 		return nil, nil, fmt.Errorf("not a directory: %s", r.source)
 	}
 
-	// Detect the renderer type
-	rendererType, err := DetectRendererType(r.source)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to detect renderer type: %w", err)
+	mode := RenderModeAuto
+	if r.opts != nil && r.opts.RenderMode != "" {
+		mode = r.opts.RenderMode
+	}
+	if mode == RenderModeDiscover {
+		return r.resolveArtifacts(ctx)
+	}
+
+	// Detect the renderer type unless the render mode selects it
+	rendererType, forced := mode.rendererType()
+	if !forced {
+		rendererType, err = DetectRendererType(r.source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to detect renderer type: %w", err)
+		}
 	}
 
 	// Get the appropriate renderer and metadata
@@ -76,8 +89,20 @@ func (r *FolderResolver) Resolve(ctx context.Context) (*renderer.Result, *Resolv
 		Extra:        make(map[string]interface{}),
 	}
 
-	// If it's a Helm chart or Kustomize directory, read all files and pass them to the appropriate renderer
-	if rendererType == RendererTypeHelm || rendererType == RendererTypeKustomize {
+	// Kustomize overlays are built on disk, their bases may be outside the directory
+	if rendererType == RendererTypeKustomize {
+		result, err := renderer.Render(ctx, []byte(r.source))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to render: %w", err)
+		}
+		meta.Name = result.Name
+		meta.Version = result.Version
+		meta.Extra = result.Extra
+		return result, meta, nil
+	}
+
+	// If it's a Helm chart, read all files and pass them to the renderer
+	if rendererType == RendererTypeHelm {
 		// Read the entire directory
 		files := make(map[string][]byte)
 		err := filepath.Walk(r.source, func(path string, info os.FileInfo, err error) error {
@@ -101,6 +126,11 @@ func (r *FolderResolver) Resolve(ctx context.Context) (*renderer.Result, *Resolv
 			return nil, nil, fmt.Errorf("failed to walk directory: %w", err)
 		}
 
+		// Add the chart dependencies that are not vendored under charts/
+		if err := vendorChartDependencies(files, r.source, r.opts); err != nil {
+			return nil, nil, err
+		}
+
 		// Add all files to the renderer
 		for name, content := range files {
 			if err := renderer.AddFile(name, content); err != nil {
@@ -114,6 +144,15 @@ func (r *FolderResolver) Resolve(ctx context.Context) (*renderer.Result, *Resolv
 			return nil, nil, fmt.Errorf("failed to render: %w", err)
 		}
 
+		// Point the templates of the chart to their file in the directory
+		for _, m := range result.Manifests {
+			if template, ok := m.Metadata["template"].(string); ok {
+				if parts := strings.SplitN(filepath.ToSlash(template), "/", 2); len(parts) == 2 {
+					m.Metadata["file"] = filepath.Join(r.source, filepath.FromSlash(parts[1]))
+				}
+			}
+		}
+
 		// Set Artifact Name and Version
 		meta.Name = result.Name
 		meta.Version = result.Version
@@ -236,11 +275,20 @@ func (r *FolderResolver) Resolve(ctx context.Context) (*renderer.Result, *Resolv
 	}
 
 	// Render the content to ensure it's valid RBAC
-	result, err := yamlRenderer.Render(ctx, content)
+	result, err := renderYAMLFiles(ctx, yamlRenderer, files, content)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// Charts and overlays below the directory were rendered as plain YAML
+	if !forced {
+		if artifacts, err := DiscoverArtifacts(r.source); err == nil && len(artifacts) > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf(
+				"%d Helm charts or Kustomize overlays found below %s were read as plain YAML, use the discover render mode to render them",
+				len(artifacts), r.source))
+		}
+	}
+
 	// Create metadata
 	metadata := &ResolverMetadata{
 		Name:    result.Name,
@@ -258,6 +306,31 @@ func (r *FolderResolver) Resolve(ctx context.Context) (*renderer.Result, *Resolv
 	return result, metadata, nil
 }
 
+// renderYAMLFiles renders each file on its own, so the manifests record the file, document and
+// line they come from. The version is the hash of the combined content of the files.
+func renderYAMLFiles(ctx context.Context, yamlRenderer renderer.Renderer, files []yamlFile, content []byte) (*renderer.Result, error) {
+	result := &renderer.Result{
+		Version:   fmt.Sprintf("sha512:%x", sha512.Sum512(content)),
+		Manifests: make([]*renderer.Manifest, 0),
+	}
+	for _, file := range files {
+		rendered, err := yamlRenderer.Render(ctx, file.contents)
+		if err != nil {
+			// Files with only comments or empty documents have no manifests
+			if errors.Is(err, renderer.ErrInvalidFormat) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to render %s: %w", file.path, err)
+		}
+		setManifestFile(rendered.Manifests, file.path)
+		result.Manifests = append(result.Manifests, rendered.Manifests...)
+		for _, warning := range rendered.Warnings {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", file.path, warning))
+		}
+	}
+	return result, nil
+}
+
 // buildWalkFunc creates a WalkDirFunc that finds YAML files and validates them
 func buildWalkFunc(ctx context.Context, filesChan chan<- yamlFile, errorsChan chan<- error) fs.WalkDirFunc {
 	return func(path string, d fs.DirEntry, err error) error {
@@ -278,8 +351,7 @@ func buildWalkFunc(ctx context.Context, filesChan chan<- yamlFile, errorsChan ch
 		}
 
 		// Check file extension
-		ext := strings.ToLower(filepath.Ext(path))
-		if ext != ".yaml" && ext != ".yml" {
+		if !isManifestFile(path) {
 			return nil
 		}
 
@@ -307,3 +379,88 @@ func buildWalkFunc(ctx context.Context, filesChan chan<- yamlFile, errorsChan ch
 		return nil
 	}
 }
+
+// resolveArtifacts renders every Helm chart and Kustomize overlay under the directory on its
+// own. The manifests of several artifacts are combined, each records its artifact under the
+// source metadata key, and the artifacts are listed under the artifacts extra metadata.
+// A directory without charts or overlays is read as plain YAML.
+func (r *FolderResolver) resolveArtifacts(ctx context.Context) (*renderer.Result, *ResolverMetadata, error) {
+	artifacts, err := DiscoverArtifacts(r.source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := DefaultOptions()
+	if r.opts != nil {
+		copied := *r.opts
+		opts = &copied
+	}
+	if len(artifacts) == 0 {
+		opts.RenderMode = RenderModeYAML
+		return NewFolderResolver(r.source, opts).Resolve(ctx)
+	}
+
+	result := &renderer.Result{
+		Name:      r.source,
+		Manifests: make([]*renderer.Manifest, 0),
+		Warnings:  make([]string, 0),
+	}
+	var list []map[string]interface{}
+	hash := sha512.New()
+	for _, artifact := range artifacts {
+		opts.RenderMode = RenderMode(artifact.RendererType.String())
+		rendered, meta, err := NewFolderResolver(artifact.Path, opts).Resolve(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to render %s: %w", artifact.Path, err)
+		}
+		if len(artifacts) == 1 {
+			if meta.Extra == nil {
+				meta.Extra = make(map[string]interface{})
+			}
+			meta.Extra["artifacts"] = []map[string]interface{}{artifactMetadata(artifact, meta, len(rendered.Manifests))}
+			return rendered, meta, nil
+		}
+
+		for _, m := range rendered.Manifests {
+			metadata := make(map[string]interface{}, len(m.Metadata)+1)
+			for k, v := range m.Metadata {
+				metadata[k] = v
+			}
+			metadata["source"] = artifact.Path
+			copied := *m
+			copied.Metadata = metadata
+			result.Manifests = append(result.Manifests, &copied)
+		}
+		for _, warning := range rendered.Warnings {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", artifact.Path, warning))
+		}
+		list = append(list, artifactMetadata(artifact, meta, len(rendered.Manifests)))
+		fmt.Fprintf(hash, "%s@%s\n", artifact.Path, meta.Version)
+	}
+	result.Version = fmt.Sprintf("sha512:%x", hash.Sum(nil))
+
+	meta := &ResolverMetadata{
+		Name:    result.Name,
+		Version: result.Version,
+		Type:    SourceTypeFolder,
+		Path:    r.source,
+		ModTime: time.Now(),
+		Extra: map[string]interface{}{
+			"manifests": len(result.Manifests),
+			"warnings":  result.Warnings,
+			"artifacts": list,
+		},
+	}
+	return result, meta, nil
+}
+
+// artifactMetadata describes a rendered artifact in the artifacts extra metadata
+func artifactMetadata(artifact Artifact, meta *ResolverMetadata, manifests int) map[string]interface{} {
+	return map[string]interface{}{
+		"path":      artifact.Path,
+		"renderer":  artifact.RendererType.String(),
+		"name":      meta.Name,
+		"version":   meta.Version,
+		"manifests": manifests,
+	}
+}
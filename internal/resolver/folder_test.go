@@ -2,12 +2,15 @@ package resolver
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings" // Added import
 	"testing"
 
 	"github.com/alevsk/rbac-scope/internal/renderer" // Added import
+	"github.com/alevsk/rbac-scope/internal/types"
 )
 
 func TestFolderResolver_CanResolve(t *testing.T) {
@@ -93,6 +96,14 @@ func TestFolderResolver_Resolve(t *testing.T) {
 			wantType:       SourceTypeFolder,
 			wantRenderType: RendererTypeYAML,
 		},
+		{
+			name:           "directory with json files",
+			source:         "testdata/folder_json",
+			wantErr:        false,
+			wantFiles:      3, // Two Roles unwrapped from roles.json and the RoleBinding
+			wantType:       SourceTypeFolder,
+			wantRenderType: RendererTypeYAML,
+		},
 		{
 			name:           "empty directory (yaml default)",
 			source:         "testdata/folder_empty",
@@ -384,3 +395,89 @@ rules:
 		})
 	}
 }
+
+func TestFolderResolver_KustomizeBases(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"base/kustomization.yaml":          "resources:\n  - role.yaml\n",
+		"base/role.yaml":                   "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: reader\n",
+		"overlays/prod/kustomization.yaml": "resources:\n  - ../../base\nnamespace: prod\n",
+	})
+	overlay := filepath.Join(root, "overlays", "prod")
+
+	if _, _, err := NewFolderResolver(overlay, &Options{}).Resolve(context.Background()); err == nil {
+		t.Error("Resolve() reached a base outside the overlay without a kustomize root")
+	}
+
+	result, meta, err := NewFolderResolver(overlay, &Options{KustomizeRoot: root}).Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(result.Manifests) != 1 || result.Manifests[0].Name != "reader" {
+		t.Fatalf("manifests = %v, want the reader role of the base", result.Manifests)
+	}
+	metadata, _ := result.Manifests[0].Content["metadata"].(map[string]interface{})
+	if metadata["namespace"] != "prod" {
+		t.Errorf("namespace = %v, want prod", metadata["namespace"])
+	}
+	if meta.RendererType != RendererTypeKustomize || meta.Extra["kustomize"] == nil {
+		t.Errorf("metadata = %v %v", meta.RendererType, meta.Extra)
+	}
+}
+
+func TestFolderResolver_Provenance(t *testing.T) {
+	root := newTestRepository(t)
+	writeFiles(t, root, map[string]string{
+		"manifests/binding.yaml": "# operator binding\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRoleBinding\nmetadata:\n  name: operator\n",
+		"manifests/roles.yaml":   "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: operator\n---\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: operator\n",
+	})
+
+	tests := []struct {
+		name string
+		dir  string
+		want map[string]types.Provenance
+	}{
+		{
+			name: "yaml files",
+			dir:  filepath.Join(root, "manifests"),
+			want: map[string]types.Provenance{
+				"ClusterRoleBinding/operator": {File: filepath.Join(root, "manifests", "binding.yaml"), Document: 1, Line: 2},
+				"ServiceAccount/operator":     {File: filepath.Join(root, "manifests", "roles.yaml"), Document: 1, Line: 1},
+				"ClusterRole/operator":        {File: filepath.Join(root, "manifests", "roles.yaml"), Document: 2, Line: 6},
+			},
+		},
+		{
+			name: "helm chart",
+			dir:  filepath.Join(root, "deploy", "helm"),
+			want: map[string]types.Provenance{
+				"ClusterRole/helm-role": {File: filepath.Join(root, "deploy", "helm", "templates", "role.yaml"), Document: 1},
+				"ClusterRole/sub-role":  {File: filepath.Join(root, "deploy", "helm", "charts", "sub", "templates", "sub.yaml"), Document: 1},
+			},
+		},
+		{
+			name: "kustomize overlay",
+			dir:  filepath.Join(root, "config", "rbac"),
+			want: map[string]types.Provenance{
+				"ClusterRole/manager-role": {File: filepath.Join(root, "config", "rbac", "role.yaml"), Document: 1, Line: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, err := NewFolderResolver(tt.dir, DefaultOptions()).Resolve(context.Background())
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			got := make(map[string]types.Provenance)
+			for _, m := range result.Manifests {
+				if p := m.Provenance(); p != nil {
+					metadata, _ := m.Content["metadata"].(map[string]interface{})
+					got[fmt.Sprintf("%s/%s", m.Content["kind"], metadata["name"])] = *p
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("provenance = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
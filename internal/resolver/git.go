@@ -0,0 +1,203 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+)
+
+// gitPrefix is the prefix of git repository sources
+const gitPrefix = "git::"
+
+// GitResolver implements SourceResolver for git repositories, such as
+// git::https://github.com/org/repo.git//deploy/chart?ref=v1.2.3 or a local bare repository.
+// The repository is fetched at ref into a temporary directory which is resolved like a
+// local source, so Helm and Kustomize detection still apply.
+type GitResolver struct {
+	source string
+	repo   string // Repository URL or path
+	path   string // Path inside the repository
+	ref    string // Branch, tag or commit, the remote HEAD when empty
+	opts   *Options
+}
+
+// NewGitResolver creates a new GitResolver for a git:: source or a local bare repository
+func NewGitResolver(source string, opts *Options) (*GitResolver, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	repo, path, ref, err := parseGitSource(source)
+	if err != nil {
+		return nil, err
+	}
+	return &GitResolver{
+		source: source,
+		repo:   repo,
+		path:   path,
+		ref:    ref,
+		opts:   opts,
+	}, nil
+}
+
+// CanResolve checks if this resolver can handle the given source
+func (r *GitResolver) CanResolve(source string) bool {
+	return strings.HasPrefix(source, gitPrefix) || isBareRepository(source)
+}
+
+// Resolve fetches the repository at the configured ref and resolves the path inside it
+func (r *GitResolver) Resolve(ctx context.Context) (*renderer.Result, *ResolverMetadata, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, nil, fmt.Errorf("git is required to resolve %s: %w", r.source, err)
+	}
+
+	dir, err := os.MkdirTemp("", "rbac-scope-git-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	commit, err := r.checkout(ctx, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(r.path))
+	resolver, err := ResolverFactory(target, r.opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve %s in %s: %w", r.path, r.repo, err)
+	}
+	result, meta, err := resolver.Resolve(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Files of the checkout are reported relative to the repository, the checkout is removed
+	for _, m := range result.Manifests {
+		if file, ok := m.Metadata["file"].(string); ok {
+			if rel, err := filepath.Rel(dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+				m.Metadata["file"] = filepath.ToSlash(rel)
+			}
+		}
+	}
+
+	meta.Type = SourceTypeGit
+	meta.Path = r.source
+	if meta.Extra == nil {
+		meta.Extra = make(map[string]interface{})
+	}
+	meta.Extra["repository"] = r.repo
+	meta.Extra["ref"] = r.ref
+	meta.Extra["commit"] = commit
+	if r.path != "" {
+		meta.Extra["path"] = r.path
+	}
+	if meta.Name == "" || meta.Name == target {
+		meta.Name = r.source
+	}
+	return result, meta, nil
+}
+
+// checkout fetches the ref into dir and returns the checked out commit
+func (r *GitResolver) checkout(ctx context.Context, dir string) (string, error) {
+	ref := r.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	if _, err := runGit(ctx, dir, "init", "--quiet"); err != nil {
+		return "", err
+	}
+	// A shallow fetch of the ref is enough for branches and tags, and for commits on
+	// servers that allow fetching them directly
+	if _, err := runGit(ctx, dir, "fetch", "--quiet", "--depth", "1", r.repo, ref); err == nil {
+		if _, err := runGit(ctx, dir, "checkout", "--quiet", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	} else {
+		// Fall back to fetching every branch and tag, then checking out the ref
+		if _, err := runGit(ctx, dir, "fetch", "--quiet", "--tags", r.repo, "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+			return "", fmt.Errorf("failed to fetch %s: %w", r.repo, err)
+		}
+		if _, err := runGit(ctx, dir, "checkout", "--quiet", ref+"^{commit}"); err != nil {
+			return "", fmt.Errorf("ref %s not found in %s: %w", ref, r.repo, err)
+		}
+	}
+
+	commit, err := runGit(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// runGit runs a git command in dir and returns its trimmed output
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never prompt for credentials, the analysis runs unattended
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// parseGitSource splits a git source into the repository, the path inside it and the ref,
+// the path follows a double slash and the ref is given as the ref query parameter
+func parseGitSource(source string) (repo, path, ref string, err error) {
+	s := strings.TrimPrefix(source, gitPrefix)
+
+	if i := strings.Index(s, "?"); i >= 0 {
+		query, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid git source query %s: %w", source, err)
+		}
+		ref = query.Get("ref")
+		s = s[:i]
+	}
+
+	// The scheme separator is not a path separator
+	start := 0
+	if i := strings.Index(s, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.Index(s[start:], "//"); i >= 0 {
+		path = s[start+i+2:]
+		s = s[:start+i]
+	}
+	repo = s
+
+	if repo == "" {
+		return "", "", "", fmt.Errorf("missing repository in git source %s", source)
+	}
+	// Arguments starting with a dash would be parsed as git options
+	if strings.HasPrefix(repo, "-") || strings.HasPrefix(ref, "-") {
+		return "", "", "", fmt.Errorf("invalid git source %s", source)
+	}
+	path = filepath.ToSlash(filepath.Clean("/" + path))[1:]
+	if path == "." {
+		path = ""
+	}
+	return repo, path, ref, nil
+}
+
+// isBareRepository reports whether dir is a local bare git repository
+func isBareRepository(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return os.IsNotExist(err)
+}
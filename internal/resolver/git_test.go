@@ -0,0 +1,179 @@
+package resolver
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// newTestGitRepo creates a repository with a Role under deploy/ and a chart under chart/.
+// Tag v1.0.0 grants get on pods, the following commit on main grants get and list.
+// It returns the working repository, a bare clone and the commits of v1.0.0 and main.
+func newTestGitRepo(t *testing.T) (string, string, string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)
+		out, err := runGit(context.Background(), dir, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	role := func(verbs string) string {
+		return "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: reader\n  namespace: default\nrules:\n  - apiGroups: [\"\"]\n    resources: [\"pods\"]\n    verbs: [" + verbs + "]\n"
+	}
+
+	writeFiles(t, work, map[string]string{
+		"deploy/role.yaml":          role(`"get"`),
+		"chart/Chart.yaml":          "apiVersion: v2\nname: git-chart\nversion: 0.1.0\n",
+		"chart/templates/role.yaml": role(`"get"`),
+	})
+	git(root, "init", "--quiet", work)
+	git(work, "add", ".")
+	git(work, "commit", "--quiet", "-m", "v1")
+	git(work, "tag", "v1.0.0")
+	v1 := git(work, "rev-parse", "HEAD")
+
+	writeFiles(t, work, map[string]string{"deploy/role.yaml": role(`"get", "list"`)})
+	git(work, "commit", "--quiet", "-am", "v2")
+	main := git(work, "rev-parse", "HEAD")
+
+	bare := filepath.Join(root, "bare.git")
+	git(root, "clone", "--quiet", "--bare", work, bare)
+	return work, bare, v1, main
+}
+
+func TestGitResolver_Resolve(t *testing.T) {
+	work, bare, v1, main := newTestGitRepo(t)
+
+	tests := []struct {
+		name       string
+		source     string
+		wantCommit string
+		wantVerbs  int
+		wantHelm   bool
+		wantErr    bool
+	}{
+		{name: "tag", source: "git::file://" + work + "//deploy?ref=v1.0.0", wantCommit: v1, wantVerbs: 1},
+		{name: "default branch", source: "git::" + work + "//deploy", wantCommit: main, wantVerbs: 2},
+		{name: "branch", source: "git::" + bare + "//deploy?ref=main", wantCommit: main, wantVerbs: 2},
+		{name: "commit", source: "git::" + bare + "//deploy?ref=" + v1, wantCommit: v1, wantVerbs: 1},
+		{name: "helm chart", source: "git::" + bare + "//chart?ref=v1.0.0", wantCommit: v1, wantVerbs: 1, wantHelm: true},
+		{name: "bare repository", source: bare, wantCommit: main},
+		{name: "unknown ref", source: "git::" + bare + "//deploy?ref=v9.9.9", wantErr: true},
+		{name: "unknown path", source: "git::" + bare + "//missing?ref=v1.0.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ResolverFactory(tt.source, nil)
+			if err != nil {
+				t.Fatalf("ResolverFactory() error = %v", err)
+			}
+			if _, ok := r.(*GitResolver); !ok {
+				t.Fatalf("ResolverFactory() = %T, want *GitResolver", r)
+			}
+
+			result, meta, err := r.Resolve(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if meta.Type != SourceTypeGit || meta.Path != tt.source {
+				t.Errorf("metadata type/path = %v/%s, want git/%s", meta.Type, meta.Path, tt.source)
+			}
+			if meta.Extra["commit"] != tt.wantCommit {
+				t.Errorf("commit = %v, want %s", meta.Extra["commit"], tt.wantCommit)
+			}
+			if (meta.RendererType == RendererTypeHelm) != tt.wantHelm {
+				t.Errorf("renderer type = %v, want helm %v", meta.RendererType, tt.wantHelm)
+			}
+			if tt.wantVerbs == 0 {
+				// The repository root holds the Role under deploy/ and the chart templates
+				if len(result.Manifests) != 3 {
+					t.Errorf("expected 3 manifests, got %d", len(result.Manifests))
+				}
+				return
+			}
+			if len(result.Manifests) != 1 {
+				t.Fatalf("expected 1 manifest, got %d", len(result.Manifests))
+			}
+			rules, _ := result.Manifests[0].Content["rules"].([]interface{})
+			rule, _ := rules[0].(map[string]interface{})
+			if verbs, _ := rule["verbs"].([]interface{}); len(verbs) != tt.wantVerbs {
+				t.Errorf("verbs = %v, want %d verbs", rule["verbs"], tt.wantVerbs)
+			}
+		})
+	}
+}
+
+func TestParseGitSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		wantRepo string
+		wantPath string
+		wantRef  string
+		wantErr  bool
+	}{
+		{
+			source:   "git::https://github.com/org/repo.git//deploy/chart?ref=v1.2.3",
+			wantRepo: "https://github.com/org/repo.git",
+			wantPath: "deploy/chart",
+			wantRef:  "v1.2.3",
+		},
+		{
+			source:   "git::https://github.com/org/repo.git",
+			wantRepo: "https://github.com/org/repo.git",
+		},
+		{
+			source:   "git::git@github.com:org/repo.git//config/rbac",
+			wantRepo: "git@github.com:org/repo.git",
+			wantPath: "config/rbac",
+		},
+		{
+			source:   "git::file:///srv/repo.git//../../etc?ref=main",
+			wantRepo: "file:///srv/repo.git",
+			wantPath: "etc",
+			wantRef:  "main",
+		},
+		{
+			source:   "/srv/repo.git",
+			wantRepo: "/srv/repo.git",
+		},
+		{source: "git::", wantErr: true},
+		{source: "git::https://github.com/org/repo.git?ref=--upload-pack=evil", wantErr: true},
+		{source: "git::--upload-pack=evil", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		repo, path, ref, err := parseGitSource(tt.source)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGitSource(%q) error = %v, wantErr %v", tt.source, err, tt.wantErr)
+			continue
+		}
+		if repo != tt.wantRepo || path != tt.wantPath || ref != tt.wantRef {
+			t.Errorf("parseGitSource(%q) = %q, %q, %q, want %q, %q, %q", tt.source, repo, path, ref, tt.wantRepo, tt.wantPath, tt.wantRef)
+		}
+	}
+}
+
+func TestIsBareRepository(t *testing.T) {
+	work, bare, _, _ := newTestGitRepo(t)
+	if !isBareRepository(bare) {
+		t.Errorf("isBareRepository(%q) = false", bare)
+	}
+	if isBareRepository(work) || isBareRepository(filepath.Join(work, "deploy")) {
+		t.Error("isBareRepository() = true for a working tree")
+	}
+}
@@ -0,0 +1,102 @@
+package resolver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+)
+
+// HelmRepoResolver implements SourceResolver for charts published in a Helm repository,
+// the chart is looked up by name and version in the repository index.yaml
+type HelmRepoResolver struct {
+	repoURL string
+	name    string
+	version string
+	opts    *Options
+	client  *http.Client
+}
+
+// NewHelmRepoResolver creates a new HelmRepoResolver for chart, given as name or name@version.
+// The version can be a semver constraint, the latest stable version is used when it is empty.
+func NewHelmRepoResolver(repoURL, chart string, opts *Options, client *http.Client) (*HelmRepoResolver, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	if !isValidURL(repoURL) {
+		return nil, fmt.Errorf("invalid repository URL: %s", repoURL)
+	}
+	name, version, _ := strings.Cut(chart, "@")
+	if name == "" {
+		return nil, fmt.Errorf("chart name is required")
+	}
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	return &HelmRepoResolver{
+		repoURL: strings.TrimSuffix(repoURL, "/"),
+		name:    name,
+		version: version,
+		opts:    opts,
+		client:  client,
+	}, nil
+}
+
+// CanResolve checks if this resolver can handle the given source
+func (r *HelmRepoResolver) CanResolve(source string) bool {
+	return isValidURL(source) && (strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"))
+}
+
+// Resolve looks up the chart in the repository index, downloads and renders it
+func (r *HelmRepoResolver) Resolve(ctx context.Context) (*renderer.Result, *ResolverMetadata, error) {
+	content, err := fetchURL(ctx, r.client, r.repoURL+"/index.yaml", r.opts.maxArchiveSize())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch repository index: %w", err)
+	}
+
+	var index repo.IndexFile
+	if err := yaml.Unmarshal(content, &index); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse repository index: %w", err)
+	}
+	index.SortEntries()
+
+	chartVersion, err := index.Get(r.name, r.version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chart %s version %q not found in %s: %w", r.name, r.version, r.repoURL, err)
+	}
+	if len(chartVersion.URLs) == 0 {
+		return nil, nil, fmt.Errorf("chart %s version %s has no download URL", r.name, chartVersion.Version)
+	}
+	chartURL, err := repo.ResolveReferenceURL(r.repoURL+"/", chartVersion.URLs[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid chart URL %s: %w", chartVersion.URLs[0], err)
+	}
+
+	archive, err := fetchURL(ctx, r.client, chartURL, r.opts.maxArchiveSize())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download chart: %w", err)
+	}
+	if chartVersion.Digest != "" {
+		sum := sha256.Sum256(archive)
+		if digest := hex.EncodeToString(sum[:]); !strings.EqualFold(digest, chartVersion.Digest) {
+			return nil, nil, fmt.Errorf("chart digest mismatch: got %s, index has %s", digest, chartVersion.Digest)
+		}
+	}
+
+	result, err := renderChartArchive(ctx, archive, r.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, chartArchiveMetadata(result, SourceTypeHelmRepo, r.repoURL, int64(len(archive)), map[string]interface{}{
+		"repository": r.repoURL,
+		"url":        chartURL,
+		"digest":     chartVersion.Digest,
+	}), nil
+}
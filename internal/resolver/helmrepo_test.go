@@ -0,0 +1,119 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestHelmRepo serves an index.yaml listing the test chart and the chart archive.
+// Version 0.2.0 points to the same archive with a wrong digest.
+func newTestHelmRepo(t *testing.T) *httptest.Server {
+	t.Helper()
+	archive := readTestChart(t)
+	digest := strings.TrimPrefix(sha256Digest(archive), "sha256:")
+	index := fmt.Sprintf(`apiVersion: v1
+entries:
+  test-chart:
+    - apiVersion: v2
+      name: test-chart
+      version: 0.2.0
+      digest: 0000
+      urls:
+        - charts/test-chart-0.1.0.tgz
+    - apiVersion: v2
+      name: test-chart
+      version: 0.1.0
+      digest: %s
+      urls:
+        - charts/test-chart-0.1.0.tgz
+    - apiVersion: v2
+      name: test-chart
+      version: 0.0.1
+      urls:
+        - charts/missing.tgz
+`, digest)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.yaml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(index))
+	})
+	mux.HandleFunc("/charts/test-chart-0.1.0.tgz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHelmRepoResolver_Resolve(t *testing.T) {
+	server := newTestHelmRepo(t)
+
+	tests := []struct {
+		name    string
+		repoURL string
+		chart   string
+		wantErr string
+	}{
+		{name: "exact version", repoURL: server.URL, chart: "test-chart@0.1.0"},
+		{name: "version constraint", repoURL: server.URL + "/", chart: "test-chart@~0.1"},
+		{name: "digest mismatch", repoURL: server.URL, chart: "test-chart", wantErr: "digest mismatch"},
+		{name: "unknown chart", repoURL: server.URL, chart: "other", wantErr: "not found"},
+		{name: "unknown version", repoURL: server.URL, chart: "test-chart@9.9.9", wantErr: "not found"},
+		{name: "missing archive", repoURL: server.URL, chart: "test-chart@0.0.1", wantErr: "failed to download chart"},
+		{name: "missing index", repoURL: server.URL + "/missing", chart: "test-chart", wantErr: "failed to fetch repository index"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewHelmRepoResolver(tt.repoURL, tt.chart, nil, server.Client())
+			if err != nil {
+				t.Fatalf("NewHelmRepoResolver() error = %v", err)
+			}
+			result, meta, err := r.Resolve(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if meta.Type != SourceTypeHelmRepo || meta.RendererType != RendererTypeHelm {
+				t.Errorf("metadata type = %v/%v, want helm-repo/helm", meta.Type, meta.RendererType)
+			}
+			if meta.Name != "test-chart" || meta.Version != "0.1.0" {
+				t.Errorf("unexpected metadata %+v", meta)
+			}
+			chart, _ := meta.Extra["chart"].(map[string]interface{})
+			if chart["url"] != server.URL+"/charts/test-chart-0.1.0.tgz" {
+				t.Errorf("chart url = %v", chart["url"])
+			}
+			if len(result.Manifests) != 1 || result.Manifests[0].Content["kind"] != "Role" {
+				t.Errorf("unexpected manifests %+v", result.Manifests)
+			}
+		})
+	}
+}
+
+func TestNewHelmRepoResolver(t *testing.T) {
+	tests := []struct {
+		repoURL string
+		chart   string
+		wantErr bool
+	}{
+		{"https://charts.example.com", "operator", false},
+		{"https://charts.example.com", "operator@1.2.0", false},
+		{"https://charts.example.com", "@1.2.0", true},
+		{"charts.example.com", "operator", true},
+	}
+	for _, tt := range tests {
+		if _, err := NewHelmRepoResolver(tt.repoURL, tt.chart, nil, nil); (err != nil) != tt.wantErr {
+			t.Errorf("NewHelmRepoResolver(%q, %q) error = %v, wantErr %v", tt.repoURL, tt.chart, err, tt.wantErr)
+		}
+	}
+}
@@ -10,7 +10,7 @@ import (
 	"github.com/alevsk/rbac-scope/internal/renderer"
 )
 
-// LocalYAMLResolver implements SourceResolver for local YAML files
+// LocalYAMLResolver implements SourceResolver for local YAML and JSON files
 type LocalYAMLResolver struct {
 	source   string
 	opts     *Options
@@ -41,13 +41,22 @@ func NewLocalYAMLResolver(source string, opts *Options) *LocalYAMLResolver {
 
 // CanResolve checks if this resolver can handle the given source
 func (r *LocalYAMLResolver) CanResolve(source string) bool {
-	// Check if file exists and has a YAML extension
+	// Check if file exists and has a YAML or JSON extension
 	if _, err := os.Stat(source); err != nil {
 		return false
 	}
 
-	ext := strings.ToLower(filepath.Ext(source))
-	return ext == ".yaml" || ext == ".yml"
+	return isManifestFile(source)
+}
+
+// isManifestFile reports whether path has the extension of a YAML or JSON manifest file
+func isManifestFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
 }
 
 // Resolve processes the source and returns the rendered manifests
@@ -86,6 +95,7 @@ func (r *LocalYAMLResolver) Resolve(ctx context.Context) (*renderer.Result, *Res
 	if err != nil {
 		return nil, nil, err
 	}
+	setManifestFile(result.Manifests, r.source)
 
 	return result, &ResolverMetadata{
 		Name:    r.source,
@@ -101,6 +111,19 @@ func (r *LocalYAMLResolver) Resolve(ctx context.Context) (*renderer.Result, *Res
 	}, nil
 }
 
+// setManifestFile records file under the file metadata key of the manifests that have metadata
+// and do not record a file already
+func setManifestFile(manifests []*renderer.Manifest, file string) {
+	for _, m := range manifests {
+		if m.Metadata == nil {
+			continue
+		}
+		if _, ok := m.Metadata["file"]; !ok {
+			m.Metadata["file"] = file
+		}
+	}
+}
+
 // isValidYAML performs basic YAML validation
 // This is a simple check for common YAML markers
 func isValidYAML(content string) bool {
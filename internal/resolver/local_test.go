@@ -19,6 +19,11 @@ func TestLocalYAMLResolver_CanResolve(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	jsonFile := filepath.Join(tmpDir, "roles.json")
+	if err := os.WriteFile(jsonFile, []byte(`{"kind": "List", "items": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
 	nonYAMLFile := filepath.Join(tmpDir, "test.txt")
 	if err := os.WriteFile(nonYAMLFile, []byte("text"), 0644); err != nil {
 		t.Fatal(err)
@@ -34,6 +39,11 @@ func TestLocalYAMLResolver_CanResolve(t *testing.T) {
 			source: validFile,
 			want:   true,
 		},
+		{
+			name:   "json file",
+			source: jsonFile,
+			want:   true,
+		},
 		{
 			name:   "non-yaml file",
 			source: nonYAMLFile,
@@ -14,6 +14,16 @@ const (
 	SourceTypeRemote
 	// SourceTypeFolder represents a directory containing YAML files
 	SourceTypeFolder
+	// SourceTypeOCI represents a Helm chart stored in an OCI registry
+	SourceTypeOCI
+	// SourceTypeHelmRepo represents a chart published in a Helm repository
+	SourceTypeHelmRepo
+	// SourceTypeGit represents a path inside a git repository
+	SourceTypeGit
+	// SourceTypeStdin represents documents read from stdin
+	SourceTypeStdin
+	// SourceTypeCluster represents the objects deployed in a live cluster
+	SourceTypeCluster
 )
 
 // ResolverMetadata contains information about the resolved source
@@ -22,7 +32,7 @@ type ResolverMetadata struct {
 	Name string
 	// Version of the artifact
 	Version string
-	// Type is the source type (file, folder, remote)
+	// Type is the source type (file, folder, remote, oci, helm-repo, git, stdin, cluster)
 	Type SourceType
 	// RendererType indicates the type of renderer used (yaml, helm, kustomize)
 	RendererType RendererType
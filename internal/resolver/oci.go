@@ -0,0 +1,96 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// ociScheme is the prefix of Helm charts stored in OCI registries
+const ociScheme = "oci://"
+
+// OCIChartResolver implements SourceResolver for Helm charts stored in OCI registries,
+// such as oci://registry.example.com/charts/operator:1.2.0
+type OCIChartResolver struct {
+	source string
+	opts   *Options
+	client *http.Client
+}
+
+// NewOCIChartResolver creates a new OCIChartResolver, a nil client uses the helm registry defaults
+func NewOCIChartResolver(source string, opts *Options, client *http.Client) (*OCIChartResolver, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	r := &OCIChartResolver{
+		source: source,
+		opts:   opts,
+		client: client,
+	}
+	if !r.CanResolve(source) {
+		return nil, fmt.Errorf("invalid OCI chart reference: %s", source)
+	}
+	return r, nil
+}
+
+// CanResolve checks if this resolver can handle the given source
+func (r *OCIChartResolver) CanResolve(source string) bool {
+	ref := strings.TrimPrefix(source, ociScheme)
+	return strings.HasPrefix(source, ociScheme) && strings.Contains(ref, "/")
+}
+
+// Resolve pulls the chart from the registry and renders it
+func (r *OCIChartResolver) Resolve(ctx context.Context) (*renderer.Result, *ResolverMetadata, error) {
+	clientOpts := []registry.ClientOption{}
+	if r.client != nil {
+		clientOpts = append(clientOpts, registry.ClientOptHTTPClient(r.client))
+	}
+	if r.opts.PlainHTTP {
+		clientOpts = append(clientOpts, registry.ClientOptPlainHTTP())
+	}
+	client, err := registry.NewClient(clientOpts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	ref := strings.TrimPrefix(r.source, ociScheme)
+	if !hasOCITagOrDigest(ref) {
+		// Use the latest semver tag, as helm does when no version is given
+		tags, err := client.Tags(ref)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list tags of %s: %w", r.source, err)
+		}
+		if len(tags) == 0 {
+			return nil, nil, fmt.Errorf("no chart versions found in %s", r.source)
+		}
+		ref = ref + ":" + tags[0]
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	pulled, err := client.Pull(ref, registry.PullOptWithChart(true))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to pull chart %s: %w", r.source, err)
+	}
+
+	result, err := renderChartArchive(ctx, pulled.Chart.Data, r.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, chartArchiveMetadata(result, SourceTypeOCI, r.source, pulled.Chart.Size, map[string]interface{}{
+		"reference": ociScheme + pulled.Ref,
+		"digest":    pulled.Manifest.Digest,
+	}), nil
+}
+
+// hasOCITagOrDigest reports whether the last path element of an OCI reference sets a tag or digest
+func hasOCITagOrDigest(ref string) bool {
+	name := ref[strings.LastIndex(ref, "/")+1:]
+	return strings.ContainsAny(name, ":@")
+}
@@ -0,0 +1,176 @@
+package resolver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// testChartArchive is a packaged chart with a single Role named test-role
+var testChartArchive = filepath.Join("..", "renderer", "testdata", "fixtures", "test-chart-0.1.0.tgz")
+
+func readTestChart(t *testing.T) []byte {
+	t.Helper()
+	archive, err := os.ReadFile(testChartArchive)
+	if err != nil {
+		t.Fatalf("failed to read chart fixture: %v", err)
+	}
+	return archive
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newTestRegistry serves a minimal OCI distribution API with the chart archive
+// pushed to repository under each of the tags
+func newTestRegistry(t *testing.T, repository string, archive []byte, tags ...string) *httptest.Server {
+	t.Helper()
+	config := []byte(`{"apiVersion":"v2","name":"test-chart","version":"0.1.0"}`)
+	blobs := map[string][]byte{
+		sha256Digest(config):  config,
+		sha256Digest(archive): archive,
+	}
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        map[string]interface{}{"mediaType": registry.ConfigMediaType, "digest": sha256Digest(config), "size": len(config)},
+		"layers": []map[string]interface{}{
+			{"mediaType": registry.ChartLayerMediaType, "digest": sha256Digest(archive), "size": len(archive)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestDigest := sha256Digest(manifest)
+
+	serve := func(w http.ResponseWriter, r *http.Request, mediaType, digest string, content []byte) {
+		w.Header().Set("Content-Type", mediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(content)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/v2/" + repository + "/"
+		path := r.URL.Path
+		switch {
+		case path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case path == prefix+"tags/list":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repository, "tags": tags})
+		case strings.HasPrefix(path, prefix+"manifests/"):
+			ref := strings.TrimPrefix(path, prefix+"manifests/")
+			for _, tag := range tags {
+				if ref == tag || ref == manifestDigest {
+					serve(w, r, "application/vnd.oci.image.manifest.v1+json", manifestDigest, manifest)
+					return
+				}
+			}
+			http.NotFound(w, r)
+		case strings.HasPrefix(path, prefix+"blobs/"):
+			digest := strings.TrimPrefix(path, prefix+"blobs/")
+			content, ok := blobs[digest]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			serve(w, r, "application/octet-stream", digest, content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOCIChartResolver_Resolve(t *testing.T) {
+	archive := readTestChart(t)
+	server := newTestRegistry(t, "charts/test-chart", archive, "0.0.9", "0.1.0")
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name    string
+		source  string
+		wantErr bool
+	}{
+		{name: "tag", source: "oci://" + host + "/charts/test-chart:0.1.0"},
+		{name: "latest tag", source: "oci://" + host + "/charts/test-chart"},
+		{name: "unknown tag", source: "oci://" + host + "/charts/test-chart:2.0.0", wantErr: true},
+		{name: "unknown repository", source: "oci://" + host + "/charts/other:0.1.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewOCIChartResolver(tt.source, &Options{PlainHTTP: true}, server.Client())
+			if err != nil {
+				t.Fatalf("NewOCIChartResolver() error = %v", err)
+			}
+			result, meta, err := r.Resolve(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if meta.Type != SourceTypeOCI || meta.RendererType != RendererTypeHelm {
+				t.Errorf("metadata type = %v/%v, want oci/helm", meta.Type, meta.RendererType)
+			}
+			if meta.Name != "test-chart" || meta.Version != "0.1.0" || meta.Size != int64(len(archive)) {
+				t.Errorf("unexpected metadata %+v", meta)
+			}
+			chart, _ := meta.Extra["chart"].(map[string]interface{})
+			if ref, _ := chart["reference"].(string); !strings.HasSuffix(ref, "/charts/test-chart:0.1.0") {
+				t.Errorf("chart reference = %v", chart["reference"])
+			}
+			if len(result.Manifests) != 1 || result.Manifests[0].Content["kind"] != "Role" {
+				t.Errorf("unexpected manifests %+v", result.Manifests)
+			}
+		})
+	}
+}
+
+func TestNewOCIChartResolver(t *testing.T) {
+	tests := []struct {
+		source  string
+		wantErr bool
+	}{
+		{"oci://registry.example.com/charts/operator:1.0.0", false},
+		{"oci://registry.example.com/operator", false},
+		{"oci://registry.example.com", true},
+		{"https://registry.example.com/operator", true},
+	}
+	for _, tt := range tests {
+		if _, err := NewOCIChartResolver(tt.source, nil, nil); (err != nil) != tt.wantErr {
+			t.Errorf("NewOCIChartResolver(%q) error = %v, wantErr %v", tt.source, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHasOCITagOrDigest(t *testing.T) {
+	tests := map[string]bool{
+		"registry.example.com/charts/operator":                 false,
+		"registry.example.com/charts/operator:1.0.0":           true,
+		"registry.example.com/charts/operator@sha256:abcdef01": true,
+		"localhost:5000/operator":                              false,
+		"localhost:5000/operator:1.0.0":                        true,
+	}
+	for ref, want := range tests {
+		if got := hasOCITagOrDigest(ref); got != want {
+			t.Errorf("hasOCITagOrDigest(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
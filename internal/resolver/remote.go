@@ -6,8 +6,6 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"path"
-	"strings"
 	"time"
 
 	"github.com/alevsk/rbac-scope/internal/renderer"
@@ -20,7 +18,7 @@ var defaultHTTPClient = http.DefaultClient
 // Default timeout for HTTP requests
 const defaultHTTPTimeout = 30 * time.Second
 
-// RemoteYAMLResolver implements SourceResolver for remote HTTP/HTTPS resources
+// RemoteYAMLResolver implements SourceResolver for remote YAML and JSON HTTP/HTTPS resources
 type RemoteYAMLResolver struct {
 	source   string
 	opts     *Options
@@ -101,8 +99,7 @@ func (r *RemoteYAMLResolver) CanResolve(source string) bool {
 	}
 
 	// Check file extension if present
-	ext := strings.ToLower(path.Ext(u.Path))
-	return ext == ".yaml" || ext == ".yml"
+	return isManifestFile(u.Path)
 }
 
 // Resolve processes the source and returns the rendered manifests
@@ -114,7 +111,7 @@ func (r *RemoteYAMLResolver) Resolve(ctx context.Context) (*renderer.Result, *Re
 	}
 
 	// Add appropriate headers
-	req.Header.Set("Accept", "application/yaml,text/yaml,text/plain")
+	req.Header.Set("Accept", "application/yaml,text/yaml,application/json,text/plain")
 	req.Header.Set("User-Agent", "rbac-scope/1.0")
 
 	// Perform the request
@@ -145,6 +142,7 @@ func (r *RemoteYAMLResolver) Resolve(ctx context.Context) (*renderer.Result, *Re
 	if err != nil {
 		return nil, nil, err
 	}
+	setManifestFile(result.Manifests, r.source)
 
 	return result, &ResolverMetadata{
 		Name:    result.Name,
@@ -180,6 +180,12 @@ func TestRemoteYAMLResolver_CanResolve(t *testing.T) {
 			wantErr: false,
 			wantCan: true,
 		},
+		{
+			name:    "valid json url",
+			source:  "https://example.com/clusterroles.json",
+			wantErr: false,
+			wantCan: true,
+		},
 		{
 			name:    "invalid URL",
 			source:  "not-a-url",
@@ -3,8 +3,9 @@ package resolver
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/alevsk/rbac-scope/internal/renderer"
@@ -16,19 +17,66 @@ type Options struct {
 	FollowSymlinks bool
 	// ValidateYAML enables strict YAML validation during ingestion
 	ValidateYAML bool
-	// Values is a file path to a values.yaml file used for rendering a helm chart
-	Values string
+	// Values are the value files and overrides used for rendering a helm chart
+	Values renderer.ValuesOptions
+	// ReleaseName is the helm release name used for rendering charts
+	ReleaseName string
+	// Namespace is the helm release namespace used for rendering charts
+	Namespace string
+	// KubeVersion is the Kubernetes version exposed to chart templates
+	KubeVersion string
+	// APIVersions are additional API versions exposed to chart templates
+	APIVersions []string
+	// DependencyDir is a directory with packaged charts (name-version.tgz), such as a local
+	// chart repository, used for chart dependencies that are not vendored under charts/
+	DependencyDir string
+	// KustomizeRoot bounds the directories Kustomize overlays and their bases may be read from,
+	// the enclosing git working tree or the overlay directory when empty
+	KustomizeRoot string
+	// KustomizeHelm enables the helmCharts generator of Kustomize overlays, charts are read from
+	// the chartHome of the overlay or from DependencyDir
+	KustomizeHelm bool
+	// RenderMode selects how directory sources are rendered, RenderModeAuto when empty
+	RenderMode RenderMode
+	// Chart selects a chart of a Helm repository source as name or name@version
+	Chart string
+	// PlainHTTP uses HTTP instead of HTTPS to pull charts from OCI registries
+	PlainHTTP bool
+	// Kubeconfig is the kubeconfig file of cluster sources, the default loading rules are used when empty
+	Kubeconfig string
+	// Namespaces limits the namespaced objects read from cluster sources, all namespaces when empty
+	Namespaces []string
+	// ExcludeNamespaces are namespaces whose objects are not read from cluster sources
+	ExcludeNamespaces []string
+	// SnapshotPath is a file where the YAML bundle read from a cluster source is saved
+	SnapshotPath string
+	// Stdin is the reader of the stdin source, os.Stdin when nil
+	Stdin io.Reader
+	// MaxArchiveSize is the maximum size in bytes of a chart archive, compressed and
+	// decompressed, zero uses DefaultMaxArchiveSize
+	MaxArchiveSize int64
 }
 
+// DefaultMaxArchiveSize is the default maximum size of a chart archive
+const DefaultMaxArchiveSize int64 = 100 * 1024 * 1024
+
 // DefaultOptions returns the default resolver options
 func DefaultOptions() *Options {
 	return &Options{
 		FollowSymlinks: false,
 		ValidateYAML:   true,
-		Values:         "",
+		MaxArchiveSize: DefaultMaxArchiveSize,
 	}
 }
 
+// maxArchiveSize returns the configured maximum chart archive size
+func (o *Options) maxArchiveSize() int64 {
+	if o == nil || o.MaxArchiveSize <= 0 {
+		return DefaultMaxArchiveSize
+	}
+	return o.MaxArchiveSize
+}
+
 // String returns the string representation of a SourceType
 func (st SourceType) String() string {
 	switch st {
@@ -38,6 +86,16 @@ func (st SourceType) String() string {
 		return "remote"
 	case SourceTypeFolder:
 		return "folder"
+	case SourceTypeOCI:
+		return "oci"
+	case SourceTypeHelmRepo:
+		return "helm-repo"
+	case SourceTypeGit:
+		return "git"
+	case SourceTypeStdin:
+		return "stdin"
+	case SourceTypeCluster:
+		return "cluster"
 	default:
 		return "unknown"
 	}
@@ -59,11 +117,41 @@ func ResolverFactory(source string, opts *Options) (SourceResolver, error) {
 		return nil, fmt.Errorf("empty source")
 	}
 
+	// Documents piped to stdin
+	if source == StdinSource {
+		var stdin io.Reader
+		if opts != nil {
+			stdin = opts.Stdin
+		}
+		return NewStdinResolver(stdin, opts), nil
+	}
+
+	// Objects deployed in a live cluster
+	if strings.HasPrefix(source, clusterScheme) {
+		return NewClusterResolver(source, opts, nil)
+	}
+
+	// Paths inside git repositories
+	if strings.HasPrefix(source, gitPrefix) {
+		return NewGitResolver(source, opts)
+	}
+
+	// Helm charts stored in OCI registries
+	if strings.HasPrefix(source, ociScheme) {
+		return NewOCIChartResolver(source, opts, nil)
+	}
+
 	// Try to parse as URL first
 	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
-		ext := strings.ToLower(filepath.Ext(source))
-		if ext != ".yaml" && ext != ".yml" {
-			return nil, fmt.Errorf("URL does not point to a YAML file: %s", source)
+		// A chart selects a chart from the Helm repository at the URL
+		if opts != nil && opts.Chart != "" {
+			return NewHelmRepoResolver(source, opts.Chart, opts, defaultHTTPClient)
+		}
+		if isChartArchive(source) {
+			return NewChartArchiveResolver(source, opts, defaultHTTPClient)
+		}
+		if u, err := url.Parse(source); err != nil || !isManifestFile(u.Path) {
+			return nil, fmt.Errorf("URL does not point to a YAML or JSON file: %s", source)
 		}
 		return NewRemoteYAMLResolver(source, opts, defaultHTTPClient)
 	}
@@ -71,6 +159,9 @@ func ResolverFactory(source string, opts *Options) (SourceResolver, error) {
 	// Check if it's a directory
 	info, err := os.Stat(source)
 	if err == nil && info.IsDir() {
+		if isBareRepository(source) {
+			return NewGitResolver(source, opts)
+		}
 		resolver := NewFolderResolver(source, opts)
 		if resolver.CanResolve(source) {
 			return resolver, nil
@@ -78,6 +169,11 @@ func ResolverFactory(source string, opts *Options) (SourceResolver, error) {
 		return nil, fmt.Errorf("directory cannot be resolved")
 	}
 
+	// Packaged Helm charts
+	if isChartArchive(source) {
+		return NewChartArchiveResolver(source, opts, nil)
+	}
+
 	// Try local YAML resolver
 	resolver := NewLocalYAMLResolver(source, opts)
 	if resolver.CanResolve(source) {
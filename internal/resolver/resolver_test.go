@@ -1,6 +1,7 @@
 package resolver
 
 import (
+	"fmt"
 	"net/http"
 	"path/filepath" // Ensured import
 	"reflect"
@@ -11,6 +12,7 @@ func TestDefaultOptions(t *testing.T) {
 	expected := &Options{
 		FollowSymlinks: false,
 		ValidateYAML:   true,
+		MaxArchiveSize: DefaultMaxArchiveSize,
 	}
 	got := DefaultOptions()
 	if !reflect.DeepEqual(got, expected) {
@@ -39,6 +41,31 @@ func TestSourceTypeString(t *testing.T) {
 			st:   SourceTypeFolder,
 			want: "folder",
 		},
+		{
+			name: "oci source type",
+			st:   SourceTypeOCI,
+			want: "oci",
+		},
+		{
+			name: "helm repository source type",
+			st:   SourceTypeHelmRepo,
+			want: "helm-repo",
+		},
+		{
+			name: "git source type",
+			st:   SourceTypeGit,
+			want: "git",
+		},
+		{
+			name: "stdin source type",
+			st:   SourceTypeStdin,
+			want: "stdin",
+		},
+		{
+			name: "cluster source type",
+			st:   SourceTypeCluster,
+			want: "cluster",
+		},
 		{
 			name: "unknown source type",
 			st:   SourceTypeUnknown,
@@ -73,6 +100,7 @@ func TestResolverFactory(t *testing.T) {
 	mockClient.addResponse("http://example.com/rbac.yaml", http.StatusOK, clusterRole)
 	mockClient.addResponse("https://example.com/rbac.yaml", http.StatusOK, roleWithSecrets)
 	mockClient.addResponse("http://example.com/file.txt", http.StatusOK, "not a yaml file")
+	mockClient.addResponse("https://example.com/rbac.json?ref=main", http.StatusOK, `{"apiVersion": "v1", "kind": "List", "items": [{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "Role", "metadata": {"name": "reader"}}]}`)
 
 	// Override default HTTP client
 	defaultHTTPClient = mockClient.GetClient()
@@ -100,6 +128,12 @@ func TestResolverFactory(t *testing.T) {
 			wantErr:  false,
 			wantType: SourceTypeRemote,
 		},
+		{
+			name:     "https url json source with query",
+			source:   "https://example.com/rbac.json?ref=main",
+			wantErr:  false,
+			wantType: SourceTypeRemote,
+		},
 		{
 			name:    "http url non-yaml",
 			source:  "http://example.com/file.txt",
@@ -145,3 +179,60 @@ func TestResolverFactory(t *testing.T) {
 		})
 	}
 }
+
+func TestResolverFactory_Charts(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		opts    *Options
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:   "oci reference",
+			source: "oci://registry.example.com/charts/operator:1.0.0",
+			want:   &OCIChartResolver{},
+		},
+		{
+			name:    "oci reference without repository",
+			source:  "oci://registry.example.com",
+			wantErr: true,
+		},
+		{
+			name:   "helm repository",
+			source: "https://charts.example.com",
+			opts:   &Options{Chart: "operator@1.0.0"},
+			want:   &HelmRepoResolver{},
+		},
+		{
+			name:   "local chart archive",
+			source: testChartArchive,
+			want:   &ChartArchiveResolver{},
+		},
+		{
+			name:   "remote chart archive",
+			source: "https://example.com/charts/operator-1.0.0.tgz",
+			want:   &ChartArchiveResolver{},
+		},
+		{
+			name:    "repository url without chart",
+			source:  "https://charts.example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ResolverFactory(tt.source, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolverFactory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if fmt.Sprintf("%T", r) != fmt.Sprintf("%T", tt.want) {
+				t.Errorf("ResolverFactory() = %T, want %T", r, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alevsk/rbac-scope/internal/renderer"
+)
+
+// StdinSource is the source that reads manifests from stdin
+const StdinSource = "-"
+
+// StdinResolver implements SourceResolver for a stream of YAML or JSON documents read from stdin,
+// such as the output of helm template or kustomize build
+type StdinResolver struct {
+	reader   io.Reader
+	opts     *Options
+	renderer renderer.Renderer
+}
+
+// NewStdinResolver creates a new StdinResolver reading from reader, os.Stdin when nil
+func NewStdinResolver(reader io.Reader, opts *Options) *StdinResolver {
+	if reader == nil {
+		reader = os.Stdin
+	}
+
+	rf := renderer.NewRendererFactory(&renderer.Options{
+		ValidateOutput:  opts != nil && opts.ValidateYAML,
+		IncludeMetadata: true,
+		OutputFormat:    "yaml",
+	})
+
+	r, err := rf.GetRenderer(renderer.RendererTypeYAML)
+	if err != nil {
+		// This should never happen with default options
+		panic(fmt.Sprintf("failed to create renderer: %v", err))
+	}
+
+	return &StdinResolver{
+		reader:   reader,
+		opts:     opts,
+		renderer: r,
+	}
+}
+
+// CanResolve checks if this resolver can handle the given source
+func (r *StdinResolver) CanResolve(source string) bool {
+	return source == StdinSource
+}
+
+// Resolve reads the documents from stdin and returns the rendered manifests
+func (r *StdinResolver) Resolve(ctx context.Context) (*renderer.Result, *ResolverMetadata, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	default:
+	}
+
+	content, err := io.ReadAll(r.reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	content = jsonStreamToYAML(content)
+
+	if err := r.renderer.Validate(content); err != nil {
+		return nil, nil, err
+	}
+
+	result, err := r.renderer.Render(ctx, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	setManifestFile(result.Manifests, "stdin")
+
+	return result, &ResolverMetadata{
+		Name:    "stdin",
+		Version: result.Version,
+		Type:    SourceTypeStdin,
+		Path:    "stdin",
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+		Extra: map[string]interface{}{
+			"manifests": len(result.Manifests),
+			"warnings":  result.Warnings,
+		},
+	}, nil
+}
+
+// jsonStreamToYAML turns a stream of concatenated JSON values, such as the output of
+// kubectl get -o json for several resources, into YAML documents. JSON is valid YAML,
+// so each value becomes a document as is. Any other content is returned unchanged.
+func jsonStreamToYAML(content []byte) []byte {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return content
+	}
+
+	var docs [][]byte
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	for {
+		var doc json.RawMessage
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Not a JSON stream, leave it to the YAML parser
+			return content
+		}
+		docs = append(docs, doc)
+	}
+	return bytes.Join(docs, []byte("\n---\n"))
+}
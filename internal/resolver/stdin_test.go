@@ -0,0 +1,76 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestStdinResolver_Resolve(t *testing.T) {
+	role := `{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "Role", "metadata": {"name": "reader"}}`
+	binding := `{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "RoleBinding", "metadata": {"name": "reader-binding"}}`
+
+	tests := []struct {
+		name      string
+		input     string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:      "multi-document yaml",
+			input:     "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: operator\n---\napiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: reader\n",
+			wantNames: []string{"operator", "reader"},
+		},
+		{
+			name:      "json document",
+			input:     role,
+			wantNames: []string{"reader"},
+		},
+		{
+			name:      "json stream",
+			input:     role + "\n" + binding + "\n",
+			wantNames: []string{"reader", "reader-binding"},
+		},
+		{
+			name:    "empty input",
+			input:   "",
+			wantErr: true,
+		},
+		{
+			name:    "scalar input",
+			input:   "not a manifest",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ResolverFactory(StdinSource, &Options{Stdin: strings.NewReader(tt.input)})
+			if err != nil {
+				t.Fatalf("ResolverFactory() error = %v", err)
+			}
+			if !r.CanResolve(StdinSource) {
+				t.Error("CanResolve() = false")
+			}
+
+			result, meta, err := r.Resolve(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if meta.Type != SourceTypeStdin || meta.Name != "stdin" || meta.Path != "stdin" {
+				t.Errorf("metadata = %v/%s/%s, want stdin", meta.Type, meta.Name, meta.Path)
+			}
+			var names []string
+			for _, m := range result.Manifests {
+				names = append(names, m.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("manifests = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package types
 
+import "fmt"
+
 // Manifest represents a single YAML manifest
 type Manifest struct {
 	// Name of the manifest
@@ -45,3 +47,47 @@ type Result struct {
 	// Additional data
 	Extra map[string]interface{} `json:"extra,omitempty"`
 }
+
+// Provenance locates the document a manifest was read from
+type Provenance struct {
+	// File is the file or Helm template the manifest was read from
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Document is the position of the document in the file, starting at 1
+	Document int `json:"document,omitempty" yaml:"document,omitempty"`
+	// Line is the line the document starts at, 0 when unknown
+	Line int `json:"line,omitempty" yaml:"line,omitempty"`
+}
+
+// String formats the provenance as file:line, or file#document when the line is unknown
+func (p *Provenance) String() string {
+	if p == nil {
+		return ""
+	}
+	switch {
+	case p.Line > 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	case p.Document > 0:
+		return fmt.Sprintf("%s#%d", p.File, p.Document)
+	default:
+		return p.File
+	}
+}
+
+// Provenance returns where the manifest was read from, recorded by the renderers and resolvers
+// under the file (or Helm template), docNum and line metadata keys. It is nil when unknown.
+func (m *Manifest) Provenance() *Provenance {
+	if m == nil {
+		return nil
+	}
+	p := &Provenance{}
+	p.File, _ = m.Metadata["file"].(string)
+	if p.File == "" {
+		p.File, _ = m.Metadata["template"].(string)
+	}
+	if p.File == "" {
+		return nil
+	}
+	p.Document, _ = m.Metadata["docNum"].(int)
+	p.Line, _ = m.Metadata["line"].(int)
+	return p
+}
@@ -132,3 +132,50 @@ func TestResult(t *testing.T) {
 		t.Errorf("Expected Success false, got %v", rEmpty.Success)
 	}
 }
+
+func TestManifestProvenance(t *testing.T) {
+	tests := []struct {
+		name       string
+		metadata   map[string]interface{}
+		want       *Provenance
+		wantString string
+	}{
+		{
+			name:       "file with line",
+			metadata:   map[string]interface{}{"file": "deploy/rbac.yaml", "docNum": 2, "line": 14},
+			want:       &Provenance{File: "deploy/rbac.yaml", Document: 2, Line: 14},
+			wantString: "deploy/rbac.yaml:14",
+		},
+		{
+			name:       "helm template",
+			metadata:   map[string]interface{}{"template": "operator/templates/role.yaml", "docNum": 3},
+			want:       &Provenance{File: "operator/templates/role.yaml", Document: 3},
+			wantString: "operator/templates/role.yaml#3",
+		},
+		{
+			name:       "file preferred over template",
+			metadata:   map[string]interface{}{"template": "operator/templates/role.yaml", "file": "charts/operator/templates/role.yaml"},
+			want:       &Provenance{File: "charts/operator/templates/role.yaml"},
+			wantString: "charts/operator/templates/role.yaml",
+		},
+		{
+			name:     "no file",
+			metadata: map[string]interface{}{"docNum": 1, "line": 1},
+		},
+		{
+			name: "no metadata",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manifest{Metadata: tt.metadata}
+			got := m.Provenance()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Provenance() = %+v, want %+v", got, tt.want)
+			}
+			if s := got.String(); s != tt.wantString {
+				t.Errorf("String() = %q, want %q", s, tt.wantString)
+			}
+		})
+	}
+}